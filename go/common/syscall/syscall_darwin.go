@@ -1,7 +1,10 @@
 // Package syscall defines OS-specific syscall parameters.
 package syscall
 
-import "syscall"
+import (
+	"errors"
+	"syscall"
+)
 
 // IoctlTermiosGetAttr is the ioctl that implements termios tcgetattr.
 const IoctlTermiosGetAttr = syscall.TIOCGETA
@@ -10,3 +13,9 @@ const IoctlTermiosGetAttr = syscall.TIOCGETA
 // for Darwin as PR_SET_PDEATH_SIG is not implemented. As a consequence, child
 // processes may not be cleaned up.
 var CmdAttrs = &syscall.SysProcAttr{}
+
+// SetProcessRlimit sets both the soft and hard limit of the given resource for
+// an already running process. It is not supported on Darwin.
+func SetProcessRlimit(int, int, uint64) error {
+	return errors.New("syscall: setting resource limits of other processes is not supported")
+}
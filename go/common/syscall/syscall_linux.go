@@ -1,7 +1,11 @@
 // Package syscall defines OS-specific syscall parameters.
 package syscall
 
-import "syscall"
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
 
 // IoctlTermiosGetAttr is the ioctl that implements termios tcgetattr.
 const IoctlTermiosGetAttr = syscall.TCGETS
@@ -10,3 +14,13 @@ const IoctlTermiosGetAttr = syscall.TCGETS
 var CmdAttrs = &syscall.SysProcAttr{
 	Pdeathsig: syscall.SIGKILL,
 }
+
+// SetProcessRlimit sets both the soft and hard limit of the given resource for
+// an already running process.
+func SetProcessRlimit(pid int, resource int, limit uint64) error {
+	rlim := unix.Rlimit{
+		Cur: limit,
+		Max: limit,
+	}
+	return unix.Prlimit(pid, resource, &rlim, nil)
+}
@@ -101,6 +101,20 @@ func (kq *keymanagerQuerier) Genesis(ctx context.Context) (*keymanager.Genesis,
 		return nil, err
 	}
 
+	// Secrets are not exported, but the statuses must be consistent with them, otherwise the
+	// state is corrupted and must not be used for a network restart.
+	masterSecrets, err := kq.state.MasterSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ephemeralSecrets, err := kq.state.EphemeralSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = keymanager.SanityCheckSecrets(statuses, masterSecrets, ephemeralSecrets); err != nil {
+		return nil, err
+	}
+
 	// Remove the Nodes field of each Status.
 	for _, status := range statuses {
 		status.Nodes = nil
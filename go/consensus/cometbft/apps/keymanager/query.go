@@ -2,8 +2,11 @@ package keymanager
 
 import (
 	"context"
+	"errors"
 
+	beacon "github.com/oasisprotocol/oasis-core/go/beacon/api"
 	"github.com/oasisprotocol/oasis-core/go/common"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
 	abciAPI "github.com/oasisprotocol/oasis-core/go/consensus/cometbft/api"
 	keymanagerState "github.com/oasisprotocol/oasis-core/go/consensus/cometbft/apps/keymanager/state"
 	keymanager "github.com/oasisprotocol/oasis-core/go/keymanager/api"
@@ -13,8 +16,14 @@ import (
 type Query interface {
 	Status(context.Context, common.Namespace) (*keymanager.Status, error)
 	Statuses(context.Context) ([]*keymanager.Status, error)
+	StatusesPage(context.Context, *common.Namespace, uint64) ([]*keymanager.Status, error)
+	StatusesByID(context.Context, []common.Namespace) (map[common.Namespace]*keymanager.Status, error)
 	MasterSecret(context.Context, common.Namespace) (*keymanager.SignedEncryptedMasterSecret, error)
+	MasterSecretGenerations(context.Context) (map[common.Namespace]uint64, error)
+	MasterSecretInfo(context.Context, common.Namespace) (uint64, beacon.EpochTime, error)
 	EphemeralSecret(context.Context, common.Namespace) (*keymanager.SignedEncryptedEphemeralSecret, error)
+	EphemeralSecretAt(context.Context, common.Namespace, beacon.EpochTime) (*keymanager.SignedEncryptedEphemeralSecret, error)
+	StateDigest(context.Context, common.Namespace) (hash.Hash, error)
 	Genesis(context.Context) (*keymanager.Genesis, error)
 }
 
@@ -36,6 +45,9 @@ type keymanagerQuerier struct {
 	state *keymanagerState.ImmutableState
 }
 
+// Status returns the status of the given key manager.
+//
+// Returns ErrNoSuchStatus if the key manager has no status yet.
 func (kq *keymanagerQuerier) Status(ctx context.Context, id common.Namespace) (*keymanager.Status, error) {
 	return kq.state.Status(ctx, id)
 }
@@ -44,14 +56,71 @@ func (kq *keymanagerQuerier) Statuses(ctx context.Context) ([]*keymanager.Status
 	return kq.state.Statuses(ctx)
 }
 
+func (kq *keymanagerQuerier) StatusesPage(ctx context.Context, after *common.Namespace, limit uint64) ([]*keymanager.Status, error) {
+	return kq.state.StatusesPage(ctx, after, limit)
+}
+
+func (kq *keymanagerQuerier) StatusesByID(ctx context.Context, ids []common.Namespace) (map[common.Namespace]*keymanager.Status, error) {
+	statuses := make(map[common.Namespace]*keymanager.Status, len(ids))
+	for _, id := range ids {
+		status, err := kq.state.Status(ctx, id)
+		switch {
+		case err == nil:
+			statuses[id] = status
+		case errors.Is(err, keymanager.ErrNoSuchStatus):
+		default:
+			return nil, err
+		}
+	}
+	return statuses, nil
+}
+
 func (kq *keymanagerQuerier) MasterSecret(ctx context.Context, id common.Namespace) (*keymanager.SignedEncryptedMasterSecret, error) {
 	return kq.state.MasterSecret(ctx, id)
 }
 
+func (kq *keymanagerQuerier) MasterSecretGenerations(ctx context.Context) (map[common.Namespace]uint64, error) {
+	secrets, err := kq.state.MasterSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	generations := make(map[common.Namespace]uint64, len(secrets))
+	for _, secret := range secrets {
+		generations[secret.Secret.ID] = secret.Secret.Generation
+	}
+	return generations, nil
+}
+
+// MasterSecretInfo returns the number of accepted master secret generations of the given key
+// manager and the epoch in which the last one was accepted.
+func (kq *keymanagerQuerier) MasterSecretInfo(ctx context.Context, id common.Namespace) (uint64, beacon.EpochTime, error) {
+	status, err := kq.state.Status(ctx, id)
+	if err != nil {
+		return 0, 0, err
+	}
+	return status.NextGeneration(), status.RotationEpoch, nil
+}
+
 func (kq *keymanagerQuerier) EphemeralSecret(ctx context.Context, id common.Namespace) (*keymanager.SignedEncryptedEphemeralSecret, error) {
 	return kq.state.EphemeralSecret(ctx, id)
 }
 
+func (kq *keymanagerQuerier) EphemeralSecretAt(ctx context.Context, id common.Namespace, epoch beacon.EpochTime) (*keymanager.SignedEncryptedEphemeralSecret, error) {
+	secret, err := kq.state.EphemeralSecret(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if secret.Secret.Epoch != epoch {
+		return nil, keymanager.ErrNoSuchEphemeralSecret
+	}
+	return secret, nil
+}
+
+func (kq *keymanagerQuerier) StateDigest(ctx context.Context, id common.Namespace) (hash.Hash, error) {
+	return kq.state.StateDigest(ctx, id)
+}
+
 func (app *keymanagerApplication) QueryFactory() interface{} {
 	return &QueryFactory{app.state}
 }
@@ -1,11 +1,13 @@
 package state
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 
 	"github.com/oasisprotocol/oasis-core/go/common"
 	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
 	"github.com/oasisprotocol/oasis-core/go/common/keyformat"
 	abciAPI "github.com/oasisprotocol/oasis-core/go/consensus/cometbft/api"
 	"github.com/oasisprotocol/oasis-core/go/keymanager/api"
@@ -71,6 +73,46 @@ func (st *ImmutableState) Statuses(ctx context.Context) ([]*api.Status, error) {
 	return statuses, nil
 }
 
+// StatusesPage returns at most limit key manager statuses following the status of the key
+// manager with the given ID, starting from the first status if the ID is nil. A zero limit
+// returns all remaining statuses.
+//
+// Statuses are ordered by their storage keys, so the ID of the last returned status can be
+// used to fetch the next page.
+func (st *ImmutableState) StatusesPage(ctx context.Context, after *common.Namespace, limit uint64) ([]*api.Status, error) {
+	it := st.is.NewIterator(ctx)
+	defer it.Close()
+
+	start := statusKeyFmt.Encode()
+	if after != nil {
+		start = statusKeyFmt.Encode(after)
+	}
+
+	var statuses []*api.Status
+	for it.Seek(start); it.Valid(); it.Next() {
+		if !statusKeyFmt.Decode(it.Key()) {
+			break
+		}
+		if after != nil && bytes.Equal(it.Key(), start) {
+			continue
+		}
+		if limit > 0 && uint64(len(statuses)) >= limit {
+			break
+		}
+
+		var status api.Status
+		if err := cbor.Unmarshal(it.Value(), &status); err != nil {
+			return nil, abciAPI.UnavailableStateError(err)
+		}
+		statuses = append(statuses, &status)
+	}
+	if it.Err() != nil {
+		return nil, abciAPI.UnavailableStateError(it.Err())
+	}
+
+	return statuses, nil
+}
+
 func (st *ImmutableState) getStatusesRaw(ctx context.Context) ([][]byte, error) {
 	it := st.is.NewIterator(ctx)
 	defer it.Close()
@@ -120,6 +162,29 @@ func (st *ImmutableState) MasterSecret(ctx context.Context, id common.Namespace)
 	return &secret, nil
 }
 
+// MasterSecrets returns the latest master secrets of all key managers.
+func (st *ImmutableState) MasterSecrets(ctx context.Context) ([]*api.SignedEncryptedMasterSecret, error) {
+	it := st.is.NewIterator(ctx)
+	defer it.Close()
+
+	var secrets []*api.SignedEncryptedMasterSecret
+	for it.Seek(masterSecretKeyFmt.Encode()); it.Valid(); it.Next() {
+		if !masterSecretKeyFmt.Decode(it.Key()) {
+			break
+		}
+
+		var secret api.SignedEncryptedMasterSecret
+		if err := cbor.Unmarshal(it.Value(), &secret); err != nil {
+			return nil, abciAPI.UnavailableStateError(err)
+		}
+		secrets = append(secrets, &secret)
+	}
+	if it.Err() != nil {
+		return nil, abciAPI.UnavailableStateError(it.Err())
+	}
+	return secrets, nil
+}
+
 func (st *ImmutableState) EphemeralSecret(ctx context.Context, id common.Namespace) (*api.SignedEncryptedEphemeralSecret, error) {
 	data, err := st.is.Get(ctx, ephemeralSecretKeyFmt.Encode(&id))
 	if err != nil {
@@ -136,6 +201,55 @@ func (st *ImmutableState) EphemeralSecret(ctx context.Context, id common.Namespa
 	return &secret, nil
 }
 
+// EphemeralSecrets returns the latest ephemeral secrets of all key managers.
+func (st *ImmutableState) EphemeralSecrets(ctx context.Context) ([]*api.SignedEncryptedEphemeralSecret, error) {
+	it := st.is.NewIterator(ctx)
+	defer it.Close()
+
+	var secrets []*api.SignedEncryptedEphemeralSecret
+	for it.Seek(ephemeralSecretKeyFmt.Encode()); it.Valid(); it.Next() {
+		if !ephemeralSecretKeyFmt.Decode(it.Key()) {
+			break
+		}
+
+		var secret api.SignedEncryptedEphemeralSecret
+		if err := cbor.Unmarshal(it.Value(), &secret); err != nil {
+			return nil, abciAPI.UnavailableStateError(err)
+		}
+		secrets = append(secrets, &secret)
+	}
+	if it.Err() != nil {
+		return nil, abciAPI.UnavailableStateError(it.Err())
+	}
+	return secrets, nil
+}
+
+// StateDigest returns a digest of all of the state of the given key manager, i.e. its status
+// and its latest master and ephemeral secrets.
+//
+// Equivalent key manager states always result in the same digest.
+func (st *ImmutableState) StateDigest(ctx context.Context, id common.Namespace) (hash.Hash, error) {
+	keys := [][]byte{
+		statusKeyFmt.Encode(&id),
+		masterSecretKeyFmt.Encode(&id),
+		ephemeralSecretKeyFmt.Encode(&id),
+	}
+
+	values := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		value, err := st.is.Get(ctx, key)
+		if err != nil {
+			return hash.Hash{}, abciAPI.UnavailableStateError(err)
+		}
+		values = append(values, value)
+	}
+	if values[0] == nil {
+		return hash.Hash{}, api.ErrNoSuchStatus
+	}
+
+	return hash.NewFrom(values), nil
+}
+
 func NewImmutableState(ctx context.Context, state abciAPI.ApplicationQueryState, version int64) (*ImmutableState, error) {
 	is, err := abciAPI.NewImmutableState(ctx, state, version)
 	if err != nil {
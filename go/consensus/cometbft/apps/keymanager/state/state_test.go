@@ -11,6 +11,52 @@ import (
 	"github.com/oasisprotocol/oasis-core/go/keymanager/api"
 )
 
+func TestStatusesPage(t *testing.T) {
+	require := require.New(t)
+
+	appState := abciAPI.NewMockApplicationState(&abciAPI.MockApplicationStateConfig{})
+	ctx := appState.NewContext(abciAPI.ContextBeginBlock)
+	defer ctx.Close()
+
+	s := NewMutableState(ctx.State())
+
+	// Prepare data.
+	for i := 0; i < 5; i++ {
+		id := common.NewTestNamespaceFromSeed([]byte{byte(i)}, common.NamespaceKeyManager)
+		err := s.SetStatus(ctx, &api.Status{ID: id})
+		require.NoError(err, "SetStatus()")
+	}
+	statuses, err := s.Statuses(ctx)
+	require.NoError(err, "Statuses()")
+	require.Len(statuses, 5)
+
+	// Test paging through statuses.
+	var (
+		paged []*api.Status
+		after *common.Namespace
+	)
+	for {
+		page, err := s.StatusesPage(ctx, after, 2)
+		require.NoError(err, "StatusesPage()")
+		require.LessOrEqual(len(page), 2)
+		if len(page) == 0 {
+			break
+		}
+		paged = append(paged, page...)
+		after = &page[len(page)-1].ID
+	}
+	require.Equal(statuses, paged, "paged statuses should match all statuses")
+
+	// Test querying without a limit.
+	page, err := s.StatusesPage(ctx, nil, 0)
+	require.NoError(err, "StatusesPage()")
+	require.Equal(statuses, page)
+
+	page, err = s.StatusesPage(ctx, &statuses[1].ID, 0)
+	require.NoError(err, "StatusesPage()")
+	require.Equal(statuses[2:], page)
+}
+
 func TestMasterSecret(t *testing.T) {
 	require := require.New(t)
 
@@ -50,6 +96,11 @@ func TestMasterSecret(t *testing.T) {
 	}
 	_, err := s.MasterSecret(ctx, common.Namespace{1, 2, 3})
 	require.EqualError(err, api.ErrNoSuchMasterSecret.Error(), "MasterSecret should error for non-existing secrets")
+
+	// Test querying secrets of all key managers.
+	allSecrets, err := s.MasterSecrets(ctx)
+	require.NoError(err, "MasterSecrets()")
+	require.ElementsMatch(secrets[8:], allSecrets, "last master secrets should be returned")
 }
 
 func TestEphemeralSecret(t *testing.T) {
@@ -91,4 +142,9 @@ func TestEphemeralSecret(t *testing.T) {
 	}
 	_, err := s.EphemeralSecret(ctx, common.Namespace{1, 2, 3})
 	require.EqualError(err, api.ErrNoSuchEphemeralSecret.Error(), "EphemeralSecret should error for non-existing secrets")
+
+	// Test querying secrets of all key managers.
+	allSecrets, err := s.EphemeralSecrets(ctx)
+	require.NoError(err, "EphemeralSecrets()")
+	require.ElementsMatch(secrets[8:], allSecrets, "last ephemeral secrets should be returned")
 }
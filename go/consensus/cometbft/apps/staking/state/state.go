@@ -207,6 +207,13 @@ func (s *ImmutableState) CommissionScheduleAddresses(ctx context.Context) ([]sta
 	return addresses, nil
 }
 
+// AccountKey returns the key under which the staking account with the given address is stored.
+//
+// This can be used to verify accounts against a state root, without access to the full state.
+func AccountKey(address staking.Address) []byte {
+	return accountKeyFmt.Encode(&address)
+}
+
 // Account returns the staking account for the given account address.
 func (s *ImmutableState) Account(ctx context.Context, address staking.Address) (*staking.Account, error) {
 	if !address.IsValid() {
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	beacon "github.com/oasisprotocol/oasis-core/go/beacon/api"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
 	memorySigner "github.com/oasisprotocol/oasis-core/go/common/crypto/signature/signers/memory"
 	"github.com/oasisprotocol/oasis-core/go/common/quantity"
@@ -1034,3 +1035,26 @@ func TestCommissionScheduleAccounts(t *testing.T) {
 	require.NoError(err, "CommissionScheduleAddresses")
 	require.ElementsMatch([]staking.Address{acc1Addr, acc4Addr}, addrs, "expected addresses should be returned")
 }
+
+func TestAccountKey(t *testing.T) {
+	require := require.New(t)
+
+	appState := abciAPI.NewMockApplicationState(&abciAPI.MockApplicationStateConfig{})
+	ctx := appState.NewContext(abciAPI.ContextDeliverTx)
+	defer ctx.Close()
+
+	s := NewMutableState(ctx.State())
+	addr := staking.NewAddress(signature.NewPublicKey("aaafffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"))
+	account := &staking.Account{
+		General: staking.GeneralAccount{
+			Balance: *quantity.NewFromUint64(100),
+		},
+	}
+	err := s.SetAccount(ctx, addr, account)
+	require.NoError(err, "SetAccount")
+
+	// The account should be stored under its account key.
+	raw, err := ctx.State().Get(ctx, AccountKey(addr))
+	require.NoError(err, "Get")
+	require.Equal(cbor.Marshal(account), raw)
+}
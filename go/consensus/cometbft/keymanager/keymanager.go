@@ -4,13 +4,18 @@ package keymanager
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	cmtabcitypes "github.com/cometbft/cometbft/abci/types"
 	cmtpubsub "github.com/cometbft/cometbft/libs/pubsub"
 	cmttypes "github.com/cometbft/cometbft/types"
-	"github.com/eapache/channels"
+	"github.com/prometheus/client_golang/prometheus"
 
+	beacon "github.com/oasisprotocol/oasis-core/go/beacon/api"
+	"github.com/oasisprotocol/oasis-core/go/common"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
 	"github.com/oasisprotocol/oasis-core/go/common/logging"
 	"github.com/oasisprotocol/oasis-core/go/common/pubsub"
 	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
@@ -21,21 +26,113 @@ import (
 	registry "github.com/oasisprotocol/oasis-core/go/registry/api"
 )
 
+const (
+	// watcherBufferSize is the maximum number of notifications buffered for each watcher.
+	watcherBufferSize = 128
+
+	// bootstrapTimeout is the maximum time spent fetching the current state replayed to new
+	// watchers.
+	bootstrapTimeout = 30 * time.Second
+
+	watcherKindStatus          = "status"
+	watcherKindMasterSecret    = "master_secret"
+	watcherKindEphemeralSecret = "ephemeral_secret"
+	watcherKindEvent           = "event"
+)
+
+// MaxStatusesSinceRange is the maximum number of blocks that can be scanned by a single
+// GetStatusesSince call.
+const MaxStatusesSinceRange = 1000
+
 // ServiceClient is the registry service client interface.
 type ServiceClient interface {
 	api.Backend
 	tmapi.ServiceClient
+
+	// GetStatusesPage returns at most limit key manager statuses at the given height following
+	// the status of the key manager with the given ID, starting from the first status if the ID
+	// is nil. A zero limit returns all remaining statuses.
+	//
+	// The ID of the last returned status can be used to fetch the next page.
+	GetStatusesPage(ctx context.Context, height int64, after *common.Namespace, limit uint64) ([]*api.Status, error)
+
+	// GetStatusesFor returns the statuses of the given key managers at the given height,
+	// in the order of the given IDs.
+	GetStatusesFor(ctx context.Context, ids []common.Namespace, height int64) ([]*api.Status, error)
+
+	// GetStatusesByID returns the statuses of the given key managers at the given height.
+	//
+	// Key managers without a status are absent from the returned map.
+	GetStatusesByID(ctx context.Context, ids []common.Namespace, height int64) (map[common.Namespace]*api.Status, error)
+
+	// WaitForStatus blocks until the status of the given key manager satisfies the given
+	// condition and returns the matching status.
+	WaitForStatus(ctx context.Context, id common.Namespace, cond func(*api.Status) bool) (*api.Status, error)
+
+	// MaxMasterSecretGeneration returns the generation of the latest master secret stored
+	// for each key manager.
+	MaxMasterSecretGeneration(ctx context.Context, height int64) (map[common.Namespace]uint64, error)
+
+	// GetMasterSecretInfo returns the number of master secret generations accepted by the given
+	// key manager at the given height, and the consensus height at which the last one was
+	// accepted, or zero if there are none or the rotation predates the base epoch.
+	//
+	// This is much cheaper than fetching the master secrets themselves.
+	GetMasterSecretInfo(ctx context.Context, id common.Namespace, height int64) (generations uint64, lastRotationHeight int64, err error)
+
+	// GetStateDigest returns a digest of the state of the given key manager at the given height,
+	// which can be used to cheaply detect whether the key manager state has changed.
+	//
+	// The digest is not a Merkle root and cannot be used to verify the key manager state.
+	GetStateDigest(ctx context.Context, id common.Namespace, height int64) (hash.Hash, error)
+
+	// WatchStatusesFor returns a channel that produces a stream of statuses of the given
+	// key manager, starting with its current status.
+	WatchStatusesFor(id common.Namespace) (<-chan *api.Status, *pubsub.Subscription)
+
+	// WatchMasterSecretsFor returns a channel that produces a stream of master secrets
+	// published for the given key manager.
+	WatchMasterSecretsFor(id common.Namespace) (<-chan *api.SignedEncryptedMasterSecret, *pubsub.Subscription)
+
+	// WatchKeyManager returns a channel that produces a stream of status updates and published
+	// secrets of the given key manager, in the order in which they were emitted.
+	WatchKeyManager(id common.Namespace) (<-chan *api.Event, *pubsub.Subscription)
+
+	// GetEphemeralSecretForEpoch returns the ephemeral secret of the given key manager
+	// for the given epoch.
+	//
+	// Returns ErrEphemeralSecretPruned if the state holding the secret has been pruned.
+	GetEphemeralSecretForEpoch(ctx context.Context, id common.Namespace, epoch beacon.EpochTime) (*api.SignedEncryptedEphemeralSecret, error)
+
+	// GetStatusesSince returns the key manager status updates emitted in blocks from the given
+	// height up to and including the given height, in the order in which they were emitted.
+	// If toHeight is consensus.HeightLatest, updates up to the latest height are returned.
+	//
+	// The range must start at height 1 or above and span at most MaxStatusesSinceRange blocks.
+	// Returns ErrInvalidArgument for an invalid range and consensus.ErrVersionNotFound if
+	// the range starts below the last retained height.
+	GetStatusesSince(ctx context.Context, fromHeight, toHeight int64) ([]*api.Status, error)
 }
 
 type serviceClient struct {
 	tmapi.BaseServiceClient
 
+	ctx    context.Context
 	logger *logging.Logger
 
+	backend           tmapi.Backend
 	querier           *app.QueryFactory
 	statusNotifier    *pubsub.Broker
 	mstSecretNotifier *pubsub.Broker
 	ephSecretNotifier *pubsub.Broker
+	eventNotifier     *pubsub.Broker
+
+	// pendingHeight is the height of the last queued statuses.
+	pendingHeight int64
+	// pendingStatuses are the statuses of key managers queued for broadcast.
+	pendingStatuses map[common.Namespace]*api.Status
+	// pendingOrder is the order in which key managers were first queued for broadcast.
+	pendingOrder []common.Namespace
 }
 
 func (sc *serviceClient) GetStatus(ctx context.Context, query *registry.NamespaceQuery) (*api.Status, error) {
@@ -56,12 +153,205 @@ func (sc *serviceClient) GetStatuses(ctx context.Context, height int64) ([]*api.
 	return q.Statuses(ctx)
 }
 
+func (sc *serviceClient) GetStatusesPage(ctx context.Context, height int64, after *common.Namespace, limit uint64) ([]*api.Status, error) {
+	q, err := sc.querier.QueryAt(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.StatusesPage(ctx, after, limit)
+}
+
+func (sc *serviceClient) GetStatusesFor(ctx context.Context, ids []common.Namespace, height int64) ([]*api.Status, error) {
+	q, err := sc.querier.QueryAt(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*api.Status, 0, len(ids))
+	for _, id := range ids {
+		status, err := q.Status(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get status of key manager %s: %w", id, err)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (sc *serviceClient) GetStatusesByID(ctx context.Context, ids []common.Namespace, height int64) (map[common.Namespace]*api.Status, error) {
+	q, err := sc.querier.QueryAt(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.StatusesByID(ctx, ids)
+}
+
+// WatchStatuses returns a channel that produces a stream of key manager statuses.
+//
+// New watchers first receive the current statuses of all key managers, followed by live status
+// updates in the order in which they were broadcasted. Live updates received while the current
+// statuses are being fetched are held back until those have been delivered, so a held back
+// update may be older than the current status delivered before it, but the last status
+// delivered for each key manager is always the most recent one. If the current statuses cannot
+// be fetched, watchers only receive live updates.
 func (sc *serviceClient) WatchStatuses() (<-chan *api.Status, *pubsub.Subscription) {
-	sub := sc.statusNotifier.Subscribe()
-	ch := make(chan *api.Status)
-	sub.Unwrap(ch)
+	return watchBounded(sc.logger, sc.statusNotifier, watcherKindStatus, nil, sc.bootstrapStatuses)
+}
 
-	return ch, sub
+// WatchStatusesFor returns a channel that produces a stream of statuses of the given key
+// manager, with the same ordering guarantees as WatchStatuses.
+//
+// Only the current status of the given key manager is fetched for new watchers.
+func (sc *serviceClient) WatchStatusesFor(id common.Namespace) (<-chan *api.Status, *pubsub.Subscription) {
+	filter := func(status *api.Status) bool {
+		return status.ID.Equal(&id)
+	}
+	bootstrap := func() []*api.Status {
+		return sc.bootstrapStatus(id)
+	}
+	return watchBounded(sc.logger, sc.statusNotifier, watcherKindStatus, filter, bootstrap)
+}
+
+// bootstrapStatuses returns the current statuses of all key managers, which are delivered to
+// new status watchers before any live updates.
+func (sc *serviceClient) bootstrapStatuses() []*api.Status {
+	ctx, cancel := context.WithTimeout(sc.ctx, bootstrapTimeout)
+	defer cancel()
+
+	statuses, err := sc.GetStatuses(ctx, consensus.HeightLatest)
+	if err != nil {
+		sc.logger.Error("status notifier: unable to get a list of statuses, only live updates will be delivered",
+			"err", err,
+		)
+		return nil
+	}
+	return statuses
+}
+
+// bootstrapStatus returns the current status of the given key manager, if any, which is
+// delivered to new status watchers of that key manager before any live updates.
+func (sc *serviceClient) bootstrapStatus(id common.Namespace) []*api.Status {
+	ctx, cancel := context.WithTimeout(sc.ctx, bootstrapTimeout)
+	defer cancel()
+
+	status, err := sc.GetStatus(ctx, &registry.NamespaceQuery{
+		Height: consensus.HeightLatest,
+		ID:     id,
+	})
+	switch {
+	case err == nil:
+		return []*api.Status{status}
+	case errors.Is(err, api.ErrNoSuchStatus):
+		// Key manager not initialized yet.
+		return nil
+	default:
+		sc.logger.Error("status notifier: unable to get status, only live updates will be delivered",
+			"err", err,
+			"id", id,
+		)
+		return nil
+	}
+}
+
+func (sc *serviceClient) WaitForStatus(ctx context.Context, id common.Namespace, cond func(*api.Status) bool) (*api.Status, error) {
+	// Status watchers first receive the current status, so a condition which is already met
+	// is detected without waiting for the next status update.
+	ch, sub := sc.WatchStatusesFor(id)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case status := <-ch:
+			if cond(status) {
+				return status, nil
+			}
+		}
+	}
+}
+
+func (sc *serviceClient) GetStatusesSince(ctx context.Context, fromHeight, toHeight int64) ([]*api.Status, error) {
+	if fromHeight < 1 {
+		return nil, fmt.Errorf("%w: invalid from height: %d", api.ErrInvalidArgument, fromHeight)
+	}
+
+	blk, err := sc.backend.GetBlock(ctx, consensus.HeightLatest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest block: %w", err)
+	}
+	if toHeight == consensus.HeightLatest {
+		toHeight = blk.Height
+	}
+	switch {
+	case toHeight < fromHeight:
+		return nil, fmt.Errorf("%w: to height %d below from height %d", api.ErrInvalidArgument, toHeight, fromHeight)
+	case toHeight > blk.Height:
+		return nil, fmt.Errorf("%w: to height %d above latest height %d", api.ErrInvalidArgument, toHeight, blk.Height)
+	case toHeight-fromHeight >= MaxStatusesSinceRange:
+		return nil, fmt.Errorf("%w: range of %d blocks exceeds maximum of %d", api.ErrInvalidArgument, toHeight-fromHeight+1, MaxStatusesSinceRange)
+	}
+
+	lastRetained, err := sc.backend.GetLastRetainedVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last retained height: %w", err)
+	}
+	if fromHeight < lastRetained {
+		return nil, fmt.Errorf("%w: from height %d is below last retained height %d", consensus.ErrVersionNotFound, fromHeight, lastRetained)
+	}
+
+	var statuses []*api.Status
+	for height := fromHeight; height <= toHeight; height++ {
+		results, err := sc.backend.GetBlockResults(ctx, height)
+		if err != nil {
+			sc.logger.Error("failed to get cometbft block results",
+				"err", err,
+				"height", height,
+			)
+			return nil, err
+		}
+
+		// Status updates can be emitted at the beginning of the block, by transactions and at
+		// the end of the block.
+		evs := append([]cmtabcitypes.Event{}, results.BeginBlockEvents...)
+		for _, txResult := range results.TxsResults {
+			evs = append(evs, txResult.Events...)
+		}
+		evs = append(evs, results.EndBlockEvents...)
+
+		for _, ev := range evs {
+			if ev.Type != app.EventType {
+				continue
+			}
+			for _, pair := range ev.GetAttributes() {
+				event, err := decodeStatusUpdateEvent(pair)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode status update event at height %d: %w", height, err)
+				}
+				if event != nil {
+					statuses = append(statuses, event.Statuses...)
+				}
+			}
+		}
+	}
+
+	return statuses, nil
+}
+
+// decodeStatusUpdateEvent decodes the given event attribute into a status update event. If the
+// attribute is not a status update event, nil is returned.
+func decodeStatusUpdateEvent(pair cmtabcitypes.EventAttribute) (*api.StatusUpdateEvent, error) {
+	if !events.IsAttributeKind(pair.GetKey(), &api.StatusUpdateEvent{}) {
+		return nil, nil
+	}
+
+	var event api.StatusUpdateEvent
+	if err := events.DecodeValue(pair.GetValue(), &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
 }
 
 func (sc *serviceClient) StateToGenesis(ctx context.Context, height int64) (*api.Genesis, error) {
@@ -82,6 +372,52 @@ func (sc *serviceClient) GetMasterSecret(ctx context.Context, query *registry.Na
 	return q.MasterSecret(ctx, query.ID)
 }
 
+func (sc *serviceClient) MaxMasterSecretGeneration(ctx context.Context, height int64) (map[common.Namespace]uint64, error) {
+	q, err := sc.querier.QueryAt(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.MasterSecretGenerations(ctx)
+}
+
+func (sc *serviceClient) GetMasterSecretInfo(ctx context.Context, id common.Namespace, height int64) (uint64, int64, error) {
+	q, err := sc.querier.QueryAt(ctx, height)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	generations, rotationEpoch, err := q.MasterSecretInfo(ctx, id)
+	if err != nil || generations == 0 {
+		return generations, 0, err
+	}
+
+	// Master secret generations are accepted at epoch transitions, so the rotation happened
+	// in the first block of the rotation epoch.
+	baseEpoch, err := sc.backend.Beacon().GetBaseEpoch(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get base epoch: %w", err)
+	}
+	if rotationEpoch < baseEpoch {
+		// The rotation happened before the network was restored from genesis.
+		return generations, 0, nil
+	}
+	rotationHeight, err := sc.backend.Beacon().GetEpochBlock(ctx, rotationEpoch)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get epoch block: %w", err)
+	}
+	return generations, rotationHeight, nil
+}
+
+func (sc *serviceClient) GetStateDigest(ctx context.Context, id common.Namespace, height int64) (hash.Hash, error) {
+	q, err := sc.querier.QueryAt(ctx, height)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+
+	return q.StateDigest(ctx, id)
+}
+
 func (sc *serviceClient) GetEphemeralSecret(ctx context.Context, query *registry.NamespaceQuery) (*api.SignedEncryptedEphemeralSecret, error) {
 	q, err := sc.querier.QueryAt(ctx, query.Height)
 	if err != nil {
@@ -91,18 +427,267 @@ func (sc *serviceClient) GetEphemeralSecret(ctx context.Context, query *registry
 	return q.EphemeralSecret(ctx, query.ID)
 }
 
+func (sc *serviceClient) GetEphemeralSecretForEpoch(ctx context.Context, id common.Namespace, epoch beacon.EpochTime) (*api.SignedEncryptedEphemeralSecret, error) {
+	// The ephemeral secret for an epoch is usually published during the preceding epoch, but
+	// it may also be published during the epoch itself, and the secret for the next epoch may
+	// replace it as early as in the first block of the epoch. Search all heights from the last
+	// block of the preceding epoch up to the last block of the epoch.
+	startHeight, err := sc.backend.Beacon().GetEpochBlock(ctx, epoch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get epoch block: %w", err)
+	}
+
+	blk, err := sc.backend.GetBlock(ctx, consensus.HeightLatest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest block: %w", err)
+	}
+	endHeight := blk.Height
+	if nextHeight, err := sc.backend.Beacon().GetEpochBlock(ctx, epoch+1); err == nil {
+		endHeight = nextHeight - 1
+	}
+
+	lastRetained, err := sc.backend.GetLastRetainedVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last retained height: %w", err)
+	}
+	if startHeight < lastRetained {
+		return nil, api.ErrEphemeralSecretPruned
+	}
+
+	secretAt := func(ctx context.Context, height int64) (*api.SignedEncryptedEphemeralSecret, error) {
+		q, err := sc.querier.QueryAt(ctx, height)
+		switch {
+		case err == nil:
+		case errors.Is(err, consensus.ErrVersionNotFound):
+			return nil, api.ErrEphemeralSecretPruned
+		default:
+			return nil, err
+		}
+		return q.EphemeralSecret(ctx, id)
+	}
+
+	return searchEphemeralSecret(ctx, epoch, max(startHeight-1, lastRetained, 1), endHeight, secretAt)
+}
+
+// searchEphemeralSecret returns the ephemeral secret for the given epoch, which was the latest
+// ephemeral secret at some height in the given range.
+//
+// As ephemeral secrets are published in epoch order, the search is done by bisecting the range
+// for the first height at which the latest secret is for the given epoch or a later one.
+func searchEphemeralSecret(
+	ctx context.Context,
+	epoch beacon.EpochTime,
+	fromHeight int64,
+	toHeight int64,
+	secretAt func(context.Context, int64) (*api.SignedEncryptedEphemeralSecret, error),
+) (*api.SignedEncryptedEphemeralSecret, error) {
+	var found *api.SignedEncryptedEphemeralSecret
+	for fromHeight <= toHeight {
+		height := fromHeight + (toHeight-fromHeight)/2
+
+		secret, err := secretAt(ctx, height)
+		switch {
+		case err == nil:
+		case errors.Is(err, api.ErrNoSuchEphemeralSecret):
+			secret = nil
+		default:
+			return nil, err
+		}
+
+		if secret != nil && secret.Secret.Epoch >= epoch {
+			found = secret
+			toHeight = height - 1
+		} else {
+			fromHeight = height + 1
+		}
+	}
+
+	if found == nil || found.Secret.Epoch != epoch {
+		return nil, api.ErrNoSuchEphemeralSecret
+	}
+	return found, nil
+}
+
 func (sc *serviceClient) WatchMasterSecrets() (<-chan *api.SignedEncryptedMasterSecret, *pubsub.Subscription) {
-	sub := sc.mstSecretNotifier.Subscribe()
-	ch := make(chan *api.SignedEncryptedMasterSecret)
-	sub.Unwrap(ch)
+	bootstrap := func() []*api.SignedEncryptedMasterSecret {
+		return bootstrapSecrets(sc, watcherKindMasterSecret, nil, sc.GetMasterSecret, api.ErrNoSuchMasterSecret)
+	}
+	return watchBounded(sc.logger, sc.mstSecretNotifier, watcherKindMasterSecret, nil, bootstrap)
+}
 
-	return ch, sub
+func (sc *serviceClient) WatchMasterSecretsFor(id common.Namespace) (<-chan *api.SignedEncryptedMasterSecret, *pubsub.Subscription) {
+	filter := func(secret *api.SignedEncryptedMasterSecret) bool {
+		return secret.Secret.ID.Equal(&id)
+	}
+	bootstrap := func() []*api.SignedEncryptedMasterSecret {
+		return bootstrapSecrets(sc, watcherKindMasterSecret, &id, sc.GetMasterSecret, api.ErrNoSuchMasterSecret)
+	}
+	return watchBounded(sc.logger, sc.mstSecretNotifier, watcherKindMasterSecret, filter, bootstrap)
 }
 
 func (sc *serviceClient) WatchEphemeralSecrets() (<-chan *api.SignedEncryptedEphemeralSecret, *pubsub.Subscription) {
-	sub := sc.ephSecretNotifier.Subscribe()
-	ch := make(chan *api.SignedEncryptedEphemeralSecret)
-	sub.Unwrap(ch)
+	bootstrap := func() []*api.SignedEncryptedEphemeralSecret {
+		return bootstrapSecrets(sc, watcherKindEphemeralSecret, nil, sc.GetEphemeralSecret, api.ErrNoSuchEphemeralSecret)
+	}
+	return watchBounded(sc.logger, sc.ephSecretNotifier, watcherKindEphemeralSecret, nil, bootstrap)
+}
+
+// WatchKeyManager returns a channel that produces a stream of status updates and published
+// secrets of the given key manager, in the order in which they were emitted.
+//
+// New watchers first receive the current status and the latest secrets of the key manager,
+// with the same ordering guarantees as WatchStatuses.
+func (sc *serviceClient) WatchKeyManager(id common.Namespace) (<-chan *api.Event, *pubsub.Subscription) {
+	filter := func(ev *api.Event) bool {
+		evID := ev.ID()
+		return evID.Equal(&id)
+	}
+	bootstrap := func() []*api.Event {
+		return sc.bootstrapKeyManager(id)
+	}
+	return watchBounded(sc.logger, sc.eventNotifier, watcherKindEvent, filter, bootstrap)
+}
+
+// bootstrapKeyManager returns the current status and the latest secrets of the given key
+// manager, which are delivered to new key manager watchers before any live events.
+func (sc *serviceClient) bootstrapKeyManager(id common.Namespace) []*api.Event {
+	ctx, cancel := context.WithTimeout(sc.ctx, bootstrapTimeout)
+	defer cancel()
+
+	query := &registry.NamespaceQuery{
+		Height: consensus.HeightLatest,
+		ID:     id,
+	}
+	var evs []*api.Event
+
+	status, err := sc.GetStatus(ctx, query)
+	switch {
+	case err == nil:
+		evs = append(evs, &api.Event{Status: status})
+	case errors.Is(err, api.ErrNoSuchStatus):
+		// Key manager not initialized yet, so there are no secrets either.
+		return nil
+	default:
+		sc.logger.Error("event notifier: unable to get status, only live events will be delivered",
+			"err", err,
+			"id", id,
+		)
+		return nil
+	}
+
+	mstSecret, err := sc.GetMasterSecret(ctx, query)
+	switch {
+	case err == nil:
+		evs = append(evs, &api.Event{MasterSecret: mstSecret})
+	case errors.Is(err, api.ErrNoSuchMasterSecret):
+	default:
+		sc.logger.Error("event notifier: unable to get the latest master secret",
+			"err", err,
+			"id", id,
+		)
+	}
+
+	ephSecret, err := sc.GetEphemeralSecret(ctx, query)
+	switch {
+	case err == nil:
+		evs = append(evs, &api.Event{EphemeralSecret: ephSecret})
+	case errors.Is(err, api.ErrNoSuchEphemeralSecret):
+	default:
+		sc.logger.Error("event notifier: unable to get the latest ephemeral secret",
+			"err", err,
+			"id", id,
+		)
+	}
+
+	return evs
+}
+
+// watchBounded subscribes to the given broker and forwards broadcasted values to the returned
+// channel via a bounded buffer. When the subscriber falls behind, the oldest buffered value is
+// dropped so that a slow subscriber can never back up the notification path. Drops are counted
+// and logged once each time the subscriber starts lagging.
+//
+// If a filter is given, only values for which it returns true are forwarded.
+//
+// If a bootstrap function is given, it is called in its own goroutine and the values it returns
+// are forwarded before any broadcasted values, which are buffered in the meantime. This way
+// neither the broker nor the subscriber is blocked while the bootstrap values are fetched.
+func watchBounded[T any](logger *logging.Logger, broker *pubsub.Broker, kind string, filter func(T) bool, bootstrap func() []T) (<-chan T, *pubsub.Subscription) {
+	sub := broker.Subscribe()
+	ch := make(chan T)
+
+	var bootstrapCh chan []T
+	if bootstrap != nil {
+		bootstrapCh = make(chan []T, 1)
+		go func() {
+			bootstrapCh <- bootstrap()
+		}()
+	}
+
+	go func() {
+		defer close(ch)
+
+		in := sub.Untyped()
+		var (
+			pending []T
+			lagging bool
+		)
+		buffer := make([]T, 0, watcherBufferSize)
+		for {
+			var (
+				out  chan T
+				next T
+			)
+			switch {
+			case bootstrapCh != nil:
+				// Wait for the bootstrap values before forwarding anything.
+			case len(pending) > 0:
+				out = ch
+				next = pending[0]
+			case len(buffer) > 0:
+				out = ch
+				next = buffer[0]
+			}
+
+			select {
+			case values := <-bootstrapCh:
+				bootstrapCh = nil
+				for _, v := range values {
+					if filter != nil && !filter(v) {
+						continue
+					}
+					pending = append(pending, v)
+				}
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				if filter != nil && !filter(v.(T)) {
+					continue
+				}
+				if len(buffer) >= watcherBufferSize {
+					buffer = buffer[1:]
+					watcherDroppedMessages.With(prometheus.Labels{"kind": kind}).Inc()
+					if !lagging {
+						logger.Warn("watcher is lagging, dropping oldest notifications",
+							"kind", kind,
+						)
+						lagging = true
+					}
+				}
+				buffer = append(buffer, v.(T))
+			case out <- next:
+				if len(pending) > 0 {
+					pending = pending[1:]
+				} else {
+					buffer = buffer[1:]
+				}
+				if len(buffer) == 0 {
+					lagging = false
+				}
+			}
+		}
+	}()
 
 	return ch, sub
 }
@@ -113,20 +698,32 @@ func (sc *serviceClient) ServiceDescriptor() tmapi.ServiceDescriptor {
 }
 
 // Implements api.ServiceClient.
-func (sc *serviceClient) DeliverEvent(_ context.Context, _ int64, _ cmttypes.Tx, ev *cmtabcitypes.Event) error {
-	for _, pair := range ev.GetAttributes() {
-		if events.IsAttributeKind(pair.GetKey(), &api.StatusUpdateEvent{}) {
-			var event api.StatusUpdateEvent
-			if err := events.DecodeValue(pair.GetValue(), &event); err != nil {
-				sc.logger.Error("worker: failed to get statuses from tag",
-					"err", err,
-				)
-				continue
-			}
+func (sc *serviceClient) DeliverBlock(_ context.Context, height int64) error {
+	// Blocks and events are not delivered in order, so only statuses queued at earlier heights
+	// are known to be final.
+	if sc.pendingHeight < height {
+		sc.broadcastStatuses()
+	}
+	return nil
+}
 
-			for _, status := range event.Statuses {
-				sc.statusNotifier.Broadcast(status)
-			}
+// Implements api.ServiceClient.
+func (sc *serviceClient) DeliverEvent(_ context.Context, height int64, _ cmttypes.Tx, ev *cmtabcitypes.Event) error {
+	// Events are delivered in order, so statuses queued at earlier heights are final.
+	if sc.pendingHeight < height {
+		sc.broadcastStatuses()
+	}
+
+	for _, pair := range ev.GetAttributes() {
+		event, err := decodeStatusUpdateEvent(pair)
+		if err != nil {
+			sc.logger.Error("worker: failed to get statuses from tag",
+				"err", err,
+			)
+			continue
+		}
+		if event != nil {
+			sc.queueStatuses(height, event.Statuses)
 		}
 		if events.IsAttributeKind(pair.GetKey(), &api.MasterSecretPublishedEvent{}) {
 			var event api.MasterSecretPublishedEvent
@@ -137,7 +734,9 @@ func (sc *serviceClient) DeliverEvent(_ context.Context, _ int64, _ cmttypes.Tx,
 				continue
 			}
 
+			observeMasterSecret(event.Secret)
 			sc.mstSecretNotifier.Broadcast(event.Secret)
+			sc.eventNotifier.Broadcast(&api.Event{MasterSecret: event.Secret})
 		}
 		if events.IsAttributeKind(pair.GetKey(), &api.EphemeralSecretPublishedEvent{}) {
 			var event api.EphemeralSecretPublishedEvent
@@ -148,12 +747,93 @@ func (sc *serviceClient) DeliverEvent(_ context.Context, _ int64, _ cmttypes.Tx,
 				continue
 			}
 
+			observeEphemeralSecret(event.Secret)
 			sc.ephSecretNotifier.Broadcast(event.Secret)
+			sc.eventNotifier.Broadcast(&api.Event{EphemeralSecret: event.Secret})
 		}
 	}
 	return nil
 }
 
+// bootstrapSecrets returns the latest secrets of the given key manager, or of all known key
+// managers if no ID is given, which are delivered to new secret watchers before any live updates.
+func bootstrapSecrets[T any](
+	sc *serviceClient,
+	kind string,
+	id *common.Namespace,
+	getSecret func(context.Context, *registry.NamespaceQuery) (T, error),
+	errNoSuchSecret error,
+) []T {
+	ctx, cancel := context.WithTimeout(sc.ctx, bootstrapTimeout)
+	defer cancel()
+
+	var ids []common.Namespace
+	switch id {
+	case nil:
+		statuses, err := sc.GetStatuses(ctx, consensus.HeightLatest)
+		if err != nil {
+			sc.logger.Error("secret notifier: unable to get a list of statuses, only live updates will be delivered",
+				"err", err,
+				"kind", kind,
+			)
+			return nil
+		}
+		for _, status := range statuses {
+			ids = append(ids, status.ID)
+		}
+	default:
+		ids = []common.Namespace{*id}
+	}
+
+	var secrets []T
+	for _, id := range ids {
+		secret, err := getSecret(ctx, &registry.NamespaceQuery{
+			Height: consensus.HeightLatest,
+			ID:     id,
+		})
+		switch {
+		case err == nil:
+			secrets = append(secrets, secret)
+		case errors.Is(err, errNoSuchSecret):
+			// No secret published yet.
+		default:
+			sc.logger.Error("secret notifier: unable to get the latest secret",
+				"err", err,
+				"kind", kind,
+				"id", id,
+			)
+		}
+	}
+	return secrets
+}
+
+// queueStatuses queues the given statuses emitted at the given height for broadcast.
+//
+// Statuses are coalesced per block, so that only the final status of each key manager
+// in the block is broadcasted once the block is complete.
+func (sc *serviceClient) queueStatuses(height int64, statuses []*api.Status) {
+	sc.pendingHeight = height
+
+	for _, status := range statuses {
+		if _, ok := sc.pendingStatuses[status.ID]; !ok {
+			sc.pendingOrder = append(sc.pendingOrder, status.ID)
+		}
+		sc.pendingStatuses[status.ID] = status
+	}
+}
+
+// broadcastStatuses broadcasts all queued statuses.
+func (sc *serviceClient) broadcastStatuses() {
+	for _, id := range sc.pendingOrder {
+		status := sc.pendingStatuses[id]
+		sc.statusNotifier.Broadcast(status)
+		sc.eventNotifier.Broadcast(&api.Event{Status: status})
+	}
+
+	sc.pendingOrder = nil
+	clear(sc.pendingStatuses)
+}
+
 // New constructs a new CometBFT backed key manager management Backend
 // instance.
 func New(ctx context.Context, backend tmapi.Backend) (ServiceClient, error) {
@@ -162,26 +842,25 @@ func New(ctx context.Context, backend tmapi.Backend) (ServiceClient, error) {
 		return nil, fmt.Errorf("cometbft/keymanager: failed to register app: %w", err)
 	}
 
-	sc := serviceClient{
-		logger:            logging.GetLogger("cometbft/keymanager"),
-		querier:           a.QueryFactory().(*app.QueryFactory),
-		mstSecretNotifier: pubsub.NewBroker(false),
-		ephSecretNotifier: pubsub.NewBroker(false),
-	}
-	sc.statusNotifier = pubsub.NewBrokerEx(func(ch channels.Channel) {
-		statuses, err := sc.GetStatuses(ctx, consensus.HeightLatest)
-		if err != nil {
-			sc.logger.Error("status notifier: unable to get a list of statuses",
-				"err", err,
-			)
-			return
-		}
+	return newServiceClient(ctx, backend, a.QueryFactory().(*app.QueryFactory)), nil
+}
 
-		wr := ch.In()
-		for _, v := range statuses {
-			wr <- v
-		}
-	})
+func newServiceClient(ctx context.Context, backend tmapi.Backend, querier *app.QueryFactory) *serviceClient {
+	initMetrics()
 
-	return &sc, nil
+	sc := serviceClient{
+		ctx:             ctx,
+		logger:          logging.GetLogger("cometbft/keymanager"),
+		backend:         backend,
+		querier:         querier,
+		pendingStatuses: make(map[common.Namespace]*api.Status),
+	}
+	// Brokers deliver to subscribers via unbounded channels and all bootstrapping happens in the
+	// watchers, so broadcasting from DeliverEvent never blocks on a slow watcher.
+	sc.mstSecretNotifier = pubsub.NewBroker(false)
+	sc.ephSecretNotifier = pubsub.NewBroker(false)
+	sc.statusNotifier = pubsub.NewBroker(false)
+	sc.eventNotifier = pubsub.NewBroker(false)
+
+	return &sc
 }
@@ -0,0 +1,955 @@
+package keymanager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	cmtabcitypes "github.com/cometbft/cometbft/abci/types"
+	cmtrpctypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	beacon "github.com/oasisprotocol/oasis-core/go/beacon/api"
+	"github.com/oasisprotocol/oasis-core/go/common"
+	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/events"
+	abciAPI "github.com/oasisprotocol/oasis-core/go/consensus/cometbft/api"
+	app "github.com/oasisprotocol/oasis-core/go/consensus/cometbft/apps/keymanager"
+	keymanagerState "github.com/oasisprotocol/oasis-core/go/consensus/cometbft/apps/keymanager/state"
+	"github.com/oasisprotocol/oasis-core/go/keymanager/api"
+	registry "github.com/oasisprotocol/oasis-core/go/registry/api"
+)
+
+var (
+	testRuntime1 = common.NewTestNamespaceFromSeed([]byte("runtime 1"), common.NamespaceKeyManager)
+	testRuntime2 = common.NewTestNamespaceFromSeed([]byte("runtime 2"), common.NamespaceKeyManager)
+)
+
+func newTestServiceClient(t *testing.T) (*serviceClient, *abciAPI.Context, *keymanagerState.MutableState) {
+	appState := abciAPI.NewMockApplicationState(&abciAPI.MockApplicationStateConfig{})
+	// Queries made from the init chain context are served from the context state.
+	ctx := appState.NewContext(abciAPI.ContextInitChain)
+	t.Cleanup(ctx.Close)
+
+	state := keymanagerState.NewMutableState(ctx.State())
+	sc := newServiceClient(ctx, nil, app.NewQueryFactory(appState))
+
+	return sc, ctx, state
+}
+
+func TestWaitForStatus(t *testing.T) {
+	sc, ctx, state := newTestServiceClient(t)
+
+	err := state.SetStatus(ctx, &api.Status{ID: testRuntime1, IsInitialized: true})
+	require.NoError(t, err, "SetStatus")
+	err = state.SetStatus(ctx, &api.Status{ID: testRuntime2})
+	require.NoError(t, err, "SetStatus")
+
+	t.Run("Condition already met", func(t *testing.T) {
+		require := require.New(t)
+
+		waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		status, err := sc.WaitForStatus(waitCtx, testRuntime1, func(s *api.Status) bool {
+			return s.IsInitialized
+		})
+		require.NoError(err, "WaitForStatus")
+		require.Equal(testRuntime1, status.ID)
+	})
+
+	t.Run("Condition met later", func(t *testing.T) {
+		require := require.New(t)
+
+		waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			// Updates for other key managers must be ignored.
+			sc.statusNotifier.Broadcast(&api.Status{ID: testRuntime1, Generation: 2})
+			sc.statusNotifier.Broadcast(&api.Status{ID: testRuntime2, Generation: 1})
+			sc.statusNotifier.Broadcast(&api.Status{ID: testRuntime2, Generation: 2})
+		}()
+
+		status, err := sc.WaitForStatus(waitCtx, testRuntime2, func(s *api.Status) bool {
+			return s.Generation >= 2
+		})
+		require.NoError(err, "WaitForStatus")
+		require.Equal(testRuntime2, status.ID)
+		require.EqualValues(2, status.Generation)
+	})
+
+	t.Run("Context expires", func(t *testing.T) {
+		require := require.New(t)
+
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		_, err := sc.WaitForStatus(waitCtx, testRuntime2, func(s *api.Status) bool {
+			return s.IsInitialized
+		})
+		require.ErrorIs(err, context.DeadlineExceeded)
+	})
+}
+
+func TestWatchBounded(t *testing.T) {
+	require := require.New(t)
+
+	sc, _, _ := newTestServiceClient(t)
+	dropped := watcherDroppedMessages.With(prometheus.Labels{"kind": watcherKindMasterSecret})
+	droppedBefore := testutil.ToFloat64(dropped)
+
+	// The stalled watcher never reads from its channel.
+	_, stalledSub := sc.WatchMasterSecrets()
+	defer stalledSub.Close()
+
+	ch, sub := sc.WatchMasterSecrets()
+	defer sub.Close()
+
+	n := watcherBufferSize + 10
+	for i := 0; i < n; i++ {
+		sc.mstSecretNotifier.Broadcast(&api.SignedEncryptedMasterSecret{
+			Secret: api.EncryptedMasterSecret{
+				ID:         testRuntime1,
+				Generation: uint64(i),
+			},
+		})
+
+		// Other watchers must still receive all updates.
+		select {
+		case secret := <-ch:
+			require.EqualValues(i, secret.Secret.Generation)
+		case <-time.After(time.Second):
+			t.Fatalf("failed to receive master secret %d", i)
+		}
+	}
+
+	// Drops of the stalled watcher must be counted.
+	require.Eventually(func() bool {
+		return testutil.ToFloat64(dropped)-droppedBefore == float64(n-watcherBufferSize)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDeliverEventStalledWatchers(t *testing.T) {
+	sc, _, _ := newTestServiceClient(t)
+
+	// None of the stalled watchers ever read from their channels.
+	_, mstSub := sc.WatchMasterSecrets()
+	defer mstSub.Close()
+	_, ephSub := sc.WatchEphemeralSecrets()
+	defer ephSub.Close()
+	_, stSub := sc.WatchStatuses()
+	defer stSub.Close()
+	_, evSub := sc.WatchKeyManager(testRuntime1)
+	defer evSub.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 2*watcherBufferSize; i++ {
+			for _, ev := range []events.TypedAttribute{
+				&api.StatusUpdateEvent{
+					Statuses: []*api.Status{{ID: testRuntime1, Generation: uint64(i)}},
+				},
+				&api.MasterSecretPublishedEvent{
+					Secret: &api.SignedEncryptedMasterSecret{Secret: api.EncryptedMasterSecret{ID: testRuntime1, Generation: uint64(i)}},
+				},
+				&api.EphemeralSecretPublishedEvent{
+					Secret: &api.SignedEncryptedEphemeralSecret{Secret: api.EncryptedEphemeralSecret{ID: testRuntime1, Epoch: beacon.EpochTime(i)}},
+				},
+			} {
+				cmtEv := abciAPI.NewEventBuilder(app.AppName).TypedAttribute(ev).Event()
+				_ = sc.DeliverEvent(context.Background(), int64(i), nil, &cmtEv)
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("DeliverEvent blocked by stalled watchers")
+	}
+}
+
+func TestWatchBoundedBootstrap(t *testing.T) {
+	sc, _, _ := newTestServiceClient(t)
+
+	recv := func(t *testing.T, ch <-chan *api.Status) []uint64 {
+		var generations []uint64
+		for {
+			select {
+			case status := <-ch:
+				generations = append(generations, status.Generation)
+			case <-time.After(100 * time.Millisecond):
+				return generations
+			}
+		}
+	}
+
+	t.Run("Bootstrap before live updates", func(t *testing.T) {
+		require := require.New(t)
+
+		release := make(chan struct{})
+		bootstrap := func() []*api.Status {
+			<-release
+			return []*api.Status{
+				{ID: testRuntime1, Generation: 1},
+				{ID: testRuntime2, Generation: 2},
+			}
+		}
+		filter := func(status *api.Status) bool {
+			return status.ID.Equal(&testRuntime1)
+		}
+
+		ch, sub := watchBounded(sc.logger, sc.statusNotifier, watcherKindStatus, filter, bootstrap)
+		defer sub.Close()
+
+		// A slow bootstrap must not block the broker.
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			otherSub := sc.statusNotifier.Subscribe()
+			defer otherSub.Close()
+			sc.statusNotifier.Broadcast(&api.Status{ID: testRuntime1, Generation: 3})
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("broker blocked by bootstrap")
+		}
+
+		// Live updates must be held back until the bootstrap completes.
+		require.Empty(recv(t, ch))
+		close(release)
+		require.Equal([]uint64{1, 3}, recv(t, ch))
+	})
+
+	t.Run("Bootstrap fails", func(t *testing.T) {
+		require := require.New(t)
+
+		ch, sub := watchBounded(sc.logger, sc.statusNotifier, watcherKindStatus, nil, func() []*api.Status {
+			return nil
+		})
+		defer sub.Close()
+
+		// Live updates must be delivered even without the bootstrap values.
+		sc.statusNotifier.Broadcast(&api.Status{ID: testRuntime1, Generation: 4})
+		require.Equal([]uint64{4}, recv(t, ch))
+	})
+}
+
+func TestWatchStatusesBootstrap(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+
+	err := state.SetStatus(ctx, &api.Status{ID: testRuntime1, Generation: 1})
+	require.NoError(err, "SetStatus")
+
+	ch, sub := sc.WatchStatuses()
+	defer sub.Close()
+
+	sc.statusNotifier.Broadcast(&api.Status{ID: testRuntime1, Generation: 2})
+
+	// Current statuses must be delivered before live updates.
+	for _, generation := range []uint64{1, 2} {
+		select {
+		case status := <-ch:
+			require.Equal(generation, status.Generation)
+		case <-time.After(time.Second):
+			t.Fatalf("failed to receive status %d", generation)
+		}
+	}
+}
+
+func TestWatchStatusesFor(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+
+	for _, status := range []*api.Status{
+		{ID: testRuntime1, Generation: 1},
+		{ID: testRuntime2, Generation: 2},
+	} {
+		err := state.SetStatus(ctx, status)
+		require.NoError(err, "SetStatus")
+	}
+
+	ch, sub := sc.WatchStatusesFor(testRuntime2)
+	defer sub.Close()
+
+	sc.statusNotifier.Broadcast(&api.Status{ID: testRuntime1, Generation: 3})
+	sc.statusNotifier.Broadcast(&api.Status{ID: testRuntime2, Generation: 4})
+
+	// Only the current and live statuses of the given key manager should be received.
+	for _, generation := range []uint64{2, 4} {
+		select {
+		case status := <-ch:
+			require.Equal(testRuntime2, status.ID)
+			require.Equal(generation, status.Generation)
+		case <-time.After(time.Second):
+			t.Fatalf("failed to receive status %d", generation)
+		}
+	}
+
+	select {
+	case status := <-ch:
+		t.Fatalf("received unexpected status %d", status.Generation)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Watching a key manager without a status should only deliver live updates.
+	testRuntime3 := common.NewTestNamespaceFromSeed([]byte("runtime 3"), common.NamespaceKeyManager)
+	ch3, sub3 := sc.WatchStatusesFor(testRuntime3)
+	defer sub3.Close()
+
+	sc.statusNotifier.Broadcast(&api.Status{ID: testRuntime3, Generation: 5})
+	select {
+	case status := <-ch3:
+		require.EqualValues(5, status.Generation)
+	case <-time.After(time.Second):
+		t.Fatalf("failed to receive status")
+	}
+}
+
+func TestWatchKeyManager(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+
+	err := state.SetStatus(ctx, &api.Status{ID: testRuntime1, Generation: 1})
+	require.NoError(err, "SetStatus")
+	err = state.SetMasterSecret(ctx, &api.SignedEncryptedMasterSecret{
+		Secret: api.EncryptedMasterSecret{ID: testRuntime1, Generation: 2},
+	})
+	require.NoError(err, "SetMasterSecret")
+
+	ch, sub := sc.WatchKeyManager(testRuntime1)
+	defer sub.Close()
+
+	deliver := func(height int64, ev events.TypedAttribute) {
+		cmtEv := abciAPI.NewEventBuilder(app.AppName).TypedAttribute(ev).Event()
+		err := sc.DeliverEvent(context.Background(), height, nil, &cmtEv)
+		require.NoError(err, "DeliverEvent")
+	}
+	deliver(10, &api.EphemeralSecretPublishedEvent{
+		Secret: &api.SignedEncryptedEphemeralSecret{Secret: api.EncryptedEphemeralSecret{ID: testRuntime1, Epoch: 3}},
+	})
+	deliver(10, &api.MasterSecretPublishedEvent{
+		Secret: &api.SignedEncryptedMasterSecret{Secret: api.EncryptedMasterSecret{ID: testRuntime2, Generation: 4}},
+	})
+	deliver(11, &api.StatusUpdateEvent{
+		Statuses: []*api.Status{{ID: testRuntime2, Generation: 5}, {ID: testRuntime1, Generation: 6}},
+	})
+	deliver(12, &api.MasterSecretPublishedEvent{
+		Secret: &api.SignedEncryptedMasterSecret{Secret: api.EncryptedMasterSecret{ID: testRuntime1, Generation: 7}},
+	})
+
+	// The current state should be followed by the events of the given key manager, in order.
+	describe := func(ev *api.Event) string {
+		switch {
+		case ev.Status != nil:
+			return fmt.Sprintf("status %d", ev.Status.Generation)
+		case ev.MasterSecret != nil:
+			return fmt.Sprintf("master secret %d", ev.MasterSecret.Secret.Generation)
+		case ev.EphemeralSecret != nil:
+			return fmt.Sprintf("ephemeral secret %d", ev.EphemeralSecret.Secret.Epoch)
+		default:
+			return "empty"
+		}
+	}
+	for _, expected := range []string{
+		"status 1",
+		"master secret 2",
+		"ephemeral secret 3",
+		"status 6",
+		"master secret 7",
+	} {
+		select {
+		case ev := <-ch:
+			require.Equal(testRuntime1, ev.ID())
+			require.Equal(expected, describe(ev))
+		case <-time.After(time.Second):
+			t.Fatalf("failed to receive %s", expected)
+		}
+	}
+
+	select {
+	case ev := <-ch:
+		t.Fatalf("received unexpected event: %s", describe(ev))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatchMasterSecretsFor(t *testing.T) {
+	require := require.New(t)
+
+	sc, _, _ := newTestServiceClient(t)
+
+	ch, sub := sc.WatchMasterSecretsFor(testRuntime2)
+	defer sub.Close()
+
+	for i, id := range []common.Namespace{testRuntime1, testRuntime2, testRuntime1, testRuntime2} {
+		sc.mstSecretNotifier.Broadcast(&api.SignedEncryptedMasterSecret{
+			Secret: api.EncryptedMasterSecret{
+				ID:         id,
+				Generation: uint64(i),
+			},
+		})
+	}
+
+	// Only secrets of the given key manager should be received.
+	for _, generation := range []uint64{1, 3} {
+		select {
+		case secret := <-ch:
+			require.Equal(testRuntime2, secret.Secret.ID)
+			require.Equal(generation, secret.Secret.Generation)
+		case <-time.After(time.Second):
+			t.Fatalf("failed to receive master secret %d", generation)
+		}
+	}
+
+	select {
+	case secret := <-ch:
+		t.Fatalf("received unexpected master secret %d", secret.Secret.Generation)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatchSecretsReplay(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+
+	for _, id := range []common.Namespace{testRuntime1, testRuntime2} {
+		err := state.SetStatus(ctx, &api.Status{ID: id})
+		require.NoError(err, "SetStatus")
+	}
+	err := state.SetMasterSecret(ctx, &api.SignedEncryptedMasterSecret{
+		Secret: api.EncryptedMasterSecret{ID: testRuntime1, Generation: 3},
+	})
+	require.NoError(err, "SetMasterSecret")
+	err = state.SetEphemeralSecret(ctx, &api.SignedEncryptedEphemeralSecret{
+		Secret: api.EncryptedEphemeralSecret{ID: testRuntime2, Epoch: 5},
+	})
+	require.NoError(err, "SetEphemeralSecret")
+
+	// New subscribers should receive the latest secrets of key managers which have them.
+	mstCh, mstSub := sc.WatchMasterSecrets()
+	defer mstSub.Close()
+	select {
+	case secret := <-mstCh:
+		require.Equal(testRuntime1, secret.Secret.ID)
+		require.EqualValues(3, secret.Secret.Generation)
+	case <-time.After(time.Second):
+		t.Fatalf("failed to receive master secret")
+	}
+
+	ephCh, ephSub := sc.WatchEphemeralSecrets()
+	defer ephSub.Close()
+	select {
+	case secret := <-ephCh:
+		require.Equal(testRuntime2, secret.Secret.ID)
+		require.EqualValues(5, secret.Secret.Epoch)
+	case <-time.After(time.Second):
+		t.Fatalf("failed to receive ephemeral secret")
+	}
+
+	select {
+	case <-mstCh:
+		t.Fatalf("received unexpected master secret")
+	case <-ephCh:
+		t.Fatalf("received unexpected ephemeral secret")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMaxMasterSecretGeneration(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+
+	generations, err := sc.MaxMasterSecretGeneration(ctx, consensus.HeightLatest)
+	require.NoError(err, "MaxMasterSecretGeneration")
+	require.Empty(generations)
+
+	for _, secret := range []*api.SignedEncryptedMasterSecret{
+		{Secret: api.EncryptedMasterSecret{ID: testRuntime1, Generation: 0}},
+		{Secret: api.EncryptedMasterSecret{ID: testRuntime2, Generation: 0}},
+		{Secret: api.EncryptedMasterSecret{ID: testRuntime1, Generation: 1}},
+		{Secret: api.EncryptedMasterSecret{ID: testRuntime1, Generation: 2}},
+	} {
+		err = state.SetMasterSecret(ctx, secret)
+		require.NoError(err, "SetMasterSecret")
+	}
+
+	generations, err = sc.MaxMasterSecretGeneration(ctx, consensus.HeightLatest)
+	require.NoError(err, "MaxMasterSecretGeneration")
+	require.Equal(map[common.Namespace]uint64{
+		testRuntime1: 2,
+		testRuntime2: 0,
+	}, generations)
+}
+
+func TestGetStatusNoSuchStatus(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+
+	err := state.SetStatus(ctx, &api.Status{ID: testRuntime1})
+	require.NoError(err, "SetStatus")
+
+	// Missing statuses must be reported with a sentinel error across the query boundary.
+	_, err = sc.GetStatus(ctx, &registry.NamespaceQuery{ID: testRuntime2, Height: consensus.HeightLatest})
+	require.ErrorIs(err, api.ErrNoSuchStatus)
+
+	_, err = sc.GetStatusesFor(ctx, []common.Namespace{testRuntime1, testRuntime2}, consensus.HeightLatest)
+	require.ErrorIs(err, api.ErrNoSuchStatus)
+
+	statuses, err := sc.GetStatusesByID(ctx, []common.Namespace{testRuntime1, testRuntime2}, consensus.HeightLatest)
+	require.NoError(err, "GetStatusesByID")
+	require.Len(statuses, 1)
+}
+
+func TestGetMasterSecretInfo(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+	sc.backend = &testBackend{
+		baseEpoch: 2,
+		epochs: map[beacon.EpochTime]int64{
+			5: 42,
+		},
+	}
+
+	_, _, err := sc.GetMasterSecretInfo(ctx, testRuntime1, consensus.HeightLatest)
+	require.ErrorIs(err, api.ErrNoSuchStatus)
+
+	// Key manager without master secrets.
+	err = state.SetStatus(ctx, &api.Status{ID: testRuntime1, IsInitialized: true})
+	require.NoError(err, "SetStatus")
+
+	generations, height, err := sc.GetMasterSecretInfo(ctx, testRuntime1, consensus.HeightLatest)
+	require.NoError(err, "GetMasterSecretInfo")
+	require.EqualValues(0, generations)
+	require.EqualValues(0, height)
+
+	// Key manager with three master secret generations, the last one accepted in epoch 5.
+	err = state.SetStatus(ctx, &api.Status{ID: testRuntime1, IsInitialized: true, Generation: 2, RotationEpoch: 5, Checksum: []byte{1}})
+	require.NoError(err, "SetStatus")
+
+	generations, height, err = sc.GetMasterSecretInfo(ctx, testRuntime1, consensus.HeightLatest)
+	require.NoError(err, "GetMasterSecretInfo")
+	require.EqualValues(3, generations)
+	require.EqualValues(42, height)
+
+	// Rotations before the base epoch have no known height.
+	err = state.SetStatus(ctx, &api.Status{ID: testRuntime1, IsInitialized: true, Generation: 2, RotationEpoch: 1, Checksum: []byte{1}})
+	require.NoError(err, "SetStatus")
+
+	generations, height, err = sc.GetMasterSecretInfo(ctx, testRuntime1, consensus.HeightLatest)
+	require.NoError(err, "GetMasterSecretInfo")
+	require.EqualValues(3, generations)
+	require.EqualValues(0, height)
+}
+
+func TestGetStateDigest(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+
+	_, err := sc.GetStateDigest(ctx, testRuntime1, consensus.HeightLatest)
+	require.ErrorIs(err, api.ErrNoSuchStatus)
+
+	err = state.SetStatus(ctx, &api.Status{ID: testRuntime1, IsInitialized: true})
+	require.NoError(err, "SetStatus")
+
+	digest, err := sc.GetStateDigest(ctx, testRuntime1, consensus.HeightLatest)
+	require.NoError(err, "GetStateDigest")
+	require.False(digest.IsEmpty())
+
+	// Equivalent states must result in the same digest.
+	sc2, ctx2, state2 := newTestServiceClient(t)
+	err = state2.SetStatus(ctx2, &api.Status{ID: testRuntime1, IsInitialized: true})
+	require.NoError(err, "SetStatus")
+
+	digest2, err := sc2.GetStateDigest(ctx2, testRuntime1, consensus.HeightLatest)
+	require.NoError(err, "GetStateDigest")
+	require.Equal(digest, digest2)
+
+	// Any change to the key manager state must change the digest.
+	err = state.SetMasterSecret(ctx, &api.SignedEncryptedMasterSecret{
+		Secret: api.EncryptedMasterSecret{ID: testRuntime1, Generation: 1},
+	})
+	require.NoError(err, "SetMasterSecret")
+
+	digest3, err := sc.GetStateDigest(ctx, testRuntime1, consensus.HeightLatest)
+	require.NoError(err, "GetStateDigest")
+	require.NotEqual(digest, digest3)
+}
+
+// testBackend is a consensus backend serving the given block results.
+type testBackend struct {
+	abciAPI.Backend
+
+	results      map[int64]*cmtrpctypes.ResultBlockResults
+	latest       int64
+	lastRetained int64
+	epochs       map[beacon.EpochTime]int64
+	baseEpoch    beacon.EpochTime
+}
+
+func (b *testBackend) Beacon() beacon.Backend {
+	return &testBeacon{epochs: b.epochs, baseEpoch: b.baseEpoch}
+}
+
+// testBeacon is a beacon backend serving the given epoch heights.
+type testBeacon struct {
+	beacon.Backend
+
+	epochs    map[beacon.EpochTime]int64
+	baseEpoch beacon.EpochTime
+}
+
+func (b *testBeacon) GetBaseEpoch(context.Context) (beacon.EpochTime, error) {
+	return b.baseEpoch, nil
+}
+
+func (b *testBeacon) GetEpochBlock(_ context.Context, epoch beacon.EpochTime) (int64, error) {
+	height, ok := b.epochs[epoch]
+	if !ok {
+		return -1, fmt.Errorf("no block for epoch %d", epoch)
+	}
+	return height, nil
+}
+
+func (b *testBackend) GetBlock(_ context.Context, height int64) (*consensus.Block, error) {
+	if height == consensus.HeightLatest {
+		height = b.latest
+	}
+	return &consensus.Block{Height: height}, nil
+}
+
+func (b *testBackend) GetLastRetainedVersion(context.Context) (int64, error) {
+	return b.lastRetained, nil
+}
+
+func (b *testBackend) GetBlockResults(_ context.Context, height int64) (*cmtrpctypes.ResultBlockResults, error) {
+	results, ok := b.results[height]
+	if !ok {
+		return nil, fmt.Errorf("no block results at height %d", height)
+	}
+	return results, nil
+}
+
+func TestGetStatusesSince(t *testing.T) {
+	require := require.New(t)
+
+	sc, _, _ := newTestServiceClient(t)
+
+	statusEvent := func(statuses ...*api.Status) cmtabcitypes.Event {
+		return abciAPI.NewEventBuilder(app.AppName).TypedAttribute(&api.StatusUpdateEvent{
+			Statuses: statuses,
+		}).Event()
+	}
+	otherEvent := abciAPI.NewEventBuilder("other").TypedAttribute(&api.StatusUpdateEvent{
+		Statuses: []*api.Status{{ID: testRuntime1, Generation: 100}},
+	}).Event()
+
+	sc.backend = &testBackend{
+		latest: 3,
+		results: map[int64]*cmtrpctypes.ResultBlockResults{
+			1: {
+				EndBlockEvents: []cmtabcitypes.Event{statusEvent(&api.Status{ID: testRuntime1, Generation: 1})},
+			},
+			2: {
+				BeginBlockEvents: []cmtabcitypes.Event{statusEvent(&api.Status{ID: testRuntime2, Generation: 1})},
+				TxsResults: []*cmtabcitypes.ResponseDeliverTx{
+					{Events: []cmtabcitypes.Event{otherEvent, statusEvent(&api.Status{ID: testRuntime1, Generation: 2})}},
+				},
+			},
+			3: {
+				EndBlockEvents: []cmtabcitypes.Event{statusEvent(
+					&api.Status{ID: testRuntime1, Generation: 3},
+					&api.Status{ID: testRuntime2, Generation: 2},
+				)},
+			},
+		},
+	}
+
+	generations := func(statuses []*api.Status) []uint64 {
+		var gens []uint64
+		for _, status := range statuses {
+			gens = append(gens, status.Generation)
+		}
+		return gens
+	}
+
+	ctx := context.Background()
+
+	statuses, err := sc.GetStatusesSince(ctx, 1, consensus.HeightLatest)
+	require.NoError(err, "GetStatusesSince")
+	require.Equal([]uint64{1, 1, 2, 3, 2}, generations(statuses))
+
+	statuses, err = sc.GetStatusesSince(ctx, 3, consensus.HeightLatest)
+	require.NoError(err, "GetStatusesSince")
+	require.Equal([]uint64{3, 2}, generations(statuses))
+
+	statuses, err = sc.GetStatusesSince(ctx, 1, 2)
+	require.NoError(err, "GetStatusesSince")
+	require.Equal([]uint64{1, 1, 2}, generations(statuses))
+
+	// Invalid ranges should be rejected.
+	for _, tc := range []struct {
+		from, to int64
+	}{
+		{0, consensus.HeightLatest},
+		{-1, 2},
+		{3, 2},
+		{1, 4},
+	} {
+		_, err = sc.GetStatusesSince(ctx, tc.from, tc.to)
+		require.ErrorIs(err, api.ErrInvalidArgument, "GetStatusesSince(%d, %d)", tc.from, tc.to)
+	}
+
+	// Ranges should be bounded.
+	backend := sc.backend.(*testBackend)
+	backend.latest = MaxStatusesSinceRange + 1
+	_, err = sc.GetStatusesSince(ctx, 1, consensus.HeightLatest)
+	require.ErrorIs(err, api.ErrInvalidArgument, "GetStatusesSince should fail for too large ranges")
+	backend.latest = 3
+
+	// Pruned heights should be reported as such.
+	backend.lastRetained = 2
+	_, err = sc.GetStatusesSince(ctx, 1, consensus.HeightLatest)
+	require.ErrorIs(err, consensus.ErrVersionNotFound)
+
+	statuses, err = sc.GetStatusesSince(ctx, 2, consensus.HeightLatest)
+	require.NoError(err, "GetStatusesSince")
+	require.Equal([]uint64{1, 2, 3, 2}, generations(statuses))
+}
+
+func TestDeliverEventStatusCoalescing(t *testing.T) {
+	require := require.New(t)
+
+	sc, _, _ := newTestServiceClient(t)
+
+	ch, sub := sc.WatchStatuses()
+	defer sub.Close()
+
+	deliver := func(height int64, statuses ...*api.Status) {
+		ev := abciAPI.NewEventBuilder(app.AppName).TypedAttribute(&api.StatusUpdateEvent{
+			Statuses: statuses,
+		}).Event()
+		err := sc.DeliverEvent(context.Background(), height, nil, &ev)
+		require.NoError(err, "DeliverEvent")
+	}
+	deliverBlock := func(height int64) {
+		err := sc.DeliverBlock(context.Background(), height)
+		require.NoError(err, "DeliverBlock")
+	}
+	recv := func() []*api.Status {
+		var statuses []*api.Status
+		for {
+			select {
+			case status := <-ch:
+				statuses = append(statuses, status)
+			case <-time.After(100 * time.Millisecond):
+				return statuses
+			}
+		}
+	}
+
+	// Statuses should not be broadcasted before the block is complete.
+	deliver(1,
+		&api.Status{ID: testRuntime1, Generation: 1},
+		&api.Status{ID: testRuntime2, Generation: 1},
+		&api.Status{ID: testRuntime1, Generation: 2},
+	)
+	deliver(1, &api.Status{ID: testRuntime1, Generation: 3})
+	require.Empty(recv())
+
+	// Only the final status of each key manager in the block should be broadcasted.
+	deliverBlock(2)
+	statuses := recv()
+	require.Len(statuses, 2)
+	require.Equal(testRuntime1, statuses[0].ID)
+	require.EqualValues(3, statuses[0].Generation)
+	require.Equal(testRuntime2, statuses[1].ID)
+	require.EqualValues(1, statuses[1].Generation)
+
+	// Statuses should not be broadcasted when the block is delivered before its events.
+	deliver(2, &api.Status{ID: testRuntime1, Generation: 3})
+	deliverBlock(2)
+	require.Empty(recv())
+
+	// Events from a later block should complete the previous block.
+	deliver(3, &api.Status{ID: testRuntime2, Generation: 1})
+	statuses = recv()
+	require.Len(statuses, 1)
+	require.Equal(testRuntime1, statuses[0].ID)
+
+	// Equal statuses in different blocks should be broadcasted.
+	deliverBlock(4)
+	statuses = recv()
+	require.Len(statuses, 1)
+	require.Equal(testRuntime2, statuses[0].ID)
+	require.EqualValues(1, statuses[0].Generation)
+}
+
+func TestGetEphemeralSecretForEpoch(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+	sc.backend = &testBackend{
+		latest: 30,
+		epochs: map[beacon.EpochTime]int64{
+			4: 10,
+			5: 20,
+		},
+	}
+
+	err := state.SetEphemeralSecret(ctx, &api.SignedEncryptedEphemeralSecret{
+		Secret: api.EncryptedEphemeralSecret{ID: testRuntime1, Epoch: 5},
+	})
+	require.NoError(err, "SetEphemeralSecret")
+
+	secret, err := sc.GetEphemeralSecretForEpoch(ctx, testRuntime1, 5)
+	require.NoError(err, "GetEphemeralSecretForEpoch")
+	require.EqualValues(5, secret.Secret.Epoch)
+
+	_, err = sc.GetEphemeralSecretForEpoch(ctx, testRuntime1, 4)
+	require.ErrorIs(err, api.ErrNoSuchEphemeralSecret)
+
+	_, err = sc.GetEphemeralSecretForEpoch(ctx, testRuntime2, 5)
+	require.ErrorIs(err, api.ErrNoSuchEphemeralSecret)
+
+	_, err = sc.GetEphemeralSecretForEpoch(ctx, testRuntime1, 6)
+	require.Error(err, "GetEphemeralSecretForEpoch should fail for unknown epochs")
+
+	sc.backend.(*testBackend).lastRetained = 21
+	_, err = sc.GetEphemeralSecretForEpoch(ctx, testRuntime1, 5)
+	require.ErrorIs(err, api.ErrEphemeralSecretPruned)
+}
+
+func TestSearchEphemeralSecret(t *testing.T) {
+	// secretsAt returns a function serving the latest secret at heights 9 to 19, where the
+	// epochs of the latest secrets are given in the order of heights, with zero meaning none.
+	secretsAt := func(epochs ...beacon.EpochTime) func(context.Context, int64) (*api.SignedEncryptedEphemeralSecret, error) {
+		return func(_ context.Context, height int64) (*api.SignedEncryptedEphemeralSecret, error) {
+			require.True(t, height >= 9 && height < 9+int64(len(epochs)), "height out of range")
+			epoch := epochs[height-9]
+			if epoch == 0 {
+				return nil, api.ErrNoSuchEphemeralSecret
+			}
+			return &api.SignedEncryptedEphemeralSecret{
+				Secret: api.EncryptedEphemeralSecret{ID: testRuntime1, Epoch: epoch},
+			}, nil
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		epochs []beacon.EpochTime
+		err    error
+	}{
+		{"Published in the previous epoch", []beacon.EpochTime{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5}, nil},
+		{"Next published in the first block", []beacon.EpochTime{5, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6}, nil},
+		{"Published during the epoch", []beacon.EpochTime{4, 4, 4, 4, 4, 4, 5, 5, 6, 6, 6}, nil},
+		{"Published in the last block", []beacon.EpochTime{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 5}, nil},
+		{"Not published", []beacon.EpochTime{4, 4, 4, 4, 4, 4, 6, 6, 6, 6, 6}, api.ErrNoSuchEphemeralSecret},
+		{"Not published yet", []beacon.EpochTime{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, api.ErrNoSuchEphemeralSecret},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			secret, err := searchEphemeralSecret(context.Background(), 5, 9, 19, secretsAt(tc.epochs...))
+			if tc.err != nil {
+				require.ErrorIs(err, tc.err)
+				return
+			}
+			require.NoError(err, "searchEphemeralSecret")
+			require.EqualValues(5, secret.Secret.Epoch)
+		})
+	}
+}
+
+func TestDeliverEventSecretMetrics(t *testing.T) {
+	require := require.New(t)
+
+	sc, _, _ := newTestServiceClient(t)
+
+	deliver := func(value events.TypedAttribute) {
+		ev := abciAPI.NewEventBuilder(app.AppName).TypedAttribute(value).Event()
+		err := sc.DeliverEvent(context.Background(), 1, nil, &ev)
+		require.NoError(err, "DeliverEvent")
+	}
+
+	labels := prometheus.Labels{"keymanager": testRuntime1.String()}
+	mstPublished := testutil.ToFloat64(masterSecretsPublished.With(labels))
+	ephPublished := testutil.ToFloat64(ephemeralSecretsPublished.With(labels))
+
+	for _, generation := range []uint64{1, 2} {
+		deliver(&api.MasterSecretPublishedEvent{
+			Secret: &api.SignedEncryptedMasterSecret{
+				Secret: api.EncryptedMasterSecret{ID: testRuntime1, Generation: generation},
+			},
+		})
+	}
+	deliver(&api.EphemeralSecretPublishedEvent{
+		Secret: &api.SignedEncryptedEphemeralSecret{
+			Secret: api.EncryptedEphemeralSecret{ID: testRuntime1, Epoch: 7},
+		},
+	})
+
+	require.EqualValues(mstPublished+2, testutil.ToFloat64(masterSecretsPublished.With(labels)))
+	require.EqualValues(2, testutil.ToFloat64(masterSecretGeneration.With(labels)))
+	require.EqualValues(ephPublished+1, testutil.ToFloat64(ephemeralSecretsPublished.With(labels)))
+	require.EqualValues(7, testutil.ToFloat64(ephemeralSecretEpoch.With(labels)))
+}
+
+func TestGetStatusesFor(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+
+	for _, id := range []common.Namespace{testRuntime1, testRuntime2} {
+		err := state.SetStatus(ctx, &api.Status{ID: id})
+		require.NoError(err, "SetStatus")
+	}
+
+	statuses, err := sc.GetStatusesFor(ctx, []common.Namespace{testRuntime2, testRuntime1}, consensus.HeightLatest)
+	require.NoError(err, "GetStatusesFor")
+	require.Len(statuses, 2)
+	require.Equal(testRuntime2, statuses[0].ID)
+	require.Equal(testRuntime1, statuses[1].ID)
+
+	statuses, err = sc.GetStatusesFor(ctx, nil, consensus.HeightLatest)
+	require.NoError(err, "GetStatusesFor")
+	require.Empty(statuses)
+
+	testRuntime3 := common.NewTestNamespaceFromSeed([]byte("runtime 3"), common.NamespaceKeyManager)
+	_, err = sc.GetStatusesFor(ctx, []common.Namespace{testRuntime1, testRuntime3}, consensus.HeightLatest)
+	require.ErrorIs(err, api.ErrNoSuchStatus)
+}
+
+func TestGetStatusesByID(t *testing.T) {
+	require := require.New(t)
+
+	sc, ctx, state := newTestServiceClient(t)
+
+	err := state.SetStatus(ctx, &api.Status{ID: testRuntime1, Generation: 1})
+	require.NoError(err, "SetStatus")
+
+	statuses, err := sc.GetStatusesByID(ctx, []common.Namespace{testRuntime1, testRuntime2}, consensus.HeightLatest)
+	require.NoError(err, "GetStatusesByID")
+	require.Len(statuses, 1)
+	require.Contains(statuses, testRuntime1)
+	require.EqualValues(1, statuses[testRuntime1].Generation)
+	require.NotContains(statuses, testRuntime2, "missing statuses should be absent")
+}
@@ -0,0 +1,76 @@
+package keymanager
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/oasisprotocol/oasis-core/go/keymanager/api"
+)
+
+var (
+	watcherDroppedMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_consensus_keymanager_watcher_dropped_messages",
+			Help: "Number of key manager notifications dropped due to slow watchers.",
+		},
+		[]string{"kind"},
+	)
+
+	masterSecretsPublished = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_consensus_keymanager_master_secrets_published",
+			Help: "Number of published key manager master secrets.",
+		},
+		[]string{"keymanager"},
+	)
+	masterSecretGeneration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "oasis_consensus_keymanager_master_secret_generation",
+			Help: "Generation of the most recently published key manager master secret.",
+		},
+		[]string{"keymanager"},
+	)
+	ephemeralSecretsPublished = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_consensus_keymanager_ephemeral_secrets_published",
+			Help: "Number of published key manager ephemeral secrets.",
+		},
+		[]string{"keymanager"},
+	)
+	ephemeralSecretEpoch = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "oasis_consensus_keymanager_ephemeral_secret_epoch",
+			Help: "Epoch of the most recently published key manager ephemeral secret.",
+		},
+		[]string{"keymanager"},
+	)
+
+	keymanagerCollectors = []prometheus.Collector{
+		watcherDroppedMessages,
+		masterSecretsPublished,
+		masterSecretGeneration,
+		ephemeralSecretsPublished,
+		ephemeralSecretEpoch,
+	}
+
+	metricsOnce sync.Once
+)
+
+func initMetrics() {
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(keymanagerCollectors...)
+	})
+}
+
+func observeMasterSecret(secret *api.SignedEncryptedMasterSecret) {
+	labels := prometheus.Labels{"keymanager": secret.Secret.ID.String()}
+	masterSecretsPublished.With(labels).Inc()
+	masterSecretGeneration.With(labels).Set(float64(secret.Secret.Generation))
+}
+
+func observeEphemeralSecret(secret *api.SignedEncryptedEphemeralSecret) {
+	labels := prometheus.Labels{"keymanager": secret.Secret.ID.String()}
+	ephemeralSecretsPublished.With(labels).Inc()
+	ephemeralSecretEpoch.With(labels).Set(float64(secret.Secret.Epoch))
+}
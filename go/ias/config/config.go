@@ -1,24 +1,63 @@
 // Package config implements global configuration options.
 package config
 
+import (
+	"fmt"
+	"time"
+)
+
 // Config is the IAS configuration structure.
 type Config struct {
 	// IAS proxy address in the form ID@HOST:PORT.
+	//
+	// The ID is the expected public key of the IAS proxy TLS certificate. Connections to proxies
+	// presenting a different identity are rejected.
 	ProxyAddresses []string `yaml:"proxy_addresses"`
 
+	// Maximum number of attempts made for each IAS proxy request.
+	ProxyMaxAttempts uint64 `yaml:"proxy_max_attempts,omitempty"`
+
+	// Initial interval between IAS proxy request attempts, doubled after each failed attempt.
+	ProxyRetryInterval time.Duration `yaml:"proxy_retry_interval,omitempty"`
+
+	// Timeout of each IAS proxy request attempt. Zero disables the timeout.
+	ProxyRequestTimeout time.Duration `yaml:"proxy_request_timeout,omitempty"`
+
+	// Duration for which signature revocation lists fetched from IAS proxies are cached. Zero
+	// disables caching.
+	ProxySigRLCacheTTL time.Duration `yaml:"proxy_sigrl_cache_ttl,omitempty"`
+
+	// Skip checking that AVRs returned by IAS proxies attest to the submitted quote and nonce.
+	ProxySkipAVRQuoteCheck bool `yaml:"proxy_skip_avr_quote_check,omitempty"`
+
 	// Skip IAS AVR signature verification (UNSAFE).
 	DebugSkipVerify bool `yaml:"debug_skip_verify,omitempty"`
 }
 
 // Validate validates the configuration settings.
 func (c *Config) Validate() error {
+	if c.ProxyMaxAttempts < 1 {
+		return fmt.Errorf("proxy_max_attempts must be at least 1")
+	}
+	if c.ProxyMaxAttempts > 1 && c.ProxyRetryInterval <= 0 {
+		return fmt.Errorf("proxy_retry_interval must be positive when retries are enabled")
+	}
+	if c.ProxyRequestTimeout < 0 {
+		return fmt.Errorf("proxy_request_timeout must not be negative")
+	}
+	if c.ProxySigRLCacheTTL < 0 {
+		return fmt.Errorf("proxy_sigrl_cache_ttl must not be negative")
+	}
 	return nil
 }
 
 // DefaultConfig returns the default configuration settings.
 func DefaultConfig() Config {
 	return Config{
-		ProxyAddresses:  []string{},
-		DebugSkipVerify: false,
+		ProxyAddresses:      []string{},
+		ProxyMaxAttempts:    2,
+		ProxyRetryInterval:  time.Second,
+		ProxyRequestTimeout: 30 * time.Second,
+		DebugSkipVerify:     false,
 	}
 }
@@ -25,5 +25,9 @@ func New(identity *identity.Identity) ([]api.Endpoint, error) {
 	return client.New(
 		identity,
 		config.GlobalConfig.IAS.ProxyAddresses,
+		client.WithRetries(config.GlobalConfig.IAS.ProxyMaxAttempts, config.GlobalConfig.IAS.ProxyRetryInterval),
+		client.WithRequestTimeout(config.GlobalConfig.IAS.ProxyRequestTimeout),
+		client.WithSigRLCacheTTL(config.GlobalConfig.IAS.ProxySigRLCacheTTL),
+		client.WithAVRQuoteCheck(!config.GlobalConfig.IAS.ProxySkipAVRQuoteCheck),
 	)
 }
@@ -2,14 +2,22 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
 	"strings"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"google.golang.org/grpc"
 
+	cmnBackoff "github.com/oasisprotocol/oasis-core/go/common/backoff"
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	cmnTLS "github.com/oasisprotocol/oasis-core/go/common/crypto/tls"
 	cmnGrpc "github.com/oasisprotocol/oasis-core/go/common/grpc"
 	"github.com/oasisprotocol/oasis-core/go/common/identity"
 	"github.com/oasisprotocol/oasis-core/go/common/logging"
@@ -18,6 +26,72 @@ import (
 	"github.com/oasisprotocol/oasis-core/go/ias/proxy"
 )
 
+// startupCheckTimeout is the timeout of the proxy identity and SPID info checks, unless a
+// shorter request timeout is configured.
+const startupCheckTimeout = 10 * time.Second
+
+var (
+	// ErrProxyIdentityMismatch is the error returned when an IAS proxy does not present the
+	// expected node identity.
+	ErrProxyIdentityMismatch = errors.New("ias/proxyclient: proxy identity mismatch")
+
+	// ErrInvalidSPIDInfo is the error returned when an IAS proxy returns invalid SPID info.
+	ErrInvalidSPIDInfo = errors.New("ias/proxyclient: invalid SPID info")
+)
+
+// Option is an IAS proxy client option.
+type Option func(*options)
+
+type options struct {
+	maxAttempts   uint64
+	retryInterval time.Duration
+	sigRLCacheTTL time.Duration
+	timeout       time.Duration
+	checkAVR      bool
+}
+
+// WithRetries configures the maximum number of attempts made for evidence verification and
+// SigRL requests, and the initial interval between the attempts, which is doubled after each
+// failed attempt.
+//
+// By default, requests are not retried.
+func WithRetries(maxAttempts uint64, interval time.Duration) Option {
+	return func(o *options) {
+		o.maxAttempts = maxAttempts
+		o.retryInterval = interval
+	}
+}
+
+// WithSigRLCacheTTL configures the duration for which fetched signature revocation lists are
+// cached.
+//
+// By default, signature revocation lists are not cached.
+func WithSigRLCacheTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.sigRLCacheTTL = ttl
+	}
+}
+
+// WithRequestTimeout configures the timeout of each IAS proxy request attempt. The timeout only
+// takes effect when the caller's context has no earlier deadline.
+//
+// By default, requests have no timeout.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithAVRQuoteCheck configures whether AVRs returned by IAS proxies are checked to attest to the
+// submitted quote and nonce before being returned.
+//
+// By default, AVRs are returned without checks.
+func WithAVRQuoteCheck(enabled bool) Option {
+	return func(o *options) {
+		o.checkAVR = enabled
+	}
+}
+
 var _ api.Endpoint = (*mockEndpoint)(nil)
 
 type mockEndpoint struct{}
@@ -51,10 +125,55 @@ var _ api.Endpoint = (*proxyClient)(nil)
 type proxyClient struct {
 	conn     *grpc.ClientConn
 	endpoint api.Endpoint
+	opts     *options
+	sigRLs   *sigRLCache
 
 	logger *logging.Logger
 }
 
+// withTimeout returns a context bounded by the configured request timeout.
+func (c *proxyClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.opts.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.opts.timeout)
+}
+
+// retry calls the given function until it succeeds, the maximum number of attempts is reached
+// or the context is canceled. Each attempt is bounded by the configured request timeout.
+func (c *proxyClient) retry(ctx context.Context, method string, fn func(context.Context) error) error {
+	attempt := func() error {
+		attemptCtx, cancel := c.withTimeout(ctx)
+		defer cancel()
+		return fn(attemptCtx)
+	}
+	if c.opts.maxAttempts <= 1 {
+		return attempt()
+	}
+
+	boff := cmnBackoff.NewExponentialBackOff()
+	boff.InitialInterval = c.opts.retryInterval
+
+	var attempts uint64
+	return backoff.Retry(func() error {
+		attempts++
+		err := attempt()
+		switch {
+		case err == nil:
+			return nil
+		case ctx.Err() != nil:
+			return backoff.Permanent(err)
+		}
+
+		c.logger.Warn("IAS proxy request failed",
+			"err", err,
+			"method", method,
+			"attempt", attempts,
+		)
+		return err
+	}, backoff.WithContext(backoff.WithMaxRetries(boff, c.opts.maxAttempts-1), ctx))
+}
+
 func (c *proxyClient) VerifyEvidence(ctx context.Context, evidence *api.Evidence) (*ias.AVRBundle, error) {
 	// Ensure the evidence.Quote passes basic sanity/security checks before
 	// even bothering to contact the backend.
@@ -66,15 +185,122 @@ func (c *proxyClient) VerifyEvidence(ctx context.Context, evidence *api.Evidence
 		return nil, err
 	}
 
-	return c.endpoint.VerifyEvidence(ctx, evidence)
+	start := time.Now()
+	var avrBundle *ias.AVRBundle
+	err := c.retry(ctx, "VerifyEvidence", func(ctx context.Context) (err error) {
+		avrBundle, err = c.endpoint.VerifyEvidence(ctx, evidence)
+		return err
+	})
+	observeRequest("VerifyEvidence", start, err)
+	if err != nil {
+		return nil, err
+	}
+	observeAVR(avrBundle)
+
+	if c.opts.checkAVR {
+		if err = checkAVRQuote(avrBundle, &untrustedQuote, evidence.Nonce); err != nil {
+			return nil, fmt.Errorf("IAS proxy returned an invalid AVR: %w", err)
+		}
+	}
+
+	return avrBundle, nil
+}
+
+// checkAVRQuote checks that the given AVR attests to the given quote and nonce.
+//
+// The AVR signature is not verified, as that is done by the consumers of the AVR.
+func checkAVRQuote(avrBundle *ias.AVRBundle, quote *ias.Quote, nonce string) error {
+	avr, err := ias.UnsafeDecodeAVR(avrBundle.Body)
+	if err != nil {
+		return err
+	}
+	if avr.Nonce != nonce {
+		return fmt.Errorf("nonce mismatch (expected: %s, got: %s)", nonce, avr.Nonce)
+	}
+
+	avrQuote, err := avr.Quote()
+	if err != nil {
+		return err
+	}
+	rawAVRQuote, err := avrQuote.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	rawQuote, err := quote.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(rawAVRQuote, rawQuote) {
+		return fmt.Errorf("quote mismatch")
+	}
+
+	return nil
 }
 
 func (c *proxyClient) GetSPIDInfo(ctx context.Context) (*api.SPIDInfo, error) {
-	return c.endpoint.GetSPIDInfo(ctx)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	spidInfo, err := c.endpoint.GetSPIDInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The SPID size is enforced when decoding, but the signature type is not.
+	switch spidInfo.QuoteSignatureType {
+	case ias.SignatureUnlinkable, ias.SignatureLinkable:
+	default:
+		return nil, fmt.Errorf("%w: unknown quote signature type: %d", ErrInvalidSPIDInfo, spidInfo.QuoteSignatureType)
+	}
+
+	return spidInfo, nil
+}
+
+// checkSPIDInfo fetches SPID info from the IAS proxy, so that a misconfigured proxy is detected
+// early.
+//
+// If the SPID info cannot be fetched, the check is skipped as it is validated on each request
+// anyway.
+func (c *proxyClient) checkSPIDInfo(address string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), startupCheckTimeout)
+	defer cancel()
+
+	_, err := c.GetSPIDInfo(ctx)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrInvalidSPIDInfo):
+		return err
+	default:
+		c.logger.Warn("failed to fetch SPID info from IAS proxy, skipping SPID info check",
+			"err", err,
+			"address", address,
+		)
+		return nil
+	}
 }
 
 func (c *proxyClient) GetSigRL(ctx context.Context, epidGID uint32) ([]byte, error) {
-	return c.endpoint.GetSigRL(ctx, epidGID)
+	if c.sigRLs == nil {
+		return c.getSigRL(ctx, epidGID)
+	}
+	return c.sigRLs.get(ctx, epidGID, func(ctx context.Context) ([]byte, error) {
+		return c.getSigRL(ctx, epidGID)
+	})
+}
+
+func (c *proxyClient) getSigRL(ctx context.Context, epidGID uint32) ([]byte, error) {
+	start := time.Now()
+	var sigRL []byte
+	err := c.retry(ctx, "GetSigRL", func(ctx context.Context) (err error) {
+		sigRL, err = c.endpoint.GetSigRL(ctx, epidGID)
+		return err
+	})
+	observeRequest("GetSigRL", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return sigRL, nil
 }
 
 func (c *proxyClient) Cleanup() {
@@ -82,9 +308,16 @@ func (c *proxyClient) Cleanup() {
 }
 
 // New creates a collection of IAS proxy clients (one client per provided address).
-func New(identity *identity.Identity, addresses []string) ([]api.Endpoint, error) {
+func New(identity *identity.Identity, addresses []string, opts ...Option) ([]api.Endpoint, error) {
 	logger := logging.GetLogger("ias/proxyclient")
 
+	initMetrics()
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	if len(addresses) == 0 {
 		logger.Warn("IAS proxy is not configured, all reports will be mocked")
 		return []api.Endpoint{&mockEndpoint{}}, nil
@@ -101,6 +334,10 @@ func New(identity *identity.Identity, addresses []string) ([]api.Endpoint, error
 		if err := pk.UnmarshalText([]byte(spl[0])); err != nil {
 			return nil, fmt.Errorf("malformed public key in address '%s': %w", addr, err)
 		}
+		reachable, err := verifyProxyIdentity(identity, pk, spl[1], o.timeout, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify IAS proxy address '%s': %w", addr, err)
+		}
 		creds, err := cmnGrpc.NewClientCreds(&cmnGrpc.ClientOptions{
 			ServerPubKeys: map[signature.PublicKey]bool{pk: true},
 			CommonName:    proxy.CommonName,
@@ -120,12 +357,65 @@ func New(identity *identity.Identity, addresses []string) ([]api.Endpoint, error
 			return nil, fmt.Errorf("failed to dial IAS proxy address '%s': %w", addr, err)
 		}
 
-		clients = append(clients, &proxyClient{
+		client := &proxyClient{
 			conn:     conn,
 			endpoint: api.NewEndpointClient(conn),
+			opts:     &o,
 			logger:   logger,
-		})
+		}
+		if o.sigRLCacheTTL > 0 {
+			client.sigRLs = newSigRLCache(o.sigRLCacheTTL)
+		}
+		if reachable {
+			if err = client.checkSPIDInfo(spl[1]); err != nil {
+				client.Cleanup()
+				return nil, fmt.Errorf("failed to verify IAS proxy address '%s': %w", addr, err)
+			}
+		}
+		clients = append(clients, client)
 	}
 
 	return clients, nil
 }
+
+// verifyProxyIdentity checks that the IAS proxy at the given address presents the expected node
+// identity, so that a misconfigured proxy is detected early. It returns whether the proxy could
+// be reached.
+//
+// If the proxy cannot be reached, the check is skipped as connections to proxies presenting
+// an unexpected identity are refused anyway.
+func verifyProxyIdentity(identity *identity.Identity, pk signature.PublicKey, address string, timeout time.Duration, logger *logging.Logger) (bool, error) {
+	if timeout <= 0 || timeout > startupCheckTimeout {
+		timeout = startupCheckTimeout
+	}
+
+	var verifyErr error
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		// Certificates are verified below, as public key pinning is used instead of CAs.
+		InsecureSkipVerify: true, //nolint:gosec
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return identity.TLSCertificate, nil
+		},
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			verifyErr = cmnTLS.VerifyCertificate(rawCerts, cmnTLS.VerifyOptions{
+				CommonName: proxy.CommonName,
+				Keys:       map[signature.PublicKey]bool{pk: true},
+			})
+			return verifyErr
+		},
+	})
+	switch {
+	case err == nil:
+		_ = conn.Close()
+		return true, nil
+	case verifyErr != nil:
+		return false, fmt.Errorf("%w: expected %s: %w", ErrProxyIdentityMismatch, pk, verifyErr)
+	default:
+		logger.Warn("failed to connect to IAS proxy, skipping identity check",
+			"err", err,
+			"address", address,
+		)
+		return false, nil
+	}
+}
@@ -0,0 +1,347 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	tlsCert "github.com/oasisprotocol/oasis-core/go/common/crypto/tls"
+	cmnGrpc "github.com/oasisprotocol/oasis-core/go/common/grpc"
+	"github.com/oasisprotocol/oasis-core/go/common/identity"
+	"github.com/oasisprotocol/oasis-core/go/common/sgx/ias"
+	"github.com/oasisprotocol/oasis-core/go/ias/api"
+	"github.com/oasisprotocol/oasis-core/go/ias/proxy"
+)
+
+func newTestProxy(t *testing.T, endpoint api.Endpoint) (signature.PublicKey, string) {
+	require := require.New(t)
+
+	cert, err := tlsCert.Generate(proxy.CommonName)
+	require.NoError(err, "Generate")
+
+	var proxyID signature.PublicKey
+	err = proxyID.UnmarshalBinary(cert.PrivateKey.(ed25519.PrivateKey).Public().(ed25519.PublicKey))
+	require.NoError(err, "UnmarshalBinary")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err, "Listen")
+
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{*cert},
+			ClientAuth:   tls.RequireAnyClientCert,
+		})),
+		grpc.ForceServerCodec(&cmnGrpc.CBORCodec{}),
+	)
+	api.RegisterService(srv, proxy.New(endpoint, nil))
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	t.Cleanup(srv.Stop)
+
+	return proxyID, listener.Addr().String()
+}
+
+func TestProxyIdentity(t *testing.T) {
+	proxyID, addr := newTestProxy(t, &mockEndpoint{})
+
+	clientCert, err := tlsCert.Generate(identity.CommonName)
+	require.NoError(t, err, "Generate")
+	id := &identity.Identity{TLSCertificate: clientCert}
+
+	t.Run("Matching identity", func(t *testing.T) {
+		require := require.New(t)
+
+		endpoints, err := New(id, []string{fmt.Sprintf("%s@%s", proxyID, addr)})
+		require.NoError(err, "New")
+		require.Len(endpoints, 1)
+		defer endpoints[0].Cleanup()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err = endpoints[0].GetSPIDInfo(ctx)
+		require.NoError(err, "GetSPIDInfo")
+	})
+
+	t.Run("Mismatching identity", func(t *testing.T) {
+		require := require.New(t)
+
+		rogueID := signature.NewPublicKey("0000000000000000000000000000000000000000000000000000000000000001")
+		_, err := New(id, []string{fmt.Sprintf("%s@%s", rogueID, addr)})
+		require.ErrorIs(err, ErrProxyIdentityMismatch)
+		require.ErrorContains(err, "tls: bad public key")
+	})
+
+	t.Run("Unreachable proxy", func(t *testing.T) {
+		require := require.New(t)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(err, "Listen")
+		unreachable := listener.Addr().String()
+		require.NoError(listener.Close(), "Close")
+
+		// The identity of unreachable proxies is verified once connections are established.
+		endpoints, err := New(id, []string{fmt.Sprintf("%s@%s", proxyID, unreachable)})
+		require.NoError(err, "New")
+		require.Len(endpoints, 1)
+		endpoints[0].Cleanup()
+	})
+
+	t.Run("Malformed identity", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := New(id, []string{addr})
+		require.ErrorContains(err, "missing public key")
+
+		_, err = New(id, []string{"malformed@" + addr})
+		require.ErrorContains(err, "malformed public key")
+	})
+}
+
+// flakyEndpoint is a mock endpoint failing the given number of SigRL requests.
+type flakyEndpoint struct {
+	mockEndpoint
+
+	failures atomic.Int32
+	calls    atomic.Int32
+}
+
+func (e *flakyEndpoint) GetSigRL(_ context.Context, _ uint32) ([]byte, error) {
+	e.calls.Add(1)
+	if e.failures.Add(-1) >= 0 {
+		return nil, fmt.Errorf("IAS proxy is rate-limited")
+	}
+	return []byte("sigrl"), nil
+}
+
+func TestProxyRetries(t *testing.T) {
+	endpoint := &flakyEndpoint{}
+	proxyID, addr := newTestProxy(t, endpoint)
+
+	clientCert, err := tlsCert.Generate(identity.CommonName)
+	require.NoError(t, err, "Generate")
+	id := &identity.Identity{TLSCertificate: clientCert}
+	addresses := []string{fmt.Sprintf("%s@%s", proxyID, addr)}
+
+	for _, tc := range []struct {
+		name        string
+		opts        []Option
+		failures    int32
+		shouldFail  bool
+		expectCalls int32
+	}{
+		{"No retries", nil, 1, true, 1},
+		{"Retries", []Option{WithRetries(3, 10*time.Millisecond)}, 2, false, 3},
+		{"Retries exhausted", []Option{WithRetries(3, 10*time.Millisecond)}, 3, true, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			endpoint.failures.Store(tc.failures)
+			endpoint.calls.Store(0)
+
+			endpoints, err := New(id, addresses, tc.opts...)
+			require.NoError(err, "New")
+			defer endpoints[0].Cleanup()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			sigRL, err := endpoints[0].GetSigRL(ctx, 0)
+			if tc.shouldFail {
+				require.Error(err, "GetSigRL should fail")
+			} else {
+				require.NoError(err, "GetSigRL")
+				require.Equal([]byte("sigrl"), sigRL)
+			}
+			require.Equal(tc.expectCalls, endpoint.calls.Load())
+		})
+	}
+}
+
+// stallingEndpoint is a mock endpoint never responding to SigRL requests.
+type stallingEndpoint struct {
+	mockEndpoint
+
+	calls atomic.Int32
+}
+
+func (e *stallingEndpoint) GetSigRL(ctx context.Context, _ uint32) ([]byte, error) {
+	e.calls.Add(1)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestProxyRequestTimeout(t *testing.T) {
+	require := require.New(t)
+
+	endpoint := &stallingEndpoint{}
+	proxyID, addr := newTestProxy(t, endpoint)
+
+	clientCert, err := tlsCert.Generate(identity.CommonName)
+	require.NoError(err, "Generate")
+	id := &identity.Identity{TLSCertificate: clientCert}
+
+	endpoints, err := New(id, []string{fmt.Sprintf("%s@%s", proxyID, addr)},
+		WithRetries(2, 10*time.Millisecond),
+		WithRequestTimeout(100*time.Millisecond),
+	)
+	require.NoError(err, "New")
+	defer endpoints[0].Cleanup()
+
+	// Establish the connection first so that all attempts reach the proxy.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = endpoints[0].GetSPIDInfo(ctx)
+	require.NoError(err, "GetSPIDInfo")
+
+	// Requests without a deadline should time out, with each attempt bounded separately.
+	_, err = endpoints[0].GetSigRL(context.Background(), 0)
+	require.Error(err, "GetSigRL should fail")
+	require.EqualValues(2, endpoint.calls.Load())
+}
+
+// staleEndpoint is a mock endpoint returning AVRs for the given quote and nonce.
+type staleEndpoint struct {
+	mockEndpoint
+
+	quote []byte
+	nonce string
+}
+
+func (e *staleEndpoint) VerifyEvidence(_ context.Context, evidence *api.Evidence) (*ias.AVRBundle, error) {
+	quote, nonce := evidence.Quote, evidence.Nonce
+	if e.quote != nil {
+		quote = e.quote
+	}
+	if e.nonce != "" {
+		nonce = e.nonce
+	}
+
+	avr, err := ias.NewMockAVR(quote, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &ias.AVRBundle{
+		Body: avr,
+	}, nil
+}
+
+func newTestQuote(t *testing.T, reportData byte) []byte {
+	quote := ias.Quote{
+		Body: ias.Body{
+			Version:       2,
+			SignatureType: ias.SignatureLinkable,
+		},
+	}
+	quote.Report.ReportData[0] = reportData
+
+	raw, err := quote.MarshalBinary()
+	require.NoError(t, err, "MarshalBinary")
+	return raw
+}
+
+func TestProxyAVRQuoteCheck(t *testing.T) {
+	clientCert, err := tlsCert.Generate(identity.CommonName)
+	require.NoError(t, err, "Generate")
+	id := &identity.Identity{TLSCertificate: clientCert}
+
+	evidence := &api.Evidence{
+		Quote: newTestQuote(t, 1),
+		Nonce: "nonce",
+	}
+
+	for _, tc := range []struct {
+		name       string
+		endpoint   *staleEndpoint
+		check      bool
+		shouldFail bool
+	}{
+		{"Matching AVR", &staleEndpoint{}, true, false},
+		{"Stale quote", &staleEndpoint{quote: newTestQuote(t, 2)}, true, true},
+		{"Stale nonce", &staleEndpoint{nonce: "stale"}, true, true},
+		{"Check disabled", &staleEndpoint{quote: newTestQuote(t, 2)}, false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			proxyID, addr := newTestProxy(t, tc.endpoint)
+			endpoints, err := New(id, []string{fmt.Sprintf("%s@%s", proxyID, addr)}, WithAVRQuoteCheck(tc.check))
+			require.NoError(err, "New")
+			defer endpoints[0].Cleanup()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err = endpoints[0].VerifyEvidence(ctx, evidence)
+			if tc.shouldFail {
+				require.ErrorContains(err, "invalid AVR")
+			} else {
+				require.NoError(err, "VerifyEvidence")
+			}
+		})
+	}
+}
+
+// misconfiguredEndpoint is a mock endpoint returning SPID info with the given signature type.
+type misconfiguredEndpoint struct {
+	mockEndpoint
+
+	signatureType ias.SignatureType
+}
+
+func (e *misconfiguredEndpoint) GetSPIDInfo(ctx context.Context) (*api.SPIDInfo, error) {
+	spidInfo, err := e.mockEndpoint.GetSPIDInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spidInfo.QuoteSignatureType = e.signatureType
+	return spidInfo, nil
+}
+
+func TestProxySPIDInfo(t *testing.T) {
+	clientCert, err := tlsCert.Generate(identity.CommonName)
+	require.NoError(t, err, "Generate")
+	id := &identity.Identity{TLSCertificate: clientCert}
+
+	for _, tc := range []struct {
+		name          string
+		signatureType ias.SignatureType
+		shouldFail    bool
+	}{
+		{"Unlinkable", ias.SignatureUnlinkable, false},
+		{"Linkable", ias.SignatureLinkable, false},
+		{"Invalid", ias.SignatureType(42), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			proxyID, addr := newTestProxy(t, &misconfiguredEndpoint{signatureType: tc.signatureType})
+			endpoints, err := New(id, []string{fmt.Sprintf("%s@%s", proxyID, addr)})
+			if tc.shouldFail {
+				require.ErrorIs(err, ErrInvalidSPIDInfo, "New should fail on invalid SPID info")
+				return
+			}
+			require.NoError(err, "New")
+			defer endpoints[0].Cleanup()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			spidInfo, err := endpoints[0].GetSPIDInfo(ctx)
+			require.NoError(err, "GetSPIDInfo")
+			require.Equal(tc.signatureType, spidInfo.QuoteSignatureType)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/oasisprotocol/oasis-core/go/common/sgx/ias"
+	"github.com/oasisprotocol/oasis-core/go/oasis-node/cmd/common/metrics"
+)
+
+var (
+	// Number of IAS proxy requests.
+	iasRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_ias_proxy_requests",
+			Help: "Number of IAS proxy requests.",
+		},
+		[]string{"method"},
+	)
+
+	// Number of failed IAS proxy requests.
+	iasRequestFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_ias_proxy_request_failures",
+			Help: "Number of failed IAS proxy requests.",
+		},
+		[]string{"method"},
+	)
+
+	// Latency of IAS proxy requests, including retries.
+	iasRequestLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "oasis_ias_proxy_request_latency",
+			Help: "IAS proxy request latency (sec).",
+		},
+		[]string{"method"},
+	)
+
+	// Number of AVRs returned by IAS proxies, by enclave quote status.
+	iasAVRQuoteStatuses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_ias_avr_quote_status",
+			Help: "Number of AVRs returned by IAS proxies by enclave quote status.",
+		},
+		[]string{"status"},
+	)
+
+	iasCollectors = []prometheus.Collector{
+		iasRequests,
+		iasRequestFailures,
+		iasRequestLatency,
+		iasAVRQuoteStatuses,
+	}
+
+	metricsOnce sync.Once
+)
+
+// observeRequest updates the request metrics if metrics are enabled.
+func observeRequest(method string, start time.Time, err error) {
+	if !metrics.Enabled() {
+		return
+	}
+
+	labels := prometheus.Labels{"method": method}
+	iasRequests.With(labels).Inc()
+	iasRequestLatency.With(labels).Observe(time.Since(start).Seconds())
+	if err != nil {
+		iasRequestFailures.With(labels).Inc()
+	}
+}
+
+// observeAVR updates the AVR quote status metrics if metrics are enabled.
+func observeAVR(avrBundle *ias.AVRBundle) {
+	if !metrics.Enabled() {
+		return
+	}
+
+	avr, err := ias.UnsafeDecodeAVR(avrBundle.Body)
+	if err != nil {
+		return
+	}
+	iasAVRQuoteStatuses.With(prometheus.Labels{"status": avr.ISVEnclaveQuoteStatus.String()}).Inc()
+}
+
+// initMetrics registers the metrics collectors if metrics are enabled.
+func initMetrics() {
+	if !metrics.Enabled() {
+		return
+	}
+
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(iasCollectors...)
+	})
+}
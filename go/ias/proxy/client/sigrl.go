@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sigRLFetchTimeout is the maximum duration of a signature revocation list fetch shared between
+// concurrent requests.
+const sigRLFetchTimeout = time.Minute
+
+// sigRLCache is a cache of signature revocation lists keyed by EPID group ID.
+//
+// Concurrent requests for the same EPID group ID share a single fetch. The fetch is detached from
+// the requests' contexts, so a request giving up does not affect the others.
+type sigRLCache struct {
+	sync.Mutex
+
+	ttl     time.Duration
+	entries map[uint32]*sigRLCacheEntry
+}
+
+type sigRLCacheEntry struct {
+	done    chan struct{}
+	fetched time.Time
+
+	sigRL []byte
+	err   error
+}
+
+func newSigRLCache(ttl time.Duration) *sigRLCache {
+	return &sigRLCache{
+		ttl:     ttl,
+		entries: make(map[uint32]*sigRLCacheEntry),
+	}
+}
+
+// get returns the cached signature revocation list for the given EPID group ID, fetching it
+// with the given function if it is missing or has expired.
+func (c *sigRLCache) get(ctx context.Context, epidGID uint32, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	c.Lock()
+	entry, ok := c.entries[epidGID]
+	if ok {
+		select {
+		case <-entry.done:
+			if time.Since(entry.fetched) < c.ttl {
+				c.Unlock()
+				return entry.sigRL, nil
+			}
+		default:
+			// Fetch in progress, wait for it to complete.
+			c.Unlock()
+			select {
+			case <-entry.done:
+				return entry.sigRL, entry.err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	entry = &sigRLCacheEntry{
+		done: make(chan struct{}),
+	}
+	c.entries[epidGID] = entry
+	c.Unlock()
+
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sigRLFetchTimeout)
+	go func() {
+		defer cancel()
+
+		entry.sigRL, entry.err = fetch(fetchCtx)
+		entry.fetched = time.Now()
+
+		c.Lock()
+		if entry.err != nil {
+			// Do not cache failures.
+			delete(c.entries, epidGID)
+		}
+		close(entry.done)
+		c.Unlock()
+	}()
+
+	select {
+	case <-entry.done:
+		return entry.sigRL, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigRLCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Expiry", func(t *testing.T) {
+		require := require.New(t)
+
+		cache := newSigRLCache(100 * time.Millisecond)
+		var fetches int
+		fetch := func(context.Context) ([]byte, error) {
+			fetches++
+			return []byte(fmt.Sprintf("sigrl %d", fetches)), nil
+		}
+
+		sigRL, err := cache.get(ctx, 1, fetch)
+		require.NoError(err, "get")
+		require.Equal([]byte("sigrl 1"), sigRL)
+
+		sigRL, err = cache.get(ctx, 1, fetch)
+		require.NoError(err, "get")
+		require.Equal([]byte("sigrl 1"), sigRL, "cached SigRL should be returned")
+
+		sigRL, err = cache.get(ctx, 2, fetch)
+		require.NoError(err, "get")
+		require.Equal([]byte("sigrl 2"), sigRL, "SigRLs should be cached per EPID group")
+
+		time.Sleep(150 * time.Millisecond)
+
+		sigRL, err = cache.get(ctx, 1, fetch)
+		require.NoError(err, "get")
+		require.Equal([]byte("sigrl 3"), sigRL, "expired SigRL should be refreshed")
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		require := require.New(t)
+
+		cache := newSigRLCache(time.Minute)
+
+		_, err := cache.get(ctx, 1, func(context.Context) ([]byte, error) {
+			return nil, fmt.Errorf("failed")
+		})
+		require.Error(err, "get should fail")
+
+		sigRL, err := cache.get(ctx, 1, func(context.Context) ([]byte, error) {
+			return []byte("sigrl"), nil
+		})
+		require.NoError(err, "get")
+		require.Equal([]byte("sigrl"), sigRL, "failures should not be cached")
+	})
+
+	t.Run("Single flight", func(t *testing.T) {
+		require := require.New(t)
+
+		cache := newSigRLCache(time.Minute)
+		var fetches atomic.Int32
+		fetch := func(context.Context) ([]byte, error) {
+			fetches.Add(1)
+			time.Sleep(100 * time.Millisecond)
+			return []byte("sigrl"), nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sigRL, err := cache.get(ctx, 1, fetch)
+				require.NoError(err, "get")
+				require.Equal([]byte("sigrl"), sigRL)
+			}()
+		}
+		wg.Wait()
+
+		require.EqualValues(1, fetches.Load(), "concurrent requests should share a fetch")
+	})
+
+	t.Run("Canceled request", func(t *testing.T) {
+		require := require.New(t)
+
+		cache := newSigRLCache(time.Minute)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		fetch := func(ctx context.Context) ([]byte, error) {
+			close(started)
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			return []byte("sigrl"), nil
+		}
+
+		// The first request gives up while the fetch is in progress.
+		firstCtx, firstCancel := context.WithCancel(ctx)
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := cache.get(firstCtx, 1, fetch)
+			errCh <- err
+		}()
+		<-started
+
+		sigRLCh := make(chan []byte, 1)
+		go func() {
+			sigRL, err := cache.get(ctx, 1, fetch)
+			require.NoError(err, "get")
+			sigRLCh <- sigRL
+		}()
+
+		firstCancel()
+		require.ErrorIs(<-errCh, context.Canceled)
+
+		// Other requests sharing the fetch should not be affected.
+		close(release)
+		require.Equal([]byte("sigrl"), <-sigRLCh)
+	})
+}
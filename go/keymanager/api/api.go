@@ -48,6 +48,10 @@ var (
 	// does not exist.
 	ErrNoSuchEphemeralSecret = errors.New(ModuleName, 4, "keymanager: no such ephemeral secret")
 
+	// ErrEphemeralSecretPruned is the error returned when the state holding a key manager
+	// ephemeral secret has been pruned.
+	ErrEphemeralSecretPruned = errors.New(ModuleName, 5, "keymanager: ephemeral secret pruned")
+
 	// MethodUpdatePolicy is the method name for policy updates.
 	MethodUpdatePolicy = transaction.NewMethodName(ModuleName, "UpdatePolicy", SignedPolicySGX{})
 
@@ -192,6 +196,8 @@ func (s *Status) VerifyRotationEpoch(epoch beacon.EpochTime) error {
 // Backend is a key manager management implementation.
 type Backend interface {
 	// GetStatus returns a key manager status by key manager ID.
+	//
+	// Returns ErrNoSuchStatus if the key manager has no status yet.
 	GetStatus(context.Context, *registry.NamespaceQuery) (*Status, error)
 
 	// GetStatuses returns all currently tracked key manager statuses.
@@ -441,6 +447,32 @@ func (ev *EphemeralSecretPublishedEvent) EventKind() string {
 	return "ephemeral_secret"
 }
 
+// Event is a key manager event.
+//
+// Exactly one of the fields is set.
+type Event struct {
+	// Status is the updated status of a key manager.
+	Status *Status `json:"status,omitempty"`
+	// MasterSecret is a published master secret.
+	MasterSecret *SignedEncryptedMasterSecret `json:"master_secret,omitempty"`
+	// EphemeralSecret is a published ephemeral secret.
+	EphemeralSecret *SignedEncryptedEphemeralSecret `json:"ephemeral_secret,omitempty"`
+}
+
+// ID returns the ID of the key manager the event belongs to.
+func (ev *Event) ID() common.Namespace {
+	switch {
+	case ev.Status != nil:
+		return ev.Status.ID
+	case ev.MasterSecret != nil:
+		return ev.MasterSecret.Secret.ID
+	case ev.EphemeralSecret != nil:
+		return ev.EphemeralSecret.Secret.ID
+	default:
+		return common.Namespace{}
+	}
+}
+
 func init() {
 	// Old `INSECURE_SIGNING_KEY_PKCS8`.
 	var oldTestKey signature.PublicKey
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/oasisprotocol/oasis-core/go/common"
 	memorySigner "github.com/oasisprotocol/oasis-core/go/common/crypto/signature/signers/memory"
 )
 
@@ -45,3 +46,53 @@ func TestStatus(t *testing.T) {
 	s.Generation = 9
 	require.Equal(uint64(10), s.NextGeneration())
 }
+
+func TestSanityCheckSecrets(t *testing.T) {
+	runtime1 := common.NewTestNamespaceFromSeed([]byte("runtime 1"), common.NamespaceKeyManager)
+	runtime2 := common.NewTestNamespaceFromSeed([]byte("runtime 2"), common.NamespaceKeyManager)
+
+	statuses := []*Status{
+		// Uninitialized key manager.
+		{ID: runtime1},
+		// Key manager with ten master secret generations.
+		{ID: runtime2, Generation: 9, Checksum: []byte{1, 2, 3}},
+	}
+	masterSecret := func(id common.Namespace, generation uint64) *SignedEncryptedMasterSecret {
+		return &SignedEncryptedMasterSecret{
+			Secret: EncryptedMasterSecret{ID: id, Generation: generation},
+		}
+	}
+	ephemeralSecret := func(id common.Namespace) *SignedEncryptedEphemeralSecret {
+		return &SignedEncryptedEphemeralSecret{
+			Secret: EncryptedEphemeralSecret{ID: id},
+		}
+	}
+
+	for _, tc := range []struct {
+		name             string
+		masterSecrets    []*SignedEncryptedMasterSecret
+		ephemeralSecrets []*SignedEncryptedEphemeralSecret
+		valid            bool
+	}{
+		{"No secrets", nil, nil, true},
+		{"First proposal", []*SignedEncryptedMasterSecret{masterSecret(runtime1, 0)}, nil, true},
+		{"Current generation", []*SignedEncryptedMasterSecret{masterSecret(runtime2, 9)}, nil, true},
+		{"Next generation", []*SignedEncryptedMasterSecret{masterSecret(runtime2, 10)}, nil, true},
+		{"Ephemeral secrets", nil, []*SignedEncryptedEphemeralSecret{ephemeralSecret(runtime1), ephemeralSecret(runtime2)}, true},
+		{"Future proposal", []*SignedEncryptedMasterSecret{masterSecret(runtime1, 1)}, nil, false},
+		{"Stale generation", []*SignedEncryptedMasterSecret{masterSecret(runtime2, 8)}, nil, false},
+		{"Skipped generation", []*SignedEncryptedMasterSecret{masterSecret(runtime2, 11)}, nil, false},
+		{"Dangling master secret", []*SignedEncryptedMasterSecret{masterSecret(common.Namespace{1}, 0)}, nil, false},
+		{"Dangling ephemeral secret", nil, []*SignedEncryptedEphemeralSecret{ephemeralSecret(common.Namespace{1})}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := SanityCheckSecrets(statuses, tc.masterSecrets, tc.ephemeralSecrets)
+			switch tc.valid {
+			case true:
+				require.NoError(t, err)
+			case false:
+				require.Error(t, err)
+			}
+		})
+	}
+}
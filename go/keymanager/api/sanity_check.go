@@ -2,6 +2,8 @@ package api
 
 import (
 	"fmt"
+
+	"github.com/oasisprotocol/oasis-core/go/common"
 )
 
 // SanityCheckStatuses examines the statuses table.
@@ -29,6 +31,43 @@ func SanityCheckStatuses(statuses []*Status) error {
 	return nil
 }
 
+// SanityCheckSecrets cross-checks the latest master and ephemeral secrets against the statuses,
+// returning an error if a secret refers to an unknown key manager or, for master secrets, to
+// a generation which is neither the current nor the next generation of the key manager.
+func SanityCheckSecrets(statuses []*Status, masterSecrets []*SignedEncryptedMasterSecret, ephemeralSecrets []*SignedEncryptedEphemeralSecret) error {
+	byID := make(map[common.Namespace]*Status, len(statuses))
+	for _, status := range statuses {
+		byID[status.ID] = status
+	}
+
+	for _, secret := range masterSecrets {
+		status, ok := byID[secret.Secret.ID]
+		if !ok {
+			return fmt.Errorf("keymanager: sanity check failed: master secret of key manager %s without status", secret.Secret.ID)
+		}
+
+		// The latest master secret is either the accepted current generation or a proposal
+		// for the next generation.
+		generation := secret.Secret.Generation
+		isCurrent := len(status.Checksum) > 0 && generation == status.Generation
+		if !isCurrent && generation != status.NextGeneration() {
+			return fmt.Errorf("keymanager: sanity check failed: master secret of key manager %s has generation %d, but status is at generation %d",
+				secret.Secret.ID,
+				generation,
+				status.Generation,
+			)
+		}
+	}
+
+	for _, secret := range ephemeralSecrets {
+		if _, ok := byID[secret.Secret.ID]; !ok {
+			return fmt.Errorf("keymanager: sanity check failed: ephemeral secret of key manager %s without status", secret.Secret.ID)
+		}
+	}
+
+	return nil
+}
+
 // SanityCheck does basic sanity checking on the genesis state.
 func (g *Genesis) SanityCheck() error {
 	if err := g.Parameters.SanityCheck(); err != nil {
@@ -377,7 +377,7 @@ func (c *commission) Run(
 		}
 
 		select {
-		case <-time.After(1 * time.Second):
+		case <-time.After(IterationInterval(1 * time.Second)):
 		case <-gracefulExit.Done():
 			c.Logger.Debug("time's up")
 			return nil
@@ -236,7 +236,7 @@ func (d *delegation) Run(
 		}
 
 		select {
-		case <-time.After(1 * time.Second):
+		case <-time.After(IterationInterval(1 * time.Second)):
 		case <-gracefulExit.Done():
 			d.Logger.Debug("time's up")
 			return nil
@@ -662,7 +662,7 @@ func (g *governanceWorkload) Run(
 	// Main workload loop.
 	for {
 		select {
-		case <-time.After(iterationTimeout):
+		case <-time.After(IterationInterval(iterationTimeout)):
 		case <-gracefulExit.Done():
 			g.Logger.Debug("time's up")
 			return nil
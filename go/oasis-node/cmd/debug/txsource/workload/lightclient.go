@@ -0,0 +1,233 @@
+package workload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	cmnGrpc "github.com/oasisprotocol/oasis-core/go/common/grpc"
+	"github.com/oasisprotocol/oasis-core/go/common/logging"
+	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
+	cmtAPI "github.com/oasisprotocol/oasis-core/go/consensus/cometbft/api"
+	stakingState "github.com/oasisprotocol/oasis-core/go/consensus/cometbft/apps/staking/state"
+	control "github.com/oasisprotocol/oasis-core/go/control/api"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+	"github.com/oasisprotocol/oasis-core/go/storage/mkvs"
+	"github.com/oasisprotocol/oasis-core/go/storage/mkvs/node"
+)
+
+// NameLightClient is the name of the light client workload.
+const NameLightClient = "lightclient"
+
+// LightClient is the light client workload.
+var LightClient = &lightClient{}
+
+const (
+	lightClientIterationTimeout = 300 * time.Second
+	// lightClientMaxAccounts is the maximum number of accounts verified in each iteration.
+	lightClientMaxAccounts = 10
+)
+
+type lightClient struct {
+	logger *logging.Logger
+
+	consensus consensus.ClientBackend
+	control   control.NodeController
+	staking   staking.Backend
+}
+
+// verifiedStateRoot decodes the signed header of the light block at the given height, verifies
+// its commit signatures against the validator set of the light block and returns the state root
+// it commits to, after checking that it matches the given block.
+func (lc *lightClient) verifiedStateRoot(ctx context.Context, block *consensus.Block) (node.Root, error) {
+	height := block.Height
+
+	chainContext, err := lc.consensus.GetChainContext(ctx)
+	if err != nil {
+		return node.Root{}, fmt.Errorf("GetChainContext: %w", err)
+	}
+	lightBlock, err := lc.consensus.GetLightBlock(ctx, height)
+	if err != nil {
+		return node.Root{}, fmt.Errorf("GetLightBlock at height %d: %w", height, err)
+	}
+
+	var pb cmtproto.LightBlock
+	if err = pb.Unmarshal(lightBlock.Meta); err != nil {
+		return node.Root{}, fmt.Errorf("malformed light block at height %d: %w", height, err)
+	}
+	clb, err := cmttypes.LightBlockFromProto(&pb)
+	if err != nil {
+		return node.Root{}, fmt.Errorf("malformed light block at height %d: %w", height, err)
+	}
+	chainID := cmtAPI.CometBFTChainID(chainContext)
+	if err = clb.ValidateBasic(chainID); err != nil {
+		return node.Root{}, fmt.Errorf("invalid light block at height %d: %w", height, err)
+	}
+	// The validator set is not verified against a trusted one, the workload only checks that
+	// the header has been signed by more than two thirds of the voting power of the set.
+	if err = clb.ValidatorSet.VerifyCommitLight(chainID, clb.Commit.BlockID, clb.Height, clb.Commit); err != nil {
+		return node.Root{}, fmt.Errorf("invalid light block commit at height %d: %w", height, err)
+	}
+	if clb.Height != height {
+		return node.Root{}, fmt.Errorf("light block height mismatch, expected: %d, got: %d", height, clb.Height)
+	}
+	if !bytes.Equal(clb.Hash(), block.Hash[:]) {
+		return node.Root{}, fmt.Errorf("light block hash mismatch at height %d", height)
+	}
+
+	var stateRoot hash.Hash
+	if err = stateRoot.UnmarshalBinary(clb.AppHash); err != nil {
+		return node.Root{}, fmt.Errorf("malformed light block state root at height %d: %w", height, err)
+	}
+	if !stateRoot.Equal(&block.StateRoot.Hash) {
+		lc.logger.Error("state root mismatch",
+			"height", height,
+			"light_block_state_root", stateRoot,
+			"block_state_root", block.StateRoot.Hash,
+		)
+		return node.Root{}, fmt.Errorf("state root mismatch at height %d", height)
+	}
+
+	return node.Root{
+		Version: uint64(height) - 1,
+		Type:    node.RootTypeState,
+		Hash:    stateRoot,
+	}, nil
+}
+
+func (lc *lightClient) verifyAccount(ctx context.Context, height int64, state mkvs.Tree, addr staking.Address) error {
+	// Proofs are verified by the tree against the state root of the signed header.
+	raw, err := state.Get(ctx, stakingState.AccountKey(addr))
+	if err != nil {
+		lc.logger.Error("failed to verify account",
+			"err", err,
+			"height", height,
+			"account", addr,
+		)
+		return fmt.Errorf("verified state lookup of %s at height %d: %w", addr, height, err)
+	}
+	var verified staking.Account
+	if raw != nil {
+		if err = cbor.Unmarshal(raw, &verified); err != nil {
+			return fmt.Errorf("malformed account %s at height %d: %w", addr, height, err)
+		}
+	}
+
+	// Compare the verified account state with the one returned by the staking backend.
+	account, err := lc.staking.Account(ctx, &staking.OwnerQuery{Height: height, Owner: addr})
+	if err != nil {
+		return fmt.Errorf("staking.Account at height %d: %w", height, err)
+	}
+	if !bytes.Equal(cbor.Marshal(&verified), cbor.Marshal(account)) {
+		lc.logger.Error("verified account mismatch",
+			"height", height,
+			"account", addr,
+			"verified", verified,
+			"queried", account,
+		)
+		return fmt.Errorf("verified account %s mismatch at height %d", addr, height)
+	}
+	return nil
+}
+
+func (lc *lightClient) doQueries(ctx context.Context, rng *rand.Rand) error {
+	block, err := lc.consensus.GetBlock(ctx, consensus.HeightLatest)
+	if err != nil {
+		return fmt.Errorf("GetBlock: %w", err)
+	}
+	height := block.Height
+
+	root, err := lc.verifiedStateRoot(ctx, block)
+	if err != nil {
+		return err
+	}
+
+	addresses, err := lc.staking.Addresses(ctx, height)
+	if err != nil {
+		return fmt.Errorf("staking.Addresses at height %d: %w", height, err)
+	}
+
+	state := mkvs.NewWithRoot(lc.consensus.State(), nil, root)
+	defer state.Close()
+
+	perm := rng.Perm(len(addresses))
+	if len(perm) > lightClientMaxAccounts {
+		perm = perm[:lightClientMaxAccounts]
+	}
+	for _, i := range perm {
+		if err = lc.verifyAccount(ctx, height, state, addresses[i]); err != nil {
+			return err
+		}
+	}
+
+	lc.logger.Debug("light client queries done",
+		"height", height,
+		"num_accounts", len(perm),
+	)
+	return nil
+}
+
+// Implements Workload.
+func (lc *lightClient) NeedsFunds() bool {
+	return false
+}
+
+// Implements Workload.
+func (lc *lightClient) Run(
+	gracefulExit context.Context,
+	rng *rand.Rand,
+	conn *grpc.ClientConn,
+	cnsc consensus.ClientBackend,
+	_ consensus.SubmissionManager,
+	_ signature.Signer,
+	_ []signature.Signer,
+) error {
+	ctx := context.Background()
+
+	lc.logger = logging.GetLogger("cmd/txsource/workload/lightclient")
+	lc.consensus = cnsc
+	lc.control = control.NewNodeControllerClient(conn)
+	lc.staking = staking.NewStakingClient(conn)
+
+	for {
+		loopCtx, cancel := context.WithTimeout(ctx, lightClientIterationTimeout)
+
+		// Ensure the node appears synced before doing queries as nodes can be restarted.
+		var err error
+		switch isSynced, _ := lc.control.IsSynced(loopCtx); isSynced {
+		case true:
+			err = lc.doQueries(loopCtx, rng)
+		case false:
+			err = lc.control.WaitSync(loopCtx)
+		}
+
+		cancel()
+		switch {
+		case err == nil:
+		case cmnGrpc.IsErrorCode(err, codes.Unavailable):
+			// Don't fail when the node is unavailable as it may be restarting.
+			lc.logger.Warn("node unavailable, retrying",
+				"err", err,
+			)
+		default:
+			return err
+		}
+
+		select {
+		case <-time.After(IterationInterval(1 * time.Second)):
+		case <-gracefulExit.Done():
+			lc.logger.Debug("time's up")
+			return nil
+		}
+	}
+}
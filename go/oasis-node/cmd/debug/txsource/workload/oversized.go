@@ -128,7 +128,7 @@ func (o *oversized) Run(
 		}
 
 		select {
-		case <-time.After(1 * time.Second):
+		case <-time.After(IterationInterval(1 * time.Second)):
 		case <-gracefulExit.Done():
 			o.Logger.Debug("time's up")
 			return nil
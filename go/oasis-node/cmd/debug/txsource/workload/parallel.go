@@ -181,7 +181,7 @@ func (p *parallel) Run(
 		}
 
 		select {
-		case <-time.After(parallelSendTimeoutInterval):
+		case <-time.After(IterationInterval(parallelSendTimeoutInterval)):
 		case <-gracefulExit.Done():
 			parallelLogger.Debug("time's up")
 			return nil
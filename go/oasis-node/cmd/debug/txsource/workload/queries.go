@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"strconv"
 	"time"
 
 	flag "github.com/spf13/pflag"
@@ -52,6 +53,28 @@ const (
 	CfgConsensusNumKeptVersions = "queries.consensus.num_kept_versions"
 	// CfgQueriesRuntimeEnabled configures whether runtime queries are enabled.
 	CfgQueriesRuntimeEnabled = "queries.runtime.enabled"
+	// CfgQueriesQuerySet configures the kinds of queries that should be done and how often, as
+	// a map of query kinds to the ratio of iterations in which the given kind of queries is done.
+	//
+	// If not set, all kinds of queries are done in each iteration.
+	CfgQueriesQuerySet = "queries.query_set"
+
+	// QueryKindControl is the node controller query kind.
+	QueryKindControl = "control"
+	// QueryKindConsensus is the consensus query kind.
+	QueryKindConsensus = "consensus"
+	// QueryKindScheduler is the scheduler query kind.
+	QueryKindScheduler = "scheduler"
+	// QueryKindRegistry is the registry query kind.
+	QueryKindRegistry = "registry"
+	// QueryKindStaking is the staking query kind.
+	QueryKindStaking = "staking"
+	// QueryKindGovernance is the governance query kind.
+	QueryKindGovernance = "governance"
+	// QueryKindRoothash is the roothash query kind.
+	QueryKindRoothash = "roothash"
+	// QueryKindRuntime is the runtime query kind.
+	QueryKindRuntime = "runtime"
 
 	// Ratio of queries that should query height 1.
 	queriesEarliestHeightRatio = 0.1
@@ -73,6 +96,51 @@ const (
 // QueriesFlags are the queries workload flags.
 var QueriesFlags = flag.NewFlagSet("", flag.ContinueOnError)
 
+// QueryKinds are all the query kinds supported by the queries workload.
+var QueryKinds = []string{
+	QueryKindControl,
+	QueryKindConsensus,
+	QueryKindScheduler,
+	QueryKindRegistry,
+	QueryKindStaking,
+	QueryKindGovernance,
+	QueryKindRoothash,
+	QueryKindRuntime,
+}
+
+// ParseQuerySet parses and validates the query set configured via CfgQueriesQuerySet.
+//
+// An empty query set results in a nil map, meaning that all kinds of queries should be done.
+func ParseQuerySet(raw map[string]string) (map[string]float64, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	querySet := make(map[string]float64, len(raw))
+	for kind, rawRatio := range raw {
+		var known bool
+		for _, k := range QueryKinds {
+			if kind == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown query kind: %s", kind)
+		}
+
+		ratio, err := strconv.ParseFloat(rawRatio, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed ratio for query kind %s: %w", kind, err)
+		}
+		if ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("ratio for query kind %s out of range: %f", kind, ratio)
+		}
+		querySet[kind] = ratio
+	}
+	return querySet, nil
+}
+
 type queries struct {
 	logger *logging.Logger
 
@@ -93,6 +161,8 @@ type queries struct {
 
 	runtimeGenesisRound uint64
 
+	querySet map[string]float64
+
 	iteration        uint64
 	queryingEarliest bool
 }
@@ -796,6 +866,29 @@ func (q *queries) doControlQueries(ctx context.Context, _ *rand.Rand) error {
 	return nil
 }
 
+// runtimeQueriesEnabled returns true iff runtime queries can be done in any iteration.
+func (q *queries) runtimeQueriesEnabled() bool {
+	if !viper.GetBool(CfgQueriesRuntimeEnabled) {
+		return false
+	}
+	return q.querySet == nil || q.querySet[QueryKindRuntime] > 0
+}
+
+// shouldQuery returns true iff the given kind of queries should be done in the current iteration.
+func (q *queries) shouldQuery(rng *rand.Rand, kind string) bool {
+	if kind == QueryKindRuntime && !q.runtimeQueriesEnabled() {
+		return false
+	}
+	if q.querySet == nil {
+		return true
+	}
+	ratio, ok := q.querySet[kind]
+	if !ok || ratio == 0 {
+		return false
+	}
+	return rng.Float64() < ratio
+}
+
 func (q *queries) doQueries(ctx context.Context, rng *rand.Rand) error {
 	block, err := q.consensus.GetBlock(ctx, consensus.HeightLatest)
 	if err != nil {
@@ -829,28 +922,42 @@ func (q *queries) doQueries(ctx context.Context, rng *rand.Rand) error {
 		"height_latest", block.Height,
 	)
 
-	if err := q.doControlQueries(ctx, rng); err != nil {
-		return fmt.Errorf("control queries error: %w", err)
+	if q.shouldQuery(rng, QueryKindControl) {
+		if err := q.doControlQueries(ctx, rng); err != nil {
+			return fmt.Errorf("control queries error: %w", err)
+		}
 	}
-	if err := q.doConsensusQueries(ctx, rng, height); err != nil {
-		return fmt.Errorf("consensus queries error: %w", err)
+	if q.shouldQuery(rng, QueryKindConsensus) {
+		if err := q.doConsensusQueries(ctx, rng, height); err != nil {
+			return fmt.Errorf("consensus queries error: %w", err)
+		}
 	}
-	if err := q.doSchedulerQueries(ctx, rng, height); err != nil {
-		return fmt.Errorf("scheduler queries error: %w", err)
+	if q.shouldQuery(rng, QueryKindScheduler) {
+		if err := q.doSchedulerQueries(ctx, rng, height); err != nil {
+			return fmt.Errorf("scheduler queries error: %w", err)
+		}
 	}
-	if err := q.doRegistryQueries(ctx, height); err != nil {
-		return fmt.Errorf("registry queries error: %w", err)
+	if q.shouldQuery(rng, QueryKindRegistry) {
+		if err := q.doRegistryQueries(ctx, height); err != nil {
+			return fmt.Errorf("registry queries error: %w", err)
+		}
 	}
-	if err := q.doStakingQueries(ctx, rng, height); err != nil {
-		return fmt.Errorf("staking queries error: %w", err)
+	if q.shouldQuery(rng, QueryKindStaking) {
+		if err := q.doStakingQueries(ctx, rng, height); err != nil {
+			return fmt.Errorf("staking queries error: %w", err)
+		}
 	}
-	if err := q.doGovernanceQueries(ctx, rng, height); err != nil {
-		return fmt.Errorf("governance queries error: %w", err)
+	if q.shouldQuery(rng, QueryKindGovernance) {
+		if err := q.doGovernanceQueries(ctx, rng, height); err != nil {
+			return fmt.Errorf("governance queries error: %w", err)
+		}
 	}
-	if err := q.doRoothashQueries(ctx, rng, height); err != nil {
-		return fmt.Errorf("roothash queries error: %w", err)
+	if q.shouldQuery(rng, QueryKindRoothash) {
+		if err := q.doRoothashQueries(ctx, rng, height); err != nil {
+			return fmt.Errorf("roothash queries error: %w", err)
+		}
 	}
-	if viper.GetBool(CfgQueriesRuntimeEnabled) {
+	if q.shouldQuery(rng, QueryKindRuntime) {
 		if err := q.doRuntimeQueries(ctx, rng); err != nil {
 			return fmt.Errorf("runtime queries error: %w", err)
 		}
@@ -884,6 +991,11 @@ func (q *queries) Run(
 
 	q.logger = logging.GetLogger("cmd/txsource/workload/queries")
 
+	q.querySet, err = ParseQuerySet(viper.GetStringMapString(CfgQueriesQuerySet))
+	if err != nil {
+		return fmt.Errorf("invalid query set: %w", err)
+	}
+
 	q.control = control.NewNodeControllerClient(conn)
 	q.consensus = cnsc
 	q.beacon = beacon.NewBeaconClient(conn)
@@ -917,7 +1029,7 @@ func (q *queries) Run(
 		return fmt.Errorf("runtime unmarshal: %w", err)
 	}
 
-	if viper.GetBool(CfgQueriesRuntimeEnabled) {
+	if q.runtimeQueriesEnabled() {
 		// Only query genesis block info if runtime queries are enabled.
 		var resp *block.Block
 		resp, err = q.runtime.GetGenesisBlock(ctx, q.runtimeID)
@@ -963,7 +1075,7 @@ func (q *queries) Run(
 		}
 
 		select {
-		case <-time.After(1 * time.Second):
+		case <-time.After(IterationInterval(1 * time.Second)):
 		case <-gracefulExit.Done():
 			q.logger.Debug("time's up")
 			return nil
@@ -974,5 +1086,6 @@ func (q *queries) Run(
 func init() {
 	QueriesFlags.Int64(CfgConsensusNumKeptVersions, 0, "Number of last versions kept by nodes")
 	QueriesFlags.Bool(CfgQueriesRuntimeEnabled, true, "Whether runtime queries should be enabled")
+	QueriesFlags.StringToString(CfgQueriesQuerySet, nil, "Ratio of iterations in which each query kind is done (all kinds if not set)")
 	_ = viper.BindPFlags(QueriesFlags)
 }
@@ -377,7 +377,7 @@ func (r *registration) Run( // nolint: gocyclo
 
 		iteration++
 		select {
-		case <-time.After(1 * time.Second):
+		case <-time.After(IterationInterval(1 * time.Second)):
 		case <-gracefulExit.Done():
 			r.Logger.Debug("time's up")
 			return nil
@@ -872,7 +872,7 @@ func (r *runtime) Run(
 		}
 
 		select {
-		case <-time.After(1 * time.Second):
+		case <-time.After(IterationInterval(1 * time.Second)):
 		case <-gracefulExit.Done():
 			r.Logger.Debug("time's up")
 			return nil
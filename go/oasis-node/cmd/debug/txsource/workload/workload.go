@@ -7,6 +7,7 @@ import (
 	"time"
 
 	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
@@ -19,6 +20,9 @@ import (
 )
 
 const (
+	// CfgWeight is the workload weight, scaling the rate at which workload iterations are done.
+	CfgWeight = "workload.weight"
+
 	maxSubmissionRetryElapsedTime = 120 * time.Second
 
 	fundAccountAmount = 10000000000
@@ -28,6 +32,7 @@ const (
 var ByName = map[string]Workload{
 	NameCommission:   Commission,
 	NameDelegation:   Delegation,
+	NameLightClient:  LightClient,
 	NameOversized:    Oversized,
 	NameParallel:     Parallel,
 	NameQueries:      Queries,
@@ -37,9 +42,30 @@ var ByName = map[string]Workload{
 	NameGovernance:   Governance,
 }
 
+// unweightedWorkloads are the workloads which do iterations back to back, so the rate at which
+// they do iterations cannot be scaled by the workload weight.
+var unweightedWorkloads = map[string]bool{
+	NameTransfer: true,
+}
+
 // Flags has the workload flags.
 var Flags = flag.NewFlagSet("", flag.ContinueOnError)
 
+// SupportsWeight returns true iff the workload with the given name honors the workload weight.
+func SupportsWeight(name string) bool {
+	return !unweightedWorkloads[name]
+}
+
+// IterationInterval returns the given interval between workload iterations scaled down by the
+// configured workload weight.
+func IterationInterval(interval time.Duration) time.Duration {
+	weight := viper.GetUint(CfgWeight)
+	if weight <= 1 {
+		return interval
+	}
+	return interval / time.Duration(weight)
+}
+
 // Workload is a DRBG-backed schedule of transactions.
 type Workload interface {
 	// NeedsFunds should return true if the workload requires funding.
@@ -194,6 +220,11 @@ func FundAccountFromTestEntity(
 }
 
 func init() {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.Uint(CfgWeight, 1, "Workload weight, dividing the interval between workload iterations (not supported by workloads doing iterations back to back)")
+	_ = viper.BindPFlags(fs)
+
+	Flags.AddFlagSet(fs)
 	Flags.AddFlagSet(QueriesFlags)
 	Flags.AddFlagSet(RuntimeFlags)
 }
@@ -7,11 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -27,6 +29,7 @@ import (
 	"github.com/oasisprotocol/oasis-core/go/common/logging"
 	"github.com/oasisprotocol/oasis-core/go/common/node"
 	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	cmnSyscall "github.com/oasisprotocol/oasis-core/go/common/syscall"
 	"github.com/oasisprotocol/oasis-core/go/config"
 	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
 	consensusGenesis "github.com/oasisprotocol/oasis-core/go/consensus/genesis"
@@ -91,6 +94,21 @@ type MetricsCfg struct {
 	Address string `json:"address"`
 	// Push interval.
 	Interval time.Duration `json:"interval"`
+	// Pull enables per-node pull metrics endpoints in case no push address is configured.
+	Pull bool `json:"pull,omitempty"`
+}
+
+// NodeResourceLimitsCfg is the per-node resource limits configuration.
+//
+// The limits are enforced as process resource limits, so they bound the virtual memory and the
+// total CPU time of each node process rather than its resident memory and CPU usage rate.
+type NodeResourceLimitsCfg struct {
+	// VirtualMemoryLimit is the maximum size of the node's virtual address space (bytes).
+	// Allocations beyond the limit fail, which usually makes the node abort.
+	VirtualMemoryLimit uint64 `json:"virtual_memory_limit,omitempty"`
+	// CPUTimeLimit is the maximum amount of CPU time the node can consume over the lifetime of
+	// its process. The node is killed once the limit is reached, restarts reset the budget.
+	CPUTimeLimit time.Duration `json:"cpu_time_limit,omitempty"`
 }
 
 // NetworkCfg is the Oasis test network configuration.
@@ -138,6 +156,9 @@ type NetworkCfg struct { // nolint: maligned
 	// Metrics is the network metrics configuration.
 	Metrics MetricsCfg `json:"metrics,omitempty"`
 
+	// NodeResourceLimits are the optional resource limits applied to all launched nodes.
+	NodeResourceLimits NodeResourceLimitsCfg `json:"node_resource_limits,omitempty"`
+
 	// StakingGenesis is the staking genesis data to be included if
 	// GenesisFile is not set.
 	StakingGenesis *staking.Genesis `json:"staking_genesis,omitempty"`
@@ -680,6 +701,9 @@ func (net *Network) startOasisNode(
 		cfg.Metrics.Interval = net.Config().Metrics.Interval
 		cfg.Metrics.JobName = node.Name
 		cfg.Metrics.Labels = metrics.GetDefaultPushLabels(net.env.ScenarioInfo())
+	} else if addr := node.MetricsAddress(); addr != "" {
+		cfg.Metrics.Mode = metrics.MetricsModePull
+		cfg.Metrics.Address = addr
 	}
 	args := append([]string{}, subCmd...)
 	args = append(args, baseArgs...)
@@ -715,6 +739,11 @@ func (net *Network) startOasisNode(
 	if err = cmd.Start(); err != nil {
 		return fmt.Errorf("oasis: failed to start node: %w", err)
 	}
+	if err = net.applyResourceLimits(cmd.Process.Pid); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("oasis: failed to apply resource limits to node: %w", err)
+	}
 
 	doneCh := net.env.AddTermOnCleanup(cmd)
 	exitCh := make(chan error, 1)
@@ -746,12 +775,30 @@ func (net *Network) startOasisNode(
 		}
 	}()
 
+	node.Lock()
 	node.cmd = cmd
 	node.exitCh = exitCh
+	node.Unlock()
 
 	return nil
 }
 
+func (net *Network) applyResourceLimits(pid int) error {
+	limits := net.cfg.NodeResourceLimits
+	if limits.VirtualMemoryLimit > 0 {
+		if err := cmnSyscall.SetProcessRlimit(pid, syscall.RLIMIT_AS, limits.VirtualMemoryLimit); err != nil {
+			return fmt.Errorf("failed to set virtual memory limit: %w", err)
+		}
+	}
+	if limits.CPUTimeLimit > 0 {
+		cpuTime := uint64(math.Ceil(limits.CPUTimeLimit.Seconds()))
+		if err := cmnSyscall.SetProcessRlimit(pid, syscall.RLIMIT_CPU, cpuTime); err != nil {
+			return fmt.Errorf("failed to set CPU time limit: %w", err)
+		}
+	}
+	return nil
+}
+
 // MakeGenesis generates a new Genesis file.
 func (net *Network) MakeGenesis() error {
 	args := []string{
@@ -13,6 +13,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/prometheus/procfs"
+
 	"github.com/oasisprotocol/oasis-core/go/common"
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
 	fileSigner "github.com/oasisprotocol/oasis-core/go/common/crypto/signature/signers/file"
@@ -52,6 +54,7 @@ const (
 	nodePortP2P       = "p2p"
 	nodePortP2PSeed   = "p2p-seed"
 	nodePortPprof     = "pprof"
+	nodePortMetrics   = "metrics"
 )
 
 // ConsensusStateSyncCfg is a node's consensus state sync configuration.
@@ -111,7 +114,8 @@ type Node struct { // nolint: maligned
 	consensusStateSync   *ConsensusStateSyncCfg
 	customGrpcSocketPath string
 
-	pprofPort uint16
+	pprofPort   uint16
+	metricsPort uint16
 
 	nodeSigner signature.PublicKey
 	p2pSigner  signature.PublicKey
@@ -330,7 +334,9 @@ func (n *Node) stopNode(graceful bool) error {
 	}
 	_ = n.cmd.Wait()
 	<-n.Exit()
+	n.Lock()
 	n.cmd = nil
+	n.Unlock()
 
 	return nil
 }
@@ -346,6 +352,36 @@ func (n *Node) StopGracefully() error {
 	return n.stopNode(true)
 }
 
+// MetricsAddress returns the address of the node's pull metrics endpoint or an empty string
+// in case pull metrics are not enabled.
+func (n *Node) MetricsAddress() string {
+	if n.metricsPort == 0 {
+		return ""
+	}
+	return "127.0.0.1:" + strconv.Itoa(int(n.metricsPort))
+}
+
+// ResidentMemory returns the resident set size of the node process (in bytes).
+func (n *Node) ResidentMemory() (uint64, error) {
+	// The node may be restarted concurrently.
+	n.Lock()
+	cmd := n.cmd
+	n.Unlock()
+
+	if cmd == nil || cmd.Process == nil {
+		return 0, fmt.Errorf("oasis/node: node %s is not running", n.Name)
+	}
+	proc, err := procfs.NewProc(cmd.Process.Pid)
+	if err != nil {
+		return 0, fmt.Errorf("oasis/node: failed to open process of node %s: %w", n.Name, err)
+	}
+	status, err := proc.NewStatus()
+	if err != nil {
+		return 0, fmt.Errorf("oasis/node: failed to read process status of node %s: %w", n.Name, err)
+	}
+	return status.VmRSS, nil
+}
+
 // Restart kills the node, waits for it to stop, and starts it again.
 func (n *Node) Restart(ctx context.Context) error {
 	return n.RestartAfter(ctx, 0)
@@ -495,6 +531,9 @@ func (cfg *NodeCfg) Into(node *Node) {
 	if node.pprofPort == 0 && cfg.EnableProfiling {
 		node.pprofPort = node.getProvisionedPort(nodePortPprof)
 	}
+	if node.metricsPort == 0 && node.net.cfg.Metrics.Pull {
+		node.metricsPort = node.getProvisionedPort(nodePortMetrics)
+	}
 	node.extraArgs = cfg.ExtraArgs
 }
 
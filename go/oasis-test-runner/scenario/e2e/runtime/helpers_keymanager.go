@@ -3,6 +3,7 @@ package runtime
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 
@@ -128,7 +129,7 @@ func (sc *Scenario) UpdateRotationInterval(ctx context.Context, childEnv *env.En
 	)
 
 	status, err := sc.KeyManagerStatus(ctx)
-	if err != nil && err != keymanager.ErrNoSuchStatus {
+	if err != nil && !errors.Is(err, keymanager.ErrNoSuchStatus) {
 		return err
 	}
 
@@ -381,7 +382,7 @@ func (sc *Scenario) BuildEnclavePolicies() (map[sgx.EnclaveIdentity]*keymanager.
 // ApplyKeyManagerPolicy applies the given policy to the simple key manager runtime.
 func (sc *Scenario) ApplyKeyManagerPolicy(ctx context.Context, childEnv *env.Env, cli *cli.Helpers, rotationInterval beacon.EpochTime, policies map[sgx.EnclaveIdentity]*keymanager.EnclavePolicySGX, nonce uint64) error {
 	status, err := sc.KeyManagerStatus(ctx)
-	if err != nil && err != keymanager.ErrNoSuchStatus {
+	if err != nil && !errors.Is(err, keymanager.ErrNoSuchStatus) {
 		return err
 	}
 
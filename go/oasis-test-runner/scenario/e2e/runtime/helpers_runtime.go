@@ -280,7 +280,7 @@ func (sc *Scenario) EnableRuntimeDeployment(ctx context.Context, childEnv *env.E
 
 	// Update the key manager policy.
 	status, err := sc.KeyManagerStatus(ctx)
-	if err != nil && err != keymanager.ErrNoSuchStatus {
+	if err != nil && !errors.Is(err, keymanager.ErrNoSuchStatus) {
 		return err
 	}
 	var policies map[sgx.EnclaveIdentity]*keymanager.EnclavePolicySGX
@@ -380,6 +380,9 @@ func RegisterScenarios() error {
 		// it is identical to the txsource-multi-short, only using fewer nodes
 		// due to SGX CI instance resource constrains.
 		TxSourceMultiShortSGX,
+		// Variant of the txsource-multi-short test with height-based node restarts. Non-default,
+		// because node restarts disable the round timeout and discrepancy log watchers.
+		TxSourceMultiShortRestarts,
 	} {
 		if err := cmd.RegisterNondefault(s); err != nil {
 			return err
@@ -1,11 +1,14 @@
 package runtime
 
 import (
+	"bufio"
 	"context"
 	"crypto"
 	cryptoRand "crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"os"
@@ -31,6 +34,7 @@ import (
 	"github.com/oasisprotocol/oasis-core/go/oasis-test-runner/env"
 	"github.com/oasisprotocol/oasis-core/go/oasis-test-runner/log"
 	"github.com/oasisprotocol/oasis-core/go/oasis-test-runner/oasis"
+	"github.com/oasisprotocol/oasis-core/go/oasis-test-runner/oasis/cli"
 	"github.com/oasisprotocol/oasis-core/go/oasis-test-runner/scenario"
 	"github.com/oasisprotocol/oasis-core/go/oasis-test-runner/scenario/e2e"
 	scheduler "github.com/oasisprotocol/oasis-core/go/scheduler/api"
@@ -43,12 +47,39 @@ const (
 	timeLimitLong     = 12 * time.Hour
 
 	nodeRestartIntervalLong = 2 * time.Minute
+	nodeRestartHeightShort  = 100
 	nodeLongRestartInterval = 15 * time.Minute
 	nodeLongRestartDuration = 10 * time.Minute
 	livenessCheckInterval   = 2 * time.Minute
+	nodeMaxResidentMemory   = 8 * 1024 * 1024 * 1024
+	workloadStartJitter     = 30 * time.Second
+	checkpointSyncCheckTime = 3 * time.Hour
+	checkpointSyncTimeout   = 30 * time.Minute
 	txSourceGasPrice        = 1
 
 	crashPointProbability = 0.0005
+
+	txSourceNodeBalance = 10000000000
+)
+
+var (
+	// txSourceValidatorAccounts are the deterministic accounts of validator nodes. Each validator
+	// belongs to a separate deterministic entity.
+	txSourceValidatorAccounts = []staking.Address{
+		e2e.DeterministicValidator0,
+		e2e.DeterministicValidator1,
+		e2e.DeterministicValidator2,
+		e2e.DeterministicValidator3,
+	}
+
+	// txSourceComputeAccounts are the deterministic accounts of compute nodes.
+	txSourceComputeAccounts = []staking.Address{
+		e2e.DeterministicCompute0,
+		e2e.DeterministicCompute1,
+		e2e.DeterministicCompute2,
+		e2e.DeterministicCompute3,
+		e2e.DeterministicCompute4,
+	}
 )
 
 // TxSourceMultiShort uses multiple workloads for a short time.
@@ -68,6 +99,37 @@ var TxSourceMultiShort scenario.Scenario = &txSourceImpl{
 		workload.NameQueries,
 	},
 	timeLimit:                         timeLimitShort,
+	workloadStartJitter:               workloadStartJitter,
+	livenessCheckInterval:             livenessCheckInterval,
+	consensusPruneDisabledProbability: 0.1,
+	consensusPruneMinKept:             100,
+	consensusPruneMaxKept:             200,
+	numValidatorNodes:                 4,
+	numKeyManagerNodes:                2,
+	numComputeNodes:                   4,
+	numClientNodes:                    2,
+}
+
+// TxSourceMultiShortRestarts uses multiple workloads for a short time while restarting nodes
+// at consensus heights, so that the restart sequence is reproducible from the scenario seed.
+var TxSourceMultiShortRestarts scenario.Scenario = &txSourceImpl{
+	Scenario: *NewScenario("txsource-multi-short-restarts", nil),
+	clientWorkloads: []string{
+		workload.NameCommission,
+		workload.NameDelegation,
+		workload.NameOversized,
+		workload.NameParallel,
+		workload.NameRegistration,
+		workload.NameRuntime,
+		workload.NameTransfer,
+		workload.NameGovernance,
+	},
+	allNodeWorkloads: []string{
+		workload.NameQueries,
+	},
+	timeLimit:                         timeLimitShort,
+	workloadStartJitter:               workloadStartJitter,
+	nodeRestartHeightInterval:         nodeRestartHeightShort,
 	livenessCheckInterval:             livenessCheckInterval,
 	consensusPruneDisabledProbability: 0.1,
 	consensusPruneMinKept:             100,
@@ -121,13 +183,18 @@ var TxSourceMulti scenario.Scenario = &txSourceImpl{
 		workload.NameGovernance,
 	},
 	allNodeWorkloads: []string{
+		workload.NameLightClient,
 		workload.NameQueries,
 	},
 	timeLimit:                         timeLimitLong,
+	workloadStartJitter:               workloadStartJitter,
+	checkpointSyncCheckTime:           checkpointSyncCheckTime,
+	checkpointSyncTimeout:             checkpointSyncTimeout,
 	nodeRestartInterval:               nodeRestartIntervalLong,
 	nodeLongRestartInterval:           nodeLongRestartInterval,
 	nodeLongRestartDuration:           nodeLongRestartDuration,
 	livenessCheckInterval:             livenessCheckInterval,
+	nodeMaxResidentMemory:             nodeMaxResidentMemory,
 	consensusPruneDisabledProbability: 0.1,
 	consensusPruneMinKept:             100,
 	consensusPruneMaxKept:             1000,
@@ -150,6 +217,12 @@ var TxSourceMulti scenario.Scenario = &txSourceImpl{
 	// Second client node is used to run supplementary-sanity checks which can
 	// cause the node to fall behind over the long run.
 	numClientNodes: 2,
+	metricsInvariants: []metricsInvariant{
+		// No validator should ever misbehave.
+		metricMaxValue("cometbft_consensus_byzantine_validators", 0),
+		// Blocks should keep being produced at a steady pace despite restarts.
+		metricMaxAverage("cometbft_consensus_block_interval_seconds", 10),
+	},
 }
 
 type txSourceImpl struct { // nolint: maligned
@@ -158,12 +231,46 @@ type txSourceImpl struct { // nolint: maligned
 	clientWorkloads  []string
 	allNodeWorkloads []string
 
+	// queriesQuerySet is the query set of the queries workload, mapping query kinds to the ratio
+	// of iterations in which they are done. If empty, all kinds of queries are done.
+	queriesQuerySet map[string]float64
+
+	// workloadWeights are the weights of workloads, scaling the rate at which workload
+	// iterations are done. Workloads without a weight have the default weight of 1. Weights of
+	// workloads which don't support them are rejected.
+	workloadWeights map[string]int
+	// workloadStartJitter is the interval over which workload starts are randomly spread.
+	workloadStartJitter time.Duration
+
 	timeLimit               time.Duration
 	nodeRestartInterval     time.Duration
 	nodeLongRestartInterval time.Duration
 	nodeLongRestartDuration time.Duration
 	livenessCheckInterval   time.Duration
 
+	// nodeRestartHeightInterval, if non-zero, drives node restarts off consensus height instead
+	// of nodeRestartInterval. A node is restarted once the consensus height reaches a multiple of
+	// the interval, making the restart sequence reproducible from the scenario seed. Multiples
+	// passed while a node is being restarted are skipped, so at most one node is restarted per
+	// interval.
+	nodeRestartHeightInterval int64
+
+	// checkpointSyncCheckTime, if non-zero, is the time after which the state of the last compute
+	// node is wiped, verifying that it restores runtime state from a checkpoint and catches up
+	// within checkpointSyncTimeout.
+	checkpointSyncCheckTime time.Duration
+	checkpointSyncTimeout   time.Duration
+
+	// nodeResourceLimits are the resource limits applied to all nodes when they are launched.
+	nodeResourceLimits oasis.NodeResourceLimitsCfg
+	// nodeMaxResidentMemory is the maximum resident memory (in bytes) of any node, checked
+	// during liveness checks. Zero means no limit.
+	nodeMaxResidentMemory uint64
+
+	// metricsInvariants are the invariants checked against the metrics scraped from each node at
+	// the end of the run.
+	metricsInvariants []metricsInvariant
+
 	consensusPruneDisabledProbability float32
 	consensusPruneMinKept             int64
 	consensusPruneMaxKept             int64
@@ -225,6 +332,19 @@ func (sc *txSourceImpl) Fixture() (*oasis.NetworkFixture, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Nodes are funded from deterministic accounts and each validator needs its own entity.
+	if sc.numValidatorNodes < 1 || sc.numValidatorNodes > len(txSourceValidatorAccounts) {
+		return nil, fmt.Errorf("number of validator nodes must be between 1 and %d", len(txSourceValidatorAccounts))
+	}
+	minComputeNodes := 2
+	if sc.nodeLongRestartInterval > 0 {
+		// One additional executor can be offline.
+		minComputeNodes++
+	}
+	if sc.numComputeNodes < minComputeNodes || sc.numComputeNodes > len(txSourceComputeAccounts) {
+		return nil, fmt.Errorf("number of compute nodes must be between %d and %d", minComputeNodes, len(txSourceComputeAccounts))
+	}
+
 	// Use deterministic identities as we need to allocate funds to nodes.
 	f.Network.DeterministicIdentities = true
 	f.Network.GovernanceParameters = &governance.ConsensusParameters{
@@ -266,53 +386,7 @@ func (sc *txSourceImpl) Fixture() (*oasis.NetworkFixture, error) {
 				staking.KindRuntimeKeyManager: *quantity.NewFromUint64(100),
 			},
 		},
-		TotalSupply: *quantity.NewFromUint64(150000001400),
 		Ledger: map[staking.Address]*staking.Account{
-			e2e.DeterministicValidator0: {
-				General: staking.GeneralAccount{
-					Balance: *quantity.NewFromUint64(10000000000),
-				},
-			},
-			e2e.DeterministicValidator1: {
-				General: staking.GeneralAccount{
-					Balance: *quantity.NewFromUint64(10000000000),
-				},
-			},
-			e2e.DeterministicValidator2: {
-				General: staking.GeneralAccount{
-					Balance: *quantity.NewFromUint64(10000000000),
-				},
-			},
-			e2e.DeterministicValidator3: {
-				General: staking.GeneralAccount{
-					Balance: *quantity.NewFromUint64(10000000000),
-				},
-			},
-			e2e.DeterministicCompute0: {
-				General: staking.GeneralAccount{
-					Balance: *quantity.NewFromUint64(10000000000),
-				},
-			},
-			e2e.DeterministicCompute1: {
-				General: staking.GeneralAccount{
-					Balance: *quantity.NewFromUint64(10000000000),
-				},
-			},
-			e2e.DeterministicCompute2: {
-				General: staking.GeneralAccount{
-					Balance: *quantity.NewFromUint64(10000000000),
-				},
-			},
-			e2e.DeterministicCompute3: {
-				General: staking.GeneralAccount{
-					Balance: *quantity.NewFromUint64(10000000000),
-				},
-			},
-			e2e.DeterministicCompute4: {
-				General: staking.GeneralAccount{
-					Balance: *quantity.NewFromUint64(10000000000),
-				},
-			},
 			e2e.DeterministicStorage0: {
 				General: staking.GeneralAccount{
 					Balance: *quantity.NewFromUint64(10000000000),
@@ -415,6 +489,30 @@ func (sc *txSourceImpl) Fixture() (*oasis.NetworkFixture, error) {
 			},
 		},
 	}
+	// Fund the requested validator and compute nodes.
+	for _, addr := range txSourceValidatorAccounts[:sc.numValidatorNodes] {
+		f.Network.StakingGenesis.Ledger[addr] = &staking.Account{
+			General: staking.GeneralAccount{
+				Balance: *quantity.NewFromUint64(txSourceNodeBalance),
+			},
+		}
+	}
+	for _, addr := range txSourceComputeAccounts[:sc.numComputeNodes] {
+		f.Network.StakingGenesis.Ledger[addr] = &staking.Account{
+			General: staking.GeneralAccount{
+				Balance: *quantity.NewFromUint64(txSourceNodeBalance),
+			},
+		}
+	}
+	for _, acct := range f.Network.StakingGenesis.Ledger {
+		if err = f.Network.StakingGenesis.TotalSupply.Add(&acct.General.Balance); err != nil {
+			return nil, fmt.Errorf("failed to compute total supply: %w", err)
+		}
+		if err = f.Network.StakingGenesis.TotalSupply.Add(&acct.Escrow.Active.Balance); err != nil {
+			return nil, fmt.Errorf("failed to compute total supply: %w", err)
+		}
+	}
+
 	f.Entities = []oasis.EntityCfg{
 		{IsDebugTestEntity: true},
 	}
@@ -452,7 +550,7 @@ func (sc *txSourceImpl) Fixture() (*oasis.NetworkFixture, error) {
 		f.Runtimes[1].Executor.RoundTimeout = 10
 	}
 
-	if sc.nodeRestartInterval > 0 || sc.nodeLongRestartInterval > 0 {
+	if sc.nodeRestartInterval > 0 || sc.nodeLongRestartInterval > 0 || sc.nodeRestartHeightInterval > 0 {
 		// If node restarts enabled, do not enable round timeouts, failures or
 		// discrepancy log watchers.
 		f.Network.DefaultLogWatcherHandlerFactories = []log.WatcherHandlerFactory{}
@@ -480,6 +578,13 @@ func (sc *txSourceImpl) Fixture() (*oasis.NetworkFixture, error) {
 			Runtimes: []int{1},
 		})
 	}
+	if sc.checkpointSyncCheckTime > 0 {
+		// The last compute node will restore its state from a checkpoint after being wiped.
+		computeWorkers[len(computeWorkers)-1].CheckpointSyncEnabled = true
+		computeWorkers[len(computeWorkers)-1].LogWatcherHandlerFactories = []log.WatcherHandlerFactory{
+			oasis.LogAssertCheckpointSync(),
+		}
+	}
 	f.ComputeWorkers = computeWorkers
 	var clients []oasis.ClientFixture
 	for i := 0; i < sc.numClientNodes; i++ {
@@ -535,6 +640,11 @@ func (sc *txSourceImpl) Fixture() (*oasis.NetworkFixture, error) {
 		sc.generateConsensusFixture(&f.ByzantineNodes[i].Consensus, false)
 	}
 
+	f.Network.NodeResourceLimits = sc.nodeResourceLimits
+	if len(sc.metricsInvariants) > 0 {
+		f.Network.Metrics.Pull = true
+	}
+
 	return f, nil
 }
 
@@ -547,11 +657,17 @@ func (sc *txSourceImpl) manager(ctx context.Context, env *env.Env, errCh chan er
 		close(stopCh)
 	})
 
-	if sc.nodeRestartInterval > 0 {
+	switch {
+	case sc.nodeRestartHeightInterval > 0:
+		sc.Logger.Info("height-based random node restarts enabled",
+			"restart_height_interval", sc.nodeRestartHeightInterval,
+		)
+		sc.nodeRestartInterval = math.MaxInt64
+	case sc.nodeRestartInterval > 0:
 		sc.Logger.Info("random node restarts enabled",
 			"restart_interval", sc.nodeRestartInterval,
 		)
-	} else {
+	default:
 		sc.nodeRestartInterval = math.MaxInt64
 	}
 	if sc.nodeLongRestartInterval > 0 {
@@ -562,6 +678,14 @@ func (sc *txSourceImpl) manager(ctx context.Context, env *env.Env, errCh chan er
 	} else {
 		sc.nodeLongRestartInterval = math.MaxInt64
 	}
+	if sc.checkpointSyncCheckTime > 0 {
+		sc.Logger.Info("checkpoint sync check enabled",
+			"check_time", sc.checkpointSyncCheckTime,
+			"timeout", sc.checkpointSyncTimeout,
+		)
+	} else {
+		sc.checkpointSyncCheckTime = math.MaxInt64
+	}
 
 	// Setup restarable nodes.
 	var restartableLock sync.Mutex
@@ -587,46 +711,84 @@ func (sc *txSourceImpl) manager(ctx context.Context, env *env.Env, errCh chan er
 	longRestartTicker := time.NewTicker(sc.nodeLongRestartInterval)
 	defer longRestartTicker.Stop()
 
+	checkpointSyncTimer := time.NewTimer(sc.checkpointSyncCheckTime)
+	defer checkpointSyncTimer.Stop()
+
+	// Watch consensus blocks to restart nodes at exact heights.
+	var restartBlkCh <-chan *consensus.Block
+	if sc.nodeRestartHeightInterval > 0 {
+		blkCh, blkSub, err := sc.Net.Controller().Consensus.WatchBlocks(ctx)
+		if err != nil {
+			sc.Logger.Error("failed to watch consensus blocks",
+				"err", err,
+			)
+			errCh <- err
+			return
+		}
+		defer blkSub.Close()
+		restartBlkCh = blkCh
+	}
+
 	var nodeIndex int
+	restartNextNode := func() {
+		restartableLock.Lock()
+		defer restartableLock.Unlock()
+
+		// Reshuffle nodes each time the counter wraps around.
+		if nodeIndex == 0 {
+			sc.rng.Shuffle(len(restartableNodes), func(i, j int) {
+				restartableNodes[i], restartableNodes[j] = restartableNodes[j], restartableNodes[i]
+			})
+		}
+		// Ensure the current node is not being restarted already.
+		if longRestartNode != nil && restartableNodes[nodeIndex].NodeID.Equal(longRestartNode.NodeID) {
+			nodeIndex = (nodeIndex + 1) % len(restartableNodes)
+		}
+
+		// Choose a random node and restart it.
+		node := restartableNodes[nodeIndex]
+		sc.Logger.Info("restarting node",
+			"node", node.Name,
+		)
+		if err := node.Restart(ctx); err != nil {
+			sc.Logger.Error("failed to restart node",
+				"node", node.Name,
+				"err", err,
+			)
+			errCh <- err
+			return
+		}
+		sc.Logger.Info("node restarted",
+			"node", node.Name,
+		)
+		nodeIndex = (nodeIndex + 1) % len(restartableNodes)
+	}
+
 	var lastHeight int64
+	nextRestartHeight := sc.nodeRestartHeightInterval
 	for {
 		select {
 		case <-stopCh:
 			return
 		case <-restartTicker.C:
-			func() {
-				restartableLock.Lock()
-				defer restartableLock.Unlock()
+			restartNextNode()
+		case blk, ok := <-restartBlkCh:
+			if !ok {
+				errCh <- fmt.Errorf("consensus block subscription closed")
+				return
+			}
+			if blk.Height < nextRestartHeight {
+				continue
+			}
 
-				// Reshuffle nodes each time the counter wraps around.
-				if nodeIndex == 0 {
-					sc.rng.Shuffle(len(restartableNodes), func(i, j int) {
-						restartableNodes[i], restartableNodes[j] = restartableNodes[j], restartableNodes[i]
-					})
-				}
-				// Ensure the current node is not being restarted already.
-				if longRestartNode != nil && restartableNodes[nodeIndex].NodeID.Equal(longRestartNode.NodeID) {
-					nodeIndex = (nodeIndex + 1) % len(restartableNodes)
-				}
+			sc.Logger.Info("restart height reached",
+				"restart_height", nextRestartHeight,
+				"height", blk.Height,
+			)
+			restartNextNode()
 
-				// Choose a random node and restart it.
-				node := restartableNodes[nodeIndex]
-				sc.Logger.Info("restarting node",
-					"node", node.Name,
-				)
-				if err := node.Restart(ctx); err != nil {
-					sc.Logger.Error("failed to restart node",
-						"node", node.Name,
-						"err", err,
-					)
-					errCh <- err
-					return
-				}
-				sc.Logger.Info("node restarted",
-					"node", node.Name,
-				)
-				nodeIndex = (nodeIndex + 1) % len(restartableNodes)
-			}()
+			// Restart at most one node per interval, skipping heights passed during the restart.
+			nextRestartHeight = (blk.Height/sc.nodeRestartHeightInterval + 1) * sc.nodeRestartHeightInterval
 		case <-longRestartTicker.C:
 			// Choose a random node and restart it.
 			restartableLock.Lock()
@@ -664,7 +826,48 @@ func (sc *txSourceImpl) manager(ctx context.Context, env *env.Env, errCh chan er
 				restartableLock.Unlock()
 			}()
 
+		case <-checkpointSyncTimer.C:
+			computeWorkers := sc.Net.ComputeWorkers()
+			node := computeWorkers[len(computeWorkers)-1].Node
+
+			restartableLock.Lock()
+			if longRestartNode != nil {
+				sc.Logger.Info("node already stopped, postponing checkpoint sync check",
+					"node", longRestartNode,
+				)
+				checkpointSyncTimer.Reset(sc.livenessCheckInterval)
+				restartableLock.Unlock()
+				continue
+			}
+			// Prevent the node from being restarted while its state is wiped.
+			longRestartNode = node
+			restartableLock.Unlock()
+
+			go func() {
+				if err := sc.checkCheckpointSync(ctx, env, node); err != nil {
+					sc.Logger.Error("checkpoint sync check failed",
+						"node", node.Name,
+						"err", err,
+					)
+					errCh <- err
+					return
+				}
+
+				restartableLock.Lock()
+				longRestartNode = nil
+				restartableLock.Unlock()
+			}()
+
 		case <-livenessTicker.C:
+			// Check if any node exceeds its memory ceiling.
+			if err := sc.checkResidentMemory(&restartableLock); err != nil {
+				sc.Logger.Error("node resource check failed",
+					"err", err,
+				)
+				errCh <- err
+				return
+			}
+
 			// Check if consensus has made any progress.
 			livenessCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 			blk, err := sc.Net.Controller().Consensus.GetBlock(livenessCtx, consensus.HeightLatest)
@@ -765,6 +968,109 @@ func (sc *txSourceImpl) manager(ctx context.Context, env *env.Env, errCh chan er
 	}
 }
 
+func (sc *txSourceImpl) checkCheckpointSync(ctx context.Context, childEnv *env.Env, node *oasis.Node) error {
+	sc.Logger.Info("wiping node state to verify checkpoint sync",
+		"node", node.Name,
+	)
+	if err := node.Stop(); err != nil {
+		return fmt.Errorf("failed to stop node: %w", err)
+	}
+	if err := cli.New(childEnv, sc.Net, sc.Logger).UnsafeReset(node.DataDir(), false, false, true); err != nil {
+		return fmt.Errorf("failed to reset node state: %w", err)
+	}
+
+	// The node may have restored its state from a checkpoint before, so only check the log
+	// emitted after the restart.
+	fi, err := os.Stat(node.LogPath())
+	if err != nil {
+		return fmt.Errorf("failed to stat node log: %w", err)
+	}
+	if err = node.Start(); err != nil {
+		return fmt.Errorf("failed to start node: %w", err)
+	}
+
+	// Node only becomes ready after it has attempted to restore the checkpoint and caught up.
+	syncCtx, cancel := context.WithTimeout(ctx, sc.checkpointSyncTimeout)
+	defer cancel()
+	if err = node.WaitReady(syncCtx); err != nil {
+		return fmt.Errorf("node failed to catch up after checkpoint sync: %w", err)
+	}
+
+	// Fail if the node replayed the state instead of restoring it from a checkpoint.
+	if err = assertLogSince(node.LogPath(), fi.Size(), oasis.LogAssertCheckpointSync()); err != nil {
+		return fmt.Errorf("node did not restore its state from a checkpoint: %w", err)
+	}
+
+	sc.Logger.Info("node caught up after checkpoint sync",
+		"node", node.Name,
+	)
+	return nil
+}
+
+// assertLogSince runs the given log assertion over the lines of the given log file following
+// the given offset.
+func assertLogSince(path string, offset int64, factory log.WatcherHandlerFactory) error {
+	handler, err := factory.New()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			if err := handler.Line(strings.TrimSuffix(line, "\n")); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return handler.Finish()
+}
+
+func (sc *txSourceImpl) checkResidentMemory(restartableLock *sync.Mutex) error {
+	if sc.nodeMaxResidentMemory == 0 {
+		return nil
+	}
+
+	// Prevent nodes from being restarted while sampling.
+	restartableLock.Lock()
+	defer restartableLock.Unlock()
+
+	for _, node := range sc.Net.Nodes() {
+		rss, err := node.ResidentMemory()
+		if err != nil {
+			// Node may not be running (e.g., due to a long restart or a crash point).
+			sc.Logger.Warn("failed to sample node resident memory",
+				"node", node.Name,
+				"err", err,
+			)
+			continue
+		}
+		if rss > sc.nodeMaxResidentMemory {
+			return fmt.Errorf("node %s resident memory (%d bytes) exceeds the limit (%d bytes)",
+				node.Name, rss, sc.nodeMaxResidentMemory,
+			)
+		}
+	}
+	return nil
+}
+
 func (sc *txSourceImpl) startWorkload(childEnv *env.Env, errCh chan error, name string, node *oasis.Node) error {
 	sc.Logger.Info("starting workload",
 		"name", name,
@@ -808,6 +1114,14 @@ func (sc *txSourceImpl) startWorkload(childEnv *env.Env, errCh chan error, name
 	if node.Name != sc.Net.Clients()[0].Name {
 		args = append(args, "--"+workload.CfgQueriesRuntimeEnabled+"=false")
 	}
+	if name == workload.NameQueries {
+		args = append(args, queriesQuerySetArgs(sc.queriesQuerySet)...)
+	}
+	weightArgs, err := workloadWeightArgs(name, sc.workloadWeights)
+	if err != nil {
+		return err
+	}
+	args = append(args, weightArgs...)
 	nodeBinary := sc.Net.Config().NodeBinary
 
 	cmd := exec.Command(nodeBinary, args...)
@@ -833,7 +1147,15 @@ func (sc *txSourceImpl) startWorkload(childEnv *env.Env, errCh chan error, name
 
 	go func() {
 		waitErr := cmd.Wait()
-		errCh <- waitErr
+		if waitErr != nil {
+			errCh <- &workloadError{
+				name: name,
+				node: node.Name,
+				err:  waitErr,
+			}
+		} else {
+			errCh <- nil
+		}
 
 		sc.Logger.Info("workload finished",
 			"name", name,
@@ -845,16 +1167,77 @@ func (sc *txSourceImpl) startWorkload(childEnv *env.Env, errCh chan error, name
 	return nil
 }
 
+// workloadError is the error returned when a workload process exits with a failure, making it
+// distinguishable from scenario infrastructure errors.
+type workloadError struct {
+	name string
+	node string
+	err  error
+}
+
+// Error implements the error interface.
+func (e *workloadError) Error() string {
+	return fmt.Sprintf("workload %s on node %s failed: %s", e.name, e.node, e.err)
+}
+
+// Unwrap returns the underlying workload process error.
+func (e *workloadError) Unwrap() error {
+	return e.err
+}
+
+// queriesQuerySetArgs returns the queries workload arguments configuring the given query set.
+func queriesQuerySetArgs(querySet map[string]float64) []string {
+	if len(querySet) == 0 {
+		return nil
+	}
+
+	kinds := make([]string, 0, len(querySet))
+	for kind := range querySet {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	entries := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		entries = append(entries, kind+"="+strconv.FormatFloat(querySet[kind], 'g', -1, 64))
+	}
+	return []string{"--" + workload.CfgQueriesQuerySet, strings.Join(entries, ",")}
+}
+
+// workloadWeightArgs returns the workload arguments configuring the weight of the given workload,
+// failing if the weight is invalid or the workload doesn't support weights.
+func workloadWeightArgs(name string, weights map[string]int) ([]string, error) {
+	weight, ok := weights[name]
+	switch {
+	case !ok:
+		return nil, nil
+	case weight < 1:
+		return nil, fmt.Errorf("invalid weight of workload %s: %d", name, weight)
+	case !workload.SupportsWeight(name):
+		return nil, fmt.Errorf("workload %s doesn't support weights", name)
+	}
+	return []string{"--" + workload.CfgWeight, strconv.Itoa(weight)}, nil
+}
+
 func (sc *txSourceImpl) Clone() scenario.Scenario {
 	return &txSourceImpl{
 		Scenario:                          *sc.Scenario.Clone().(*Scenario),
 		clientWorkloads:                   sc.clientWorkloads,
 		allNodeWorkloads:                  sc.allNodeWorkloads,
+		queriesQuerySet:                   sc.queriesQuerySet,
+		workloadWeights:                   sc.workloadWeights,
 		timeLimit:                         sc.timeLimit,
 		nodeRestartInterval:               sc.nodeRestartInterval,
 		nodeLongRestartDuration:           sc.nodeLongRestartDuration,
 		nodeLongRestartInterval:           sc.nodeLongRestartInterval,
 		livenessCheckInterval:             sc.livenessCheckInterval,
+		nodeRestartHeightInterval:         sc.nodeRestartHeightInterval,
+		workloadStartJitter:               sc.workloadStartJitter,
+		checkpointSyncCheckTime:           sc.checkpointSyncCheckTime,
+		checkpointSyncTimeout:             sc.checkpointSyncTimeout,
+		nodeResourceLimits:                sc.nodeResourceLimits,
+		nodeMaxResidentMemory:             sc.nodeMaxResidentMemory,
+		metricsInvariants:                 append([]metricsInvariant{}, sc.metricsInvariants...),
 		consensusPruneDisabledProbability: sc.consensusPruneDisabledProbability,
 		consensusPruneMinKept:             sc.consensusPruneMinKept,
 		consensusPruneMaxKept:             sc.consensusPruneMaxKept,
@@ -884,19 +1267,41 @@ func (sc *txSourceImpl) Run(ctx context.Context, childEnv *env.Env) error {
 		return fmt.Errorf("WaitNodesRegistered: %w", err)
 	}
 
-	// Start all configured workloads.
-	errCh := make(chan error, len(sc.clientWorkloads)+len(sc.allNodeWorkloads)+2)
+	// Start all configured workloads, spreading them over the start jitter interval.
+	type workloadStart struct {
+		name   string
+		node   *oasis.Node
+		offset time.Duration
+	}
+	var starts []workloadStart
 	for _, name := range sc.clientWorkloads {
-		if err := sc.startWorkload(childEnv, errCh, name, sc.Net.Clients()[0].Node); err != nil {
-			return fmt.Errorf("failed to start client workload %s: %w", name, err)
-		}
+		starts = append(starts, workloadStart{name: name, node: sc.Net.Clients()[0].Node})
 	}
 	nodes := sc.Net.Nodes()
 	for _, name := range sc.allNodeWorkloads {
 		for _, node := range nodes {
-			if err := sc.startWorkload(childEnv, errCh, name, node); err != nil {
-				return fmt.Errorf("failed to start workload %s on node %s: %w", name, node.Name, err)
-			}
+			starts = append(starts, workloadStart{name: name, node: node})
+		}
+	}
+	if sc.workloadStartJitter > 0 {
+		for i := range starts {
+			starts[i].offset = time.Duration(sc.rng.Int63n(int64(sc.workloadStartJitter)))
+		}
+		sort.SliceStable(starts, func(i, j int) bool {
+			return starts[i].offset < starts[j].offset
+		})
+	}
+
+	errCh := make(chan error, len(sc.clientWorkloads)+len(sc.allNodeWorkloads)+2)
+	startTime := time.Now()
+	for _, ws := range starts {
+		select {
+		case <-time.After(time.Until(startTime.Add(ws.offset))):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := sc.startWorkload(childEnv, errCh, ws.name, ws.node); err != nil {
+			return fmt.Errorf("failed to start workload %s on node %s: %w", ws.name, ws.node.Name, err)
 		}
 	}
 	// Start background scenario manager.
@@ -906,9 +1311,29 @@ func (sc *txSourceImpl) Run(ctx context.Context, childEnv *env.Env) error {
 	var err error
 	select {
 	case err = <-sc.Net.Errors():
+		if err != nil {
+			err = fmt.Errorf("network error: %w", err)
+		}
 	case err = <-errCh:
 	}
 	if err != nil {
+		var wErr *workloadError
+		if errors.As(err, &wErr) {
+			sc.Logger.Error("workload failed",
+				"name", wErr.name,
+				"node", wErr.node,
+				"err", wErr.err,
+			)
+		} else {
+			sc.Logger.Error("scenario infrastructure failure",
+				"err", err,
+			)
+		}
+		return err
+	}
+
+	// Check metrics invariants on all nodes.
+	if err = sc.checkMetrics(ctx, childEnv); err != nil {
 		return err
 	}
 
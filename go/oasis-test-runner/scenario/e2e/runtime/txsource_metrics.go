@@ -0,0 +1,152 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/oasisprotocol/oasis-core/go/oasis-test-runner/env"
+)
+
+// metricsInvariant is an invariant checked against the metrics scraped from each node at the end
+// of a txsource run.
+type metricsInvariant struct {
+	// name is the human readable name of the invariant.
+	name string
+	// check verifies the invariant against the scraped metric families.
+	check func(families map[string]*dto.MetricFamily) error
+}
+
+// metricMaxValue returns an invariant which is violated if any sample of the given counter or
+// gauge exceeds the given maximum value. Missing metrics are ignored.
+func metricMaxValue(name string, max float64) metricsInvariant {
+	return metricsInvariant{
+		name: fmt.Sprintf("%s <= %v", name, max),
+		check: func(families map[string]*dto.MetricFamily) error {
+			family, ok := families[name]
+			if !ok {
+				return nil
+			}
+			for _, m := range family.GetMetric() {
+				var value float64
+				switch {
+				case m.GetCounter() != nil:
+					value = m.GetCounter().GetValue()
+				case m.GetGauge() != nil:
+					value = m.GetGauge().GetValue()
+				default:
+					return fmt.Errorf("metric %s is not a counter or a gauge", name)
+				}
+				if value > max {
+					return fmt.Errorf("metric %s has value %v", name, value)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// metricMaxAverage returns an invariant which is violated if the average of any sample of the
+// given histogram or summary exceeds the given maximum value. Missing metrics are ignored.
+func metricMaxAverage(name string, max float64) metricsInvariant {
+	return metricsInvariant{
+		name: fmt.Sprintf("avg(%s) <= %v", name, max),
+		check: func(families map[string]*dto.MetricFamily) error {
+			family, ok := families[name]
+			if !ok {
+				return nil
+			}
+			for _, m := range family.GetMetric() {
+				var (
+					sum   float64
+					count uint64
+				)
+				switch {
+				case m.GetHistogram() != nil:
+					sum, count = m.GetHistogram().GetSampleSum(), m.GetHistogram().GetSampleCount()
+				case m.GetSummary() != nil:
+					sum, count = m.GetSummary().GetSampleSum(), m.GetSummary().GetSampleCount()
+				default:
+					return fmt.Errorf("metric %s is not a histogram or a summary", name)
+				}
+				if count == 0 {
+					continue
+				}
+				if avg := sum / float64(count); avg > max {
+					return fmt.Errorf("metric %s has average %v", name, avg)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func scrapeMetrics(ctx context.Context, addr string) ([]byte, map[string]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+"/metrics", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	return raw, families, nil
+}
+
+func (sc *txSourceImpl) checkMetrics(ctx context.Context, childEnv *env.Env) error {
+	if len(sc.metricsInvariants) == 0 {
+		return nil
+	}
+
+	for _, node := range sc.Net.Nodes() {
+		addr := node.MetricsAddress()
+		if addr == "" {
+			continue
+		}
+
+		raw, families, err := scrapeMetrics(ctx, addr)
+		if err != nil {
+			return fmt.Errorf("failed to scrape metrics of node %s: %w", node.Name, err)
+		}
+
+		// Store the scraped metrics so that failures can be diagnosed.
+		path := filepath.Join(childEnv.Dir(), fmt.Sprintf("metrics-%s.txt", node.Name))
+		if err = os.WriteFile(path, raw, 0o600); err != nil {
+			return fmt.Errorf("failed to store metrics of node %s: %w", node.Name, err)
+		}
+
+		for _, inv := range sc.metricsInvariants {
+			if err = inv.check(families); err != nil {
+				sc.Logger.Error("node violated metrics invariant",
+					"node", node.Name,
+					"invariant", inv.name,
+					"err", err,
+				)
+				return fmt.Errorf("node %s violated metrics invariant '%s': %w", node.Name, inv.name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oasisprotocol/oasis-core/go/oasis-node/cmd/debug/txsource/workload"
+	"github.com/oasisprotocol/oasis-core/go/oasis-test-runner/oasis"
+)
+
+func TestQueriesQuerySetArgs(t *testing.T) {
+	require := require.New(t)
+
+	require.Empty(queriesQuerySetArgs(nil), "empty query set should not configure the workload")
+
+	querySet := map[string]float64{
+		workload.QueryKindStaking:   0.5,
+		workload.QueryKindConsensus: 1,
+		workload.QueryKindRegistry:  0.25,
+	}
+	args := queriesQuerySetArgs(querySet)
+	require.Equal([]string{
+		"--" + workload.CfgQueriesQuerySet, "consensus=1,registry=0.25,staking=0.5",
+	}, args)
+
+	// Ensure the workload command parses the arguments into the configured query set.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	raw := fs.StringToString(workload.CfgQueriesQuerySet, nil, "")
+	err := fs.Parse(args)
+	require.NoError(err, "Parse")
+
+	parsed, err := workload.ParseQuerySet(*raw)
+	require.NoError(err, "ParseQuerySet")
+	require.Equal(querySet, parsed)
+}
+
+func TestWorkloadWeightArgs(t *testing.T) {
+	require := require.New(t)
+
+	weights := map[string]int{
+		workload.NameParallel: 3,
+		workload.NameQueries:  0,
+		workload.NameTransfer: 2,
+	}
+
+	args, err := workloadWeightArgs(workload.NameParallel, weights)
+	require.NoError(err, "workloadWeightArgs")
+	require.Equal([]string{"--" + workload.CfgWeight, "3"}, args)
+
+	args, err = workloadWeightArgs(workload.NameRuntime, weights)
+	require.NoError(err, "workloadWeightArgs")
+	require.Empty(args, "workloads without a weight should not be configured")
+
+	_, err = workloadWeightArgs(workload.NameQueries, weights)
+	require.Error(err, "invalid weights should be rejected")
+
+	_, err = workloadWeightArgs(workload.NameTransfer, weights)
+	require.Error(err, "weights of workloads which don't support them should be rejected")
+}
+
+func TestAssertLogSince(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "node.log")
+	before := `{"msg":"checkpoint sync succeeded","log_event":"worker/storage/checkpoint-sync-success"}` + "\n"
+	after := `{"msg":"checkpoint sync failed"}` + "\n"
+	err := os.WriteFile(path, []byte(before+after), 0o600)
+	require.NoError(err, "WriteFile")
+
+	err = assertLogSince(path, 0, oasis.LogAssertCheckpointSync())
+	require.NoError(err, "assertLogSince should find events in the whole log")
+
+	err = assertLogSince(path, int64(len(before)), oasis.LogAssertCheckpointSync())
+	require.Error(err, "assertLogSince should ignore events before the offset")
+}
@@ -2,25 +2,31 @@ package rpc
 
 import (
 	"context"
+	cryptorand "crypto/rand"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/libp2p/go-libp2p/core"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/protocol"
 
 	"github.com/oasisprotocol/oasis-core/go/common/cbor"
 	commonErrors "github.com/oasisprotocol/oasis-core/go/common/errors"
 	"github.com/oasisprotocol/oasis-core/go/common/logging"
 	"github.com/oasisprotocol/oasis-core/go/common/workerpool"
+	cmmetrics "github.com/oasisprotocol/oasis-core/go/oasis-node/cmd/common/metrics"
 )
 
 const (
-	// RequestWriteDeadline is the maximum amount of time that can be spent on writing a request.
+	// RequestWriteDeadline is the default maximum amount of time that can be spent on writing
+	// a request. It can be overridden by using the WithWriteDeadline call option.
 	RequestWriteDeadline = 5 * time.Second
 	// RequestReadDeadline is the maximum amount of time that can be spent on reading a request.
 	RequestReadDeadline = 5 * time.Second
@@ -31,6 +37,8 @@ const (
 	// DefaultParallelRequests is the default number of parallel requests that can be mande
 	// when calling multiple peers.
 	DefaultParallelRequests = 5
+	// RequestNonceSize is the size of the request nonce in bytes.
+	RequestNonceSize = 32
 )
 
 // PeerFeedback is an interface for providing deferred peer feedback after an outcome is known.
@@ -48,30 +56,38 @@ type PeerFeedback interface {
 
 	// PeerID returns the id of the peer.
 	PeerID() core.PeerID
+
+	// Latency returns the latency of the protocol interaction with the peer.
+	Latency() time.Duration
 }
 
 type peerFeedback struct {
 	client  *client
 	peerID  core.PeerID
+	method  string
 	latency time.Duration
 }
 
 func (pf *peerFeedback) RecordSuccess() {
-	pf.client.recordSuccess(pf.peerID, pf.latency)
+	pf.client.recordSuccess(pf.peerID, pf.method, pf.latency)
 }
 
 func (pf *peerFeedback) RecordFailure() {
-	pf.client.recordFailure(pf.peerID, pf.latency)
+	pf.client.recordFailure(pf.peerID, pf.method, pf.latency)
 }
 
 func (pf *peerFeedback) RecordBadPeer() {
-	pf.client.recordBadPeer(pf.peerID)
+	pf.client.recordBadPeer(pf.peerID, pf.method)
 }
 
 func (pf *peerFeedback) PeerID() core.PeerID {
 	return pf.peerID
 }
 
+func (pf *peerFeedback) Latency() time.Duration {
+	return pf.latency
+}
+
 type nopPeerFeedback struct{}
 
 func (pf *nopPeerFeedback) RecordSuccess() {
@@ -87,26 +103,75 @@ func (pf *nopPeerFeedback) PeerID() core.PeerID {
 	return ""
 }
 
+func (pf *nopPeerFeedback) Latency() time.Duration {
+	return 0
+}
+
 // NewNopPeerFeedback creates a no-op peer feedback instance.
 func NewNopPeerFeedback() PeerFeedback {
 	return &nopPeerFeedback{}
 }
 
+// RetryableFunc is a function which classifies whether a failed call should be retried.
+type RetryableFunc func(err error) bool
+
 // ValidationFunc is a call response validation function.
 type ValidationFunc func(pf PeerFeedback) error
 
+// AttemptRecorderFunc is a function which is called for each peer attempted by a call.
+type AttemptRecorderFunc func(peer core.PeerID, err error)
+
+type nonceMode uint8
+
+const (
+	nonceModeNone nonceMode = iota
+	nonceModeStable
+	nonceModeFresh
+)
+
 // CallOptions are per-call options.
 type CallOptions struct {
 	maxPeerResponseTime time.Duration
+	writeDeadline       time.Duration
 	retryInterval       time.Duration
 	maxRetries          uint64
+	expBackoff          *exponentialBackoff
+	retryableFn         RetryableFunc
 	validationFn        ValidationFunc
+	nonceMode           nonceMode
+	minResponseSpeed    uint64
+	peerLessFn          func(a, b core.PeerID) bool
+	compression         CompressionCodec
+	attemptRecorderFn   AttemptRecorderFunc
+}
+
+// exponentialBackoff are the exponential backoff settings.
+type exponentialBackoff struct {
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	multiplier      float64
+}
+
+// newBackOff creates a new backoff policy used between call retries.
+func (co *CallOptions) newBackOff() backoff.BackOff {
+	if co.expBackoff == nil {
+		return backoff.NewConstantBackOff(co.retryInterval)
+	}
+
+	eb := backoff.NewExponentialBackOff()
+	eb.InitialInterval = co.expBackoff.initialInterval
+	eb.MaxInterval = co.expBackoff.maxInterval
+	eb.Multiplier = co.expBackoff.multiplier
+	eb.MaxElapsedTime = 0 // Retries are bounded by the maximum number of retries.
+	eb.Reset()
+	return eb
 }
 
 // NewCallOptions creates options using default and given values.
 func NewCallOptions(opts ...CallOption) *CallOptions {
 	co := CallOptions{
 		maxPeerResponseTime: RequestReadDeadline,
+		writeDeadline:       RequestWriteDeadline,
 		retryInterval:       DefaultCallRetryInterval,
 	}
 	for _, opt := range opts {
@@ -125,6 +190,13 @@ func WithMaxPeerResponseTime(d time.Duration) CallOption {
 	}
 }
 
+// WithWriteDeadline configures the maximum amount of time that can be spent on writing a request.
+func WithWriteDeadline(d time.Duration) CallOption {
+	return func(opts *CallOptions) {
+		opts.writeDeadline = d
+	}
+}
+
 // WithMaxRetries configures the maximum number of retries to use for the call.
 func WithMaxRetries(maxRetries uint64) CallOption {
 	return func(opts *CallOptions) {
@@ -139,6 +211,49 @@ func WithRetryInterval(retryInterval time.Duration) CallOption {
 	}
 }
 
+// WithExponentialBackoff configures exponential backoff with jitter between call retries,
+// starting with the given initial interval which is multiplied by the given multiplier after
+// each retry, up to the given maximum interval. The number of retries is still bounded by
+// WithMaxRetries.
+//
+// If not set, constant backoff with the interval configured by WithRetryInterval is used.
+func WithExponentialBackoff(initialInterval, maxInterval time.Duration, multiplier float64) CallOption {
+	return func(opts *CallOptions) {
+		opts.expBackoff = &exponentialBackoff{
+			initialInterval: initialInterval,
+			maxInterval:     maxInterval,
+			multiplier:      multiplier,
+		}
+	}
+}
+
+// WithRetryableFn configures the function used to classify whether a failed call should be
+// retried. Calls failing with errors which the function rejects are not retried.
+//
+// If not set, all failed calls are retried.
+func WithRetryableFn(fn RetryableFunc) CallOption {
+	return func(opts *CallOptions) {
+		opts.retryableFn = fn
+	}
+}
+
+// WithCompression configures the call to request the response body to be compressed using
+// the given codec. Peers which don't support response compression respond uncompressed.
+func WithCompression(codec CompressionCodec) CallOption {
+	return func(opts *CallOptions) {
+		opts.compression = codec
+	}
+}
+
+// WithAttemptRecorder configures the function which is called for each peer attempted by CallOne,
+// in the order in which the peers were attempted. The error is nil iff the peer served the call
+// and its response passed validation.
+func WithAttemptRecorder(fn AttemptRecorderFunc) CallOption {
+	return func(opts *CallOptions) {
+		opts.attemptRecorderFn = fn
+	}
+}
+
 // WithValidationFn configures the response validation function to use for the call.
 //
 // When the function is called, the decoded response value will be set.
@@ -148,23 +263,74 @@ func WithValidationFn(fn ValidationFunc) CallOption {
 	}
 }
 
+// WithNonce configures the call to include a random request nonce which enables the server
+// to detect replayed requests.
+//
+// The same nonce is used for all attempts of the call, including retries.
+func WithNonce() CallOption {
+	return func(opts *CallOptions) {
+		opts.nonceMode = nonceModeStable
+	}
+}
+
+// WithFreshNoncePerAttempt configures the call to include a random request nonce which enables
+// the server to detect replayed requests.
+//
+// A fresh nonce is generated for each attempt of the call, including retries.
+func WithFreshNoncePerAttempt() CallOption {
+	return func(opts *CallOptions) {
+		opts.nonceMode = nonceModeFresh
+	}
+}
+
+// WithMinResponseSpeed configures the minimum speed (in bytes per second) at which peers must
+// send their responses once they start responding. Calls to peers which are slower fail with
+// ErrPeerTooSlow.
+func WithMinResponseSpeed(bytesPerSec uint64) CallOption {
+	return func(opts *CallOptions) {
+		opts.minResponseSpeed = bytesPerSec
+	}
+}
+
+// WithPeerSort configures the call to sort the given peers using the given less function before
+// trying them in order, which makes the order in which peers are tried deterministic even when
+// the peers were collected from a map.
+//
+// If not set, peers are tried in the order given by the caller.
+func WithPeerSort(less func(a, b core.PeerID) bool) CallOption {
+	return func(opts *CallOptions) {
+		opts.peerLessFn = less
+	}
+}
+
 // AggregateFunc returns a result aggregation function.
 //
 // The function is passed the response and PeerFeedback instance. If the function returns true, the
 // client will continue to call other peers. If it returns false, processing will stop.
 type AggregateFunc func(rsp interface{}, pf PeerFeedback) bool
 
+// FailureCollectorFunc is a function which is called for each peer which failed to serve a call.
+type FailureCollectorFunc func(peerID core.PeerID, err error)
+
 // CallMultiOptions are per-multicall options.
 type CallMultiOptions struct {
-	maxPeerResponseTime time.Duration
-	maxParallelRequests uint
-	aggregateFn         AggregateFunc
+	maxPeerResponseTime  time.Duration
+	maxPeerResponseTimes map[core.PeerID]time.Duration
+	writeDeadline        time.Duration
+	maxParallelRequests  uint
+	perPeerLimit         uint
+	orderedResults       bool
+	aggregateFn          AggregateFunc
+	failureCollectorFn   FailureCollectorFunc
+	deadlineMargin       time.Duration
+	responseFactory      func() interface{}
 }
 
 // NewCallMultiOptions creates options using default and given values.
 func NewCallMultiOptions(opts ...CallMultiOption) *CallMultiOptions {
 	co := CallMultiOptions{
 		maxPeerResponseTime: RequestReadDeadline,
+		writeDeadline:       RequestWriteDeadline,
 		maxParallelRequests: DefaultParallelRequests,
 	}
 	for _, opt := range opts {
@@ -183,6 +349,24 @@ func WithMaxPeerResponseTimeMulti(d time.Duration) CallMultiOption {
 	}
 }
 
+// WithMaxPeerResponseTimesMulti configures the maximum response times for individual peers.
+//
+// Peers without a configured response time use the value configured by
+// WithMaxPeerResponseTimeMulti.
+func WithMaxPeerResponseTimesMulti(times map[core.PeerID]time.Duration) CallMultiOption {
+	return func(opts *CallMultiOptions) {
+		opts.maxPeerResponseTimes = times
+	}
+}
+
+// WithWriteDeadlineMulti configures the maximum amount of time that can be spent on writing
+// a request to a peer.
+func WithWriteDeadlineMulti(d time.Duration) CallMultiOption {
+	return func(opts *CallMultiOptions) {
+		opts.writeDeadline = d
+	}
+}
+
 // WithMaxParallelRequests configures the maximum number of parallel requests to make.
 func WithMaxParallelRequests(n uint) CallMultiOption {
 	return func(opts *CallMultiOptions) {
@@ -190,6 +374,26 @@ func WithMaxParallelRequests(n uint) CallMultiOption {
 	}
 }
 
+// WithPerPeerLimit configures the maximum number of parallel requests to make to the same peer.
+//
+// Requests to a peer which already has the maximum number of requests in flight are only
+// submitted once one of those requests completes. Zero means no limit.
+func WithPerPeerLimit(n uint) CallMultiOption {
+	return func(opts *CallMultiOptions) {
+		opts.perPeerLimit = n
+	}
+}
+
+// WithOrderedResults configures the call to return results indexed to match the given peers,
+// with nil entries for peers which failed to serve the call (or whose results were not gathered).
+//
+// If not set, only successful results are returned, in the order in which they were received.
+func WithOrderedResults() CallMultiOption {
+	return func(opts *CallMultiOptions) {
+		opts.orderedResults = true
+	}
+}
+
 // WithAggregateFn configures the response aggregation function to use.
 func WithAggregateFn(fn AggregateFunc) CallMultiOption {
 	return func(opts *CallMultiOptions) {
@@ -197,6 +401,25 @@ func WithAggregateFn(fn AggregateFunc) CallMultiOption {
 	}
 }
 
+// WithDeadlineMargin configures the call to stop gathering results the given duration before the
+// deadline of the context, leaving the caller time to process the results gathered so far.
+func WithDeadlineMargin(d time.Duration) CallMultiOption {
+	return func(opts *CallMultiOptions) {
+		opts.deadlineMargin = d
+	}
+}
+
+// WithResponseFactory configures the function used to create the decode target for each peer's
+// response. The function must return a pointer to a fresh value on each invocation and may be
+// called concurrently.
+//
+// If not set, the decode target is created by reflecting on the response type given to the call.
+func WithResponseFactory(fn func() interface{}) CallMultiOption {
+	return func(opts *CallMultiOptions) {
+		opts.responseFactory = fn
+	}
+}
+
 // ClientListener is an interface for an object wishing to receive notifications from the client.
 type ClientListener interface {
 	// RecordSuccess is called on a successful protocol interaction with a peer.
@@ -209,10 +432,32 @@ type ClientListener interface {
 	RecordBadPeer(peerID core.PeerID)
 }
 
+// WithFailureCollector configures the function which is called for each peer which failed to
+// serve the call, together with the error. It is not called for peers whose results are no longer
+// being gathered.
+func WithFailureCollector(fn FailureCollectorFunc) CallMultiOption {
+	return func(opts *CallMultiOptions) {
+		opts.failureCollectorFn = fn
+	}
+}
+
+// CallMultiResult is a successful result of a call to one of the peers.
+type CallMultiResult struct {
+	// Response is the decoded response.
+	Response interface{}
+
+	// RawResponse is the raw response as received from the peer.
+	RawResponse cbor.RawMessage
+
+	// PeerFeedback is the PeerFeedback instance of the peer that served the response.
+	PeerFeedback PeerFeedback
+}
+
 // Client is an RPC client for a given protocol.
 type Client interface {
 	// Call attempts to route the given RPC method call to the given peer. It's up to the caller
-	// to provide only connected peers that support the protocol.
+	// to provide only connected peers that support the protocol. Calls to peers which are not
+	// connected and cannot be dialed fail with ErrNotConnected.
 	//
 	// On success it returns a PeerFeedback instance that should be used by the caller to provide
 	// deferred feedback on whether the peer is any good or not. This will help guide later choices
@@ -227,11 +472,15 @@ type Client interface {
 
 	// CallOne attempts to route the given RPC method call to one of the peers in the list in
 	// a sequential order. It's up to the caller to prioritize peers and to provide only
-	// connected peers that support the protocol.
+	// connected peers that support the protocol. Peers are tried in the given order, unless
+	// sorting is configured using WithPeerSort.
 	//
 	// On success it returns a PeerFeedback instance that should be used by the caller to provide
 	// deferred feedback on whether the peer is any good or not. This will help guide later choices
 	// when routing calls.
+	//
+	// If no peers are given, ErrNoPeers is returned. If the call fails on all peers,
+	// an AllPeersFailedError with the per-peer errors is returned.
 	CallOne(
 		ctx context.Context,
 		peers []core.PeerID,
@@ -240,11 +489,46 @@ type Client interface {
 		opts ...CallOption,
 	) (PeerFeedback, error)
 
+	// CallAny routes the given RPC method call to all of the peers in the list concurrently and
+	// returns the first successful and valid response, canceling all remaining in-flight calls.
+	// At most DefaultParallelRequests peers are called at the same time, prioritizing peers in
+	// the order given. It's up to the caller to provide only connected peers that support the
+	// protocol.
+	//
+	// On success it returns a PeerFeedback instance that should be used by the caller to provide
+	// deferred feedback on whether the peer is any good or not. This will help guide later choices
+	// when routing calls.
+	//
+	// Errors are reported in the same way as for CallOne.
+	CallAny(
+		ctx context.Context,
+		peers []core.PeerID,
+		method string,
+		body, rsp interface{},
+		opts ...CallOption,
+	) (PeerFeedback, error)
+
+	// CallStream is like Call, but instead of encoding the whole request body in memory before
+	// sending it, the CBOR-encoded request body is streamed to the peer using the body writer.
+	CallStream(
+		ctx context.Context,
+		peer core.PeerID,
+		method string,
+		bodyWriter BodyWriterFunc,
+		rsp interface{},
+		opts ...CallOption,
+	) (PeerFeedback, error)
+
 	// CallMulti routes the given RPC method call to multiple (possibly all) peers in the list in
 	// a sequential order. It's up to the caller to prioritize peers and use only peers that support
 	// the protocol.
 	//
-	// It returns all successfully retrieved results and their corresponding PeerFeedback instances.
+	// It returns all successfully retrieved results and their corresponding PeerFeedback instances,
+	// in the order in which they were received, unless WithOrderedResults is used.
+	// If the context is canceled while gathering results, the results retrieved so far are
+	// returned together with the context error. If the context deadline (less the configured
+	// deadline margin) elapses, the results retrieved so far are returned without an error,
+	// unless no results have been retrieved.
 	CallMulti(
 		ctx context.Context,
 		peers []core.PeerID,
@@ -253,6 +537,16 @@ type Client interface {
 		opts ...CallMultiOption,
 	) ([]interface{}, []PeerFeedback, error)
 
+	// CallMultiDetailed is like CallMulti, but it returns detailed results which also include
+	// the raw responses as received from the peers.
+	CallMultiDetailed(
+		ctx context.Context,
+		peers []core.PeerID,
+		method string,
+		body, rspTyp interface{},
+		opts ...CallMultiOption,
+	) ([]*CallMultiResult, error)
+
 	// Close closes all connections to the given peer.
 	Close(peerID core.PeerID) error
 
@@ -266,11 +560,24 @@ type Client interface {
 	// UnregisterListener unsubscribes the listener from the client notification events.
 	// If the listener is not registered this is a noop operation.
 	UnregisterListener(l ClientListener)
+
+	// Listeners returns a snapshot of the listeners subscribed to the client notification events.
+	// The order of the listeners is unspecified.
+	Listeners() []ClientListener
 }
 
 type client struct {
 	host       core.Host
 	protocolID protocol.ID
+	tracer     *CallTracer
+
+	metricsEnabled bool
+
+	// defaultCallOpts are the call options applied before the options of each call.
+	defaultCallOpts []CallOption
+
+	// rateLimiter limits the rate of calls to peers, if set.
+	rateLimiter *RateLimiter
 
 	listeners struct {
 		sync.RWMutex
@@ -280,6 +587,18 @@ type client struct {
 	logger *logging.Logger
 }
 
+// newCallOptions creates call options using the client's default and given values.
+func (c *client) newCallOptions(opts ...CallOption) *CallOptions {
+	if len(c.defaultCallOpts) == 0 {
+		return NewCallOptions(opts...)
+	}
+
+	allOpts := make([]CallOption, 0, len(c.defaultCallOpts)+len(opts))
+	allOpts = append(allOpts, c.defaultCallOpts...)
+	allOpts = append(allOpts, opts...)
+	return NewCallOptions(allOpts...)
+}
+
 // Implements Client.
 func (c *client) Call(
 	ctx context.Context,
@@ -298,23 +617,77 @@ func (c *client) CallOne(
 	method string,
 	body, rsp interface{},
 	opts ...CallOption,
+) (PeerFeedback, error) {
+	return c.callOne(ctx, peers, method, cbor.Marshal(body), nil, rsp, opts...)
+}
+
+// Implements Client.
+func (c *client) CallStream(
+	ctx context.Context,
+	peer core.PeerID,
+	method string,
+	bodyWriter BodyWriterFunc,
+	rsp interface{},
+	opts ...CallOption,
+) (PeerFeedback, error) {
+	return c.callOne(ctx, []core.PeerID{peer}, method, nil, bodyWriter, rsp, opts...)
+}
+
+// callOne routes the given RPC method call to one of the peers in the list in a sequential order.
+//
+// If the body writer is set, the request body is streamed using the body writer instead of
+// using the given encoded body.
+func (c *client) callOne(
+	ctx context.Context,
+	peers []core.PeerID,
+	method string,
+	body cbor.RawMessage,
+	bodyWriter BodyWriterFunc,
+	rsp interface{},
+	opts ...CallOption,
 ) (PeerFeedback, error) {
 	c.logger.Debug("call", "method", method)
 
 	if len(peers) == 0 {
-		return nil, fmt.Errorf("no peers given to service the request")
+		return nil, ErrNoPeers
 	}
 
-	co := NewCallOptions(opts...)
+	co := c.newCallOptions(opts...)
+
+	if co.peerLessFn != nil {
+		// Sort a copy to avoid modifying the caller's slice.
+		peers = append([]core.PeerID(nil), peers...)
+		sort.SliceStable(peers, func(i, j int) bool {
+			return co.peerLessFn(peers[i], peers[j])
+		})
+	}
 
 	// Prepare the request.
 	request := Request{
-		Method: method,
-		Body:   cbor.Marshal(body),
+		Method:      method,
+		Body:        body,
+		Compression: co.compression,
+	}
+	if co.nonceMode == nonceModeStable {
+		nonce, err := newRequestNonce()
+		if err != nil {
+			return nil, err
+		}
+		request.Nonce = nonce
+	}
+
+	trace := c.newCallTrace(CallTraceKindCallOne, method)
+
+	recordAttempt := func(peer core.PeerID, err error) {
+		if co.attemptRecorderFn != nil {
+			co.attemptRecorderFn(peer, err)
+		}
 	}
 
 	var pf PeerFeedback
 	tryPeers := func() error {
+		allErr := &AllPeersFailedError{Method: method}
+
 		// Iterate through the list of peers and attempt to execute the request.
 		for _, peer := range peers {
 			c.logger.Debug("trying peer",
@@ -322,9 +695,24 @@ func (c *client) CallOne(
 				"peer_id", peer,
 			)
 
+			if co.nonceMode == nonceModeFresh {
+				nonce, err := newRequestNonce()
+				if err != nil {
+					return backoff.Permanent(err)
+				}
+				request.Nonce = nonce
+			}
+
 			var err error
-			pf, err = c.timeCall(ctx, peer, &request, rsp, co.maxPeerResponseTime)
+			pf, _, err = c.timeCall(ctx, peer, &request, rsp, &peerCallOptions{
+				maxPeerResponseTime: co.maxPeerResponseTime,
+				writeDeadline:       co.writeDeadline,
+				minResponseSpeed:    co.minResponseSpeed,
+				bodyWriter:          bodyWriter,
+			}, trace)
 			if err != nil {
+				recordAttempt(peer, err)
+				allErr.Errors = append(allErr.Errors, PeerError{PeerID: peer, Err: err})
 				continue
 			}
 			if co.validationFn != nil {
@@ -335,9 +723,12 @@ func (c *client) CallOne(
 						"peer_id", peer,
 						"err", err,
 					)
+					recordAttempt(peer, err)
+					allErr.Errors = append(allErr.Errors, PeerError{PeerID: peer, Err: err})
 					continue
 				}
 			}
+			recordAttempt(peer, nil)
 			return nil
 		}
 
@@ -346,14 +737,164 @@ func (c *client) CallOne(
 			"method", method,
 		)
 
-		return fmt.Errorf("call failed on all peers")
+		return allErr
 	}
 
-	err := retryFn(ctx, tryPeers, co.maxRetries, co.retryInterval)
+	err := retryFn(ctx, tryPeers, co.maxRetries, co.newBackOff(), co.retryableFn)
+	trace.finish(err)
 
 	return pf, err
 }
 
+// Implements Client.
+func (c *client) CallAny(
+	ctx context.Context,
+	peers []core.PeerID,
+	method string,
+	body, rsp interface{},
+	opts ...CallOption,
+) (PeerFeedback, error) {
+	c.logger.Debug("call any", "method", method)
+
+	if len(peers) == 0 {
+		return nil, ErrNoPeers
+	}
+
+	co := c.newCallOptions(opts...)
+
+	// Prepare the request.
+	request := Request{
+		Method:      method,
+		Body:        cbor.Marshal(body),
+		Compression: co.compression,
+	}
+	if co.nonceMode == nonceModeStable {
+		nonce, err := newRequestNonce()
+		if err != nil {
+			return nil, err
+		}
+		request.Nonce = nonce
+	}
+
+	trace := c.newCallTrace(CallTraceKindCallAny, method)
+
+	var pf PeerFeedback
+	racePeers := func() error {
+		var err error
+		pf, err = c.racePeers(ctx, peers, &request, rsp, co, trace)
+		return err
+	}
+
+	err := retryFn(ctx, racePeers, co.maxRetries, co.newBackOff(), co.retryableFn)
+	trace.finish(err)
+
+	return pf, err
+}
+
+// racePeers concurrently calls all of the given peers and returns the first successful and valid
+// response.
+func (c *client) racePeers(
+	ctx context.Context,
+	peers []core.PeerID,
+	request *Request,
+	rsp interface{},
+	co *CallOptions,
+	trace *callTrace,
+) (PeerFeedback, error) {
+	// Create a worker pool. Stopping the pool waits for in-flight requests to complete, so do
+	// it in the background to be able to return early.
+	pool := workerpool.New("p2p/rpc")
+	pool.Resize(DefaultParallelRequests)
+	defer func() { go pool.Stop() }()
+
+	// Create a subcontext so we abort remaining requests once we are done.
+	peerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		peer core.PeerID
+		rsp  interface{}
+		pf   PeerFeedback
+		err  error
+	}
+
+	// Prepare a non-blocking channel for workers to push their results.
+	resultCh := make(chan result, len(peers))
+
+	for _, peer := range peers {
+		peer := peer // Make sure goroutine below operates on the right instance.
+
+		peerRequest := *request
+		if co.nonceMode == nonceModeFresh {
+			nonce, err := newRequestNonce()
+			if err != nil {
+				return nil, backoff.Permanent(err)
+			}
+			peerRequest.Nonce = nonce
+		}
+
+		pool.Submit(func() {
+			// Abort early in case we are done.
+			select {
+			case <-peerCtx.Done():
+				return
+			default:
+			}
+
+			// Decode into a separate response for each peer as the calls are concurrent.
+			var peerRsp interface{}
+			if rsp != nil {
+				peerRsp = reflect.New(reflect.TypeOf(rsp).Elem()).Interface()
+			}
+
+			pf, _, err := c.timeCall(peerCtx, peer, &peerRequest, peerRsp, &peerCallOptions{
+				maxPeerResponseTime: co.maxPeerResponseTime,
+				writeDeadline:       co.writeDeadline,
+				minResponseSpeed:    co.minResponseSpeed,
+			}, trace)
+
+			resultCh <- result{peer, peerRsp, pf, err}
+		})
+	}
+
+	// Wait for the first valid result.
+	allErr := &AllPeersFailedError{Method: request.Method}
+	for i := 0; i < len(peers); i++ {
+		select {
+		case result := <-resultCh:
+			if result.err != nil {
+				allErr.Errors = append(allErr.Errors, PeerError{PeerID: result.peer, Err: result.err})
+				continue
+			}
+			if rsp != nil {
+				reflect.ValueOf(rsp).Elem().Set(reflect.ValueOf(result.rsp).Elem())
+			}
+			if co.validationFn != nil {
+				if err := co.validationFn(result.pf); err != nil {
+					c.logger.Debug("failed to validate peer response",
+						"method", request.Method,
+						"peer_id", result.pf.PeerID(),
+						"err", err,
+					)
+					allErr.Errors = append(allErr.Errors, PeerError{PeerID: result.peer, Err: err})
+					continue
+				}
+			}
+			return result.pf, nil
+		case <-peerCtx.Done():
+			// The caller canceled the context, abort any remaining requests.
+			return nil, ctx.Err()
+		}
+	}
+
+	// No peers could be reached to service this request.
+	c.logger.Debug("no peers could be reached to service request",
+		"method", request.Method,
+	)
+
+	return nil, allErr
+}
+
 // Implements Client.
 func (c *client) CallMulti(
 	ctx context.Context,
@@ -362,6 +903,34 @@ func (c *client) CallMulti(
 	body, rspTyp interface{},
 	opts ...CallMultiOption,
 ) ([]interface{}, []PeerFeedback, error) {
+	results, err := c.CallMultiDetailed(ctx, peers, method, body, rspTyp, opts...)
+
+	var (
+		rsps []interface{}
+		pfs  []PeerFeedback
+	)
+	for _, result := range results {
+		if result == nil {
+			// Failed peer when results are ordered.
+			rsps = append(rsps, nil)
+			pfs = append(pfs, nil)
+			continue
+		}
+		rsps = append(rsps, result.Response)
+		pfs = append(pfs, result.PeerFeedback)
+	}
+
+	return rsps, pfs, err
+}
+
+// Implements Client.
+func (c *client) CallMultiDetailed(
+	ctx context.Context,
+	peers []core.PeerID,
+	method string,
+	body, rspTyp interface{},
+	opts ...CallMultiOption,
+) ([]*CallMultiResult, error) {
 	c.logger.Debug("call multiple", "method", method)
 
 	co := NewCallMultiOptions(opts...)
@@ -372,10 +941,13 @@ func (c *client) CallMulti(
 		Body:   cbor.Marshal(body),
 	}
 
-	// Create a worker pool.
+	trace := c.newCallTrace(CallTraceKindCallMulti, method)
+
+	// Create a worker pool. Stopping the pool waits for in-flight requests to complete, so do
+	// it in the background to be able to return early.
 	pool := workerpool.New("p2p/rpc")
 	pool.Resize(co.maxParallelRequests)
-	defer pool.Stop()
+	defer func() { go pool.Stop() }()
 
 	// Create a subcontext so we abort further requests if we are done early.
 	peerCtx, cancel := context.WithCancel(ctx)
@@ -383,16 +955,26 @@ func (c *client) CallMulti(
 
 	// Requests results from peers.
 	type result struct {
-		rsp interface{}
-		pf  PeerFeedback
-		err error
+		index  int
+		peer   core.PeerID
+		rsp    interface{}
+		rawRsp cbor.RawMessage
+		pf     PeerFeedback
+		err    error
 	}
 
 	// Prepare a non-blocking channel for workers to push their results.
 	resultCh := make(chan result, len(peers))
 
-	for _, peer := range peers {
-		peer := peer // Make sure goroutine below operates on the right instance.
+	// Track requests in flight for each peer, deferring requests to peers at the limit.
+	var (
+		inFlight = make(map[core.PeerID]uint)
+		deferred []int
+	)
+
+	submit := func(index int) {
+		peer := peers[index]
+		inFlight[peer]++
 
 		pool.Submit(func() {
 			// Abort early in case we are done.
@@ -402,30 +984,90 @@ func (c *client) CallMulti(
 			default:
 			}
 
-			rsp := reflect.New(reflect.TypeOf(rspTyp)).Interface()
-			pf, err := c.timeCall(peerCtx, peer, &request, rsp, co.maxPeerResponseTime)
+			maxPeerResponseTime := co.maxPeerResponseTime
+			if d, ok := co.maxPeerResponseTimes[peer]; ok {
+				maxPeerResponseTime = d
+			}
+
+			var rsp interface{}
+			if co.responseFactory != nil {
+				rsp = co.responseFactory()
+			} else {
+				rsp = reflect.New(reflect.TypeOf(rspTyp)).Interface()
+			}
+			pf, rawRsp, err := c.timeCall(peerCtx, peer, &request, rsp, &peerCallOptions{
+				maxPeerResponseTime: maxPeerResponseTime,
+				writeDeadline:       co.writeDeadline,
+			}, trace)
 
-			resultCh <- result{rsp, pf, err}
+			resultCh <- result{index, peer, rsp, rawRsp, pf, err}
 		})
 	}
 
+	release := func(peer core.PeerID) {
+		inFlight[peer]--
+
+		for i, index := range deferred {
+			if peers[index] != peer {
+				continue
+			}
+			deferred = append(deferred[:i], deferred[i+1:]...)
+			submit(index)
+			return
+		}
+	}
+
+	for index, peer := range peers {
+		if co.perPeerLimit > 0 && inFlight[peer] >= co.perPeerLimit {
+			deferred = append(deferred, index)
+			continue
+		}
+		submit(index)
+	}
+
 	// Gather results.
 	var (
-		rsps []interface{}
-		pfs  []PeerFeedback
+		results    []*CallMultiResult
+		numResults int
+		err        error
 	)
+	if co.orderedResults {
+		results = make([]*CallMultiResult, len(peers))
+	}
+
+	// Stop gathering results once the deadline derived from the context elapses.
+	var deadlineCh <-chan time.Time
+	if deadline, ok := ctx.Deadline(); ok {
+		timer := time.NewTimer(time.Until(deadline) - co.deadlineMargin)
+		defer timer.Stop()
+		deadlineCh = timer.C
+	}
 
 loop:
 	for i := 0; i < len(peers); i++ {
 		select {
 		case result := <-resultCh:
+			release(result.peer)
+
 			// Ignore failed results.
 			if result.err != nil {
+				if co.failureCollectorFn != nil {
+					co.failureCollectorFn(result.peer, result.err)
+				}
 				break
 			}
 
-			rsps = append(rsps, result.rsp)
-			pfs = append(pfs, result.pf)
+			res := &CallMultiResult{
+				Response:     result.rsp,
+				RawResponse:  result.rawRsp,
+				PeerFeedback: result.pf,
+			}
+			if co.orderedResults {
+				results[result.index] = res
+			} else {
+				results = append(results, res)
+			}
+			numResults++
 
 			if co.aggregateFn != nil {
 				if !co.aggregateFn(result.rsp, result.pf) {
@@ -433,17 +1075,42 @@ loop:
 				}
 			}
 
+		case <-deadlineCh:
+			// The deadline elapsed, abort any remaining requests and return what we have.
+			if numResults == 0 {
+				err = context.DeadlineExceeded
+			}
+			break loop
+
 		case <-peerCtx.Done():
+			// The caller canceled the context, abort any remaining requests.
+			err = ctx.Err()
+			if errors.Is(err, context.DeadlineExceeded) && numResults > 0 {
+				err = nil
+			}
 			break loop
 		}
 	}
 
 	c.logger.Debug("received responses from peers",
 		"method", method,
-		"num_peers", len(rsps),
+		"num_peers", numResults,
+		"err", err,
 	)
 
-	return rsps, pfs, nil
+	trace.finish(err)
+
+	return results, err
+}
+
+// peerCallOptions are the options of a call to a single peer.
+type peerCallOptions struct {
+	maxPeerResponseTime time.Duration
+	writeDeadline       time.Duration
+	minResponseSpeed    uint64
+
+	// bodyWriter is the writer of the streamed request body. If set, the request body is ignored.
+	bodyWriter BodyWriterFunc
 }
 
 func (c *client) timeCall(
@@ -451,16 +1118,30 @@ func (c *client) timeCall(
 	peerID core.PeerID,
 	request *Request,
 	rsp interface{},
-	maxPeerResponseTime time.Duration,
-) (PeerFeedback, error) {
+	opts *peerCallOptions,
+	trace *callTrace,
+) (PeerFeedback, cbor.RawMessage, error) {
+	// Wait for the rate limiter before calling the peer, waiting is not a peer failure.
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return &peerFeedback{
+			client: c,
+			peerID: peerID,
+			method: request.Method,
+		}, nil, fmt.Errorf("rate limit: %w", err)
+	}
+
 	start := time.Now()
-	err := c.call(ctx, peerID, request, rsp, maxPeerResponseTime)
+	rawRsp, err := c.call(ctx, peerID, request, rsp, opts)
 	latency := time.Since(start)
 
+	c.observeCall(request.Method, latency)
+
+	trace.recordAttempt(peerID, start, latency, err)
+
 	if err != nil {
 		// If the caller canceled the context we should not degrade the peer.
 		if !commonErrors.Is(err, context.Canceled) {
-			c.recordFailure(peerID, latency)
+			c.recordFailure(peerID, request.Method, latency)
 		}
 
 		c.logger.Debug("failed to call method",
@@ -473,8 +1154,9 @@ func (c *client) timeCall(
 	return &peerFeedback{
 		client:  c,
 		peerID:  peerID,
+		method:  request.Method,
 		latency: latency,
-	}, err
+	}, rawRsp, err
 }
 
 func (c *client) call(
@@ -482,8 +1164,8 @@ func (c *client) call(
 	peerID core.PeerID,
 	request *Request,
 	rsp interface{},
-	maxPeerResponseTime time.Duration,
-) error {
+	opts *peerCallOptions,
+) (cbor.RawMessage, error) {
 	// Attempt to open stream to the given peer.
 	stream, err := c.host.NewStream(
 		ctx,
@@ -491,9 +1173,17 @@ func (c *client) call(
 		c.protocolID,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to open stream: %w", err)
+		// Distinguish peers which could not be reached from genuine stream failures.
+		if c.host.Network().Connectedness(peerID) != network.Connected {
+			return nil, fmt.Errorf("failed to open stream: %w: %w", ErrNotConnected, err)
+		}
+		return nil, fmt.Errorf("failed to open stream: %w", err)
 	}
+	var reset bool
 	defer func() {
+		if reset {
+			return
+		}
 		if err = stream.Close(); err != nil {
 			c.logger.Debug("failed to close stream",
 				"err", err,
@@ -501,41 +1191,96 @@ func (c *client) call(
 		}
 	}()
 
-	codec := cbor.NewMessageCodec(stream, codecModuleName)
+	// Enforce the minimum response speed, if configured.
+	var (
+		rw io.ReadWriter = stream
+		ms *minSpeedStream
+	)
+	if opts.minResponseSpeed > 0 {
+		ms = newMinSpeedStream(stream, opts.minResponseSpeed)
+		rw = ms
+	}
+
+	codec := cbor.NewMessageCodec(rw, codecModuleName)
 
 	// Send request.
-	_ = stream.SetWriteDeadline(time.Now().Add(RequestWriteDeadline))
-	if err = codec.Write(request); err != nil {
+	_ = stream.SetWriteDeadline(time.Now().Add(opts.writeDeadline))
+	switch opts.bodyWriter {
+	case nil:
+		err = codec.Write(request)
+	default:
+		err = writeStreamedRequest(stream, request, opts.bodyWriter)
+	}
+	if err != nil {
 		c.logger.Debug("failed to send request",
 			"err", err,
 			"peer_id", peerID,
 		)
-		return fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	_ = stream.SetWriteDeadline(time.Time{})
 
 	// Read response.
-	// TODO: Add required minimum speed.
 	var rawRsp Response
-	_ = stream.SetReadDeadline(time.Now().Add(maxPeerResponseTime))
-	if err = codec.Read(&rawRsp); err != nil {
+	readDeadline := time.Now().Add(opts.maxPeerResponseTime)
+	_ = stream.SetReadDeadline(readDeadline)
+	if ms != nil {
+		ms.deadline = readDeadline
+	}
+
+	// Read the response in the background, so that the stream can be reset as soon as the
+	// context is canceled instead of waiting for the read deadline.
+	readCh := make(chan error, 1)
+	go func() {
+		readCh <- codec.Read(&rawRsp)
+	}()
+
+	select {
+	case err = <-readCh:
+	case <-ctx.Done():
+		// Resetting the stream unblocks the read, wait for it to finish before returning.
+		_ = stream.Reset()
+		reset = true
+		<-readCh
+
+		c.logger.Debug("aborted reading response",
+			"err", ctx.Err(),
+			"peer_id", peerID,
+		)
+		return nil, fmt.Errorf("failed to read response: %w", ctx.Err())
+	}
+	if err != nil {
+		if ms != nil && ms.tooSlow {
+			err = ErrPeerTooSlow
+		}
 		c.logger.Debug("failed to read response",
 			"err", err,
 			"peer_id", peerID,
 		)
-		return fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 	_ = stream.SetWriteDeadline(time.Time{})
 
 	// Decode response.
 	if rawRsp.Error != nil {
-		return commonErrors.FromCode(rawRsp.Error.Module, rawRsp.Error.Code, rawRsp.Error.Message)
+		return nil, commonErrors.FromCode(rawRsp.Error.Module, rawRsp.Error.Code, rawRsp.Error.Message)
+	}
+	if rawRsp.Compression != CompressionNone {
+		var compressed []byte
+		if err = cbor.Unmarshal(rawRsp.Ok, &compressed); err != nil {
+			return nil, fmt.Errorf("malformed compressed response: %w", err)
+		}
+		if rawRsp.Ok, err = rawRsp.Compression.decompress(compressed); err != nil {
+			return nil, fmt.Errorf("failed to decompress response: %w", err)
+		}
 	}
 
 	if rsp != nil {
-		return cbor.Unmarshal(rawRsp.Ok, rsp)
+		if err = cbor.Unmarshal(rawRsp.Ok, rsp); err != nil {
+			return nil, err
+		}
 	}
-	return nil
+	return rawRsp.Ok, nil
 }
 
 // Implements Client.
@@ -577,7 +1322,19 @@ func (c *client) UnregisterListener(l ClientListener) {
 	delete(c.listeners.m, l)
 }
 
-func (c *client) recordSuccess(peerID core.PeerID, latency time.Duration) {
+// Implements Client.
+func (c *client) Listeners() []ClientListener {
+	c.listeners.RLock()
+	defer c.listeners.RUnlock()
+
+	listeners := make([]ClientListener, 0, len(c.listeners.m))
+	for l := range c.listeners.m {
+		listeners = append(listeners, l)
+	}
+	return listeners
+}
+
+func (c *client) recordSuccess(peerID core.PeerID, _ string, latency time.Duration) {
 	c.listeners.RLock()
 	defer c.listeners.RUnlock()
 
@@ -586,7 +1343,9 @@ func (c *client) recordSuccess(peerID core.PeerID, latency time.Duration) {
 	}
 }
 
-func (c *client) recordFailure(peerID core.PeerID, latency time.Duration) {
+func (c *client) recordFailure(peerID core.PeerID, method string, latency time.Duration) {
+	c.observeFailure(method)
+
 	c.listeners.RLock()
 	defer c.listeners.RUnlock()
 
@@ -595,7 +1354,9 @@ func (c *client) recordFailure(peerID core.PeerID, latency time.Duration) {
 	}
 }
 
-func (c *client) recordBadPeer(peerID core.PeerID) {
+func (c *client) recordBadPeer(peerID core.PeerID, method string) {
+	c.observeBadPeer(method)
+
 	c.listeners.RLock()
 	defer c.listeners.RUnlock()
 
@@ -604,25 +1365,109 @@ func (c *client) recordBadPeer(peerID core.PeerID) {
 	}
 }
 
-func retryFn(ctx context.Context, fn func() error, maxRetries uint64, retryInterval time.Duration) error {
+func newRequestNonce() ([]byte, error) {
+	nonce := make([]byte, RequestNonceSize)
+	if _, err := cryptorand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate request nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+func retryFn(ctx context.Context, fn func() error, maxRetries uint64, b backoff.BackOff, retryableFn RetryableFunc) error {
 	if maxRetries == 0 {
 		return fn()
 	}
 
-	retry := backoff.WithMaxRetries(backoff.NewConstantBackOff(retryInterval), maxRetries)
+	if retryableFn != nil {
+		inner := fn
+		fn = func() error {
+			err := inner()
+			if err != nil && !retryableFn(err) {
+				return backoff.Permanent(err)
+			}
+			return err
+		}
+	}
+
+	retry := backoff.WithMaxRetries(b, maxRetries)
 	return backoff.Retry(fn, backoff.WithContext(retry, ctx))
 }
 
+// ClientOptions are client options.
+type ClientOptions struct {
+	tracer          *CallTracer
+	metricsEnabled  bool
+	defaultCallOpts []CallOption
+	rateLimiter     *RateLimiter
+}
+
+// NewClientOptions creates options using default and given values.
+func NewClientOptions(opts ...ClientOption) *ClientOptions {
+	co := ClientOptions{
+		metricsEnabled: cmmetrics.Enabled(),
+	}
+	for _, opt := range opts {
+		opt(&co)
+	}
+	return &co
+}
+
+// ClientOption is a client option setter.
+type ClientOption func(opts *ClientOptions)
+
+// WithCallTracer configures the tracer which records a trace of every call made by the client.
+func WithCallTracer(tracer *CallTracer) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.tracer = tracer
+	}
+}
+
+// WithMetrics configures whether the client should record metrics.
+//
+// If not set, metrics are recorded iff metrics are enabled for the node.
+func WithMetrics(enabled bool) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.metricsEnabled = enabled
+	}
+}
+
+// WithDefaultCallOptions configures the default call options used for all calls made by the
+// client which accept call options. Options given to individual calls override the defaults.
+func WithDefaultCallOptions(opts ...CallOption) ClientOption {
+	return func(co *ClientOptions) {
+		co.defaultCallOpts = append(co.defaultCallOpts, opts...)
+	}
+}
+
+// WithRateLimiter configures the rate limiter which limits the rate of calls to peers made by the
+// client. The limits of the rate limiter can be changed at runtime.
+//
+// If not set, calls are not rate limited.
+func WithRateLimiter(l *RateLimiter) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.rateLimiter = l
+	}
+}
+
 // NewClient creates a new RPC client for the given protocol.
-func NewClient(h host.Host, p protocol.ID) Client {
+func NewClient(h host.Host, p protocol.ID, opts ...ClientOption) Client {
 	if h == nil {
 		// No P2P service, use the no-op client.
 		return &nopClient{}
 	}
 
+	co := NewClientOptions(opts...)
+	if co.metricsEnabled {
+		initMetrics()
+	}
+
 	return &client{
-		host:       h,
-		protocolID: p,
+		host:            h,
+		protocolID:      p,
+		tracer:          co.tracer,
+		metricsEnabled:  co.metricsEnabled,
+		defaultCallOpts: co.defaultCallOpts,
+		rateLimiter:     co.rateLimiter,
 		listeners: struct {
 			sync.RWMutex
 			m map[ClientListener]struct{}
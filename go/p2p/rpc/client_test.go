@@ -1,18 +1,30 @@
 package rpc
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
@@ -20,22 +32,56 @@ import (
 )
 
 const (
-	testMethod   = "test"
-	testProtocol = core.ProtocolID("p2p/rpc/test/1.0.0")
+	testMethod      = "test"
+	testSlowMethod  = "slow"
+	testLargeMethod = "large"
+	testProtocol    = core.ProtocolID("p2p/rpc/test/1.0.0")
+
+	testSlowMethodDelay = 200 * time.Millisecond
+
+	testTrickleProtocol  = core.ProtocolID("p2p/rpc/test-trickle/1.0.0")
+	testTrickleBytes     = 200
+	testTrickleByteDelay = 10 * time.Millisecond
+
+	testStallProtocol = core.ProtocolID("p2p/rpc/test-stall/1.0.0")
+	testStallDuration = 2 * time.Second
 )
 
+// testLargeRequest is a request which is too large to be written without the peer reading it.
+type testLargeRequest struct {
+	Data []byte
+}
+
 type testRequest struct{}
 
 type testResponse struct {
 	ID int
 }
 
+// testLargeResponse is a compressible response returned by the large method.
+type testLargeResponse struct {
+	ID   int
+	Data []byte
+}
+
 type testService struct {
 	id int
+
+	mu     sync.Mutex
+	nonces [][]byte
 }
 
-func (s *testService) HandleRequest(_ context.Context, method string, body cbor.RawMessage) (interface{}, error) {
-	if method != testMethod {
+func (s *testService) HandleRequest(ctx context.Context, method string, body cbor.RawMessage) (interface{}, error) {
+	nonce, _ := RequestNonceFromContext(ctx)
+	s.mu.Lock()
+	s.nonces = append(s.nonces, nonce)
+	s.mu.Unlock()
+
+	switch method {
+	case testMethod, testLargeMethod:
+	case testSlowMethod:
+		time.Sleep(time.Duration(s.id-1) * testSlowMethodDelay)
+	default:
 		return nil, fmt.Errorf("unsupported method")
 	}
 	var req testRequest
@@ -45,9 +91,45 @@ func (s *testService) HandleRequest(_ context.Context, method string, body cbor.
 	if s.id < 2 {
 		return nil, fmt.Errorf("first two servers are corrupted")
 	}
+	if method == testLargeMethod {
+		return &testLargeResponse{ID: s.id, Data: bytes.Repeat([]byte{0x42}, 64*1024)}, nil
+	}
 	return &testResponse{ID: s.id}, nil
 }
 
+// handleTrickleStream reads a request and then slowly trickles bytes of a response.
+func handleTrickleStream(stream network.Stream) {
+	defer stream.Close()
+
+	codec := cbor.NewMessageCodec(stream, codecModuleName)
+	var request Request
+	if err := codec.Read(&request); err != nil {
+		return
+	}
+
+	// Send a length prefix followed by a CBOR byte string header so that the client keeps reading
+	// until the whole byte string is received.
+	header := make([]byte, 7)
+	binary.BigEndian.PutUint32(header[:4], testTrickleBytes+3)
+	header[4] = 0x59 // Byte string with a 2-byte length.
+	binary.BigEndian.PutUint16(header[5:], testTrickleBytes)
+	if _, err := stream.Write(header); err != nil {
+		return
+	}
+	for i := 0; i < testTrickleBytes; i++ {
+		time.Sleep(testTrickleByteDelay)
+		if _, err := stream.Write([]byte{0}); err != nil {
+			return
+		}
+	}
+}
+
+// handleStallStream never reads the request.
+func handleStallStream(stream network.Stream) {
+	time.Sleep(testStallDuration)
+	_ = stream.Reset()
+}
+
 func (s *testService) Protocol() protocol.ID {
 	return testProtocol
 }
@@ -77,9 +159,19 @@ func (l *testListener) RecordBadPeer(core.PeerID) {
 	l.mu.Unlock()
 }
 
+func (s *testService) takeNonces() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nonces := s.nonces
+	s.nonces = nil
+	return nonces
+}
+
 type RPCTestSuite struct {
 	suite.Suite
 
+	services    []*testService
 	servers     []Server
 	serverHosts []host.Host
 
@@ -111,9 +203,12 @@ func (s *RPCTestSuite) SetupSuite() {
 	// Prepare N servers.
 	n := 4
 
+	s.services = make([]*testService, 0, n)
 	s.servers = make([]Server, 0, n)
 	for i := 0; i < n; i++ {
-		server := NewServer(testProtocol, &testService{id: i})
+		service := &testService{id: i}
+		server := NewServer(testProtocol, service)
+		s.services = append(s.services, service)
 		s.servers = append(s.servers, server)
 	}
 
@@ -121,6 +216,8 @@ func (s *RPCTestSuite) SetupSuite() {
 	for _, server := range s.servers {
 		serverHost := newHost()
 		serverHost.SetStreamHandler(server.Protocol(), server.HandleStream)
+		serverHost.SetStreamHandler(testTrickleProtocol, handleTrickleStream)
+		serverHost.SetStreamHandler(testStallProtocol, handleStallStream)
 
 		s.serverHosts = append(s.serverHosts, serverHost)
 	}
@@ -172,6 +269,7 @@ func (s *RPCTestSuite) TestCall() {
 		require.NoError(err, "Call failed")
 		require.Equal(2, rsp.ID)
 		require.Equal(peer, pf.PeerID())
+		require.Positive(pf.Latency())
 
 		require.Equal(0, s.listener.successes)
 		require.Equal(0, s.listener.failures)
@@ -190,6 +288,20 @@ func (s *RPCTestSuite) TestCall() {
 		require.Equal(1, s.listener.failures)
 		require.Equal(0, s.listener.badPeers)
 	})
+
+	s.Run("Peer not connected", func() {
+		require := require.New(s.T())
+
+		failures := s.listener.failures
+
+		var rsp testResponse
+		_, err := s.client.Call(ctx, peer.ID("not connected"), testMethod, &testRequest{}, &rsp)
+		require.ErrorIs(err, ErrNotConnected)
+
+		require.Equal(0, s.listener.successes)
+		require.Equal(failures+1, s.listener.failures, "unreachable peer should be degraded")
+		require.Equal(0, s.listener.badPeers)
+	})
 }
 
 func (s *RPCTestSuite) TestCallOne() {
@@ -227,10 +339,590 @@ func (s *RPCTestSuite) TestCallOne() {
 		_, err := s.client.CallOne(ctx, peers, testMethod, &testRequest{}, &rsp)
 		require.Error(err, "CallOne did not fail")
 
+		var allErr *AllPeersFailedError
+		require.ErrorAs(err, &allErr)
+		require.Equal(testMethod, allErr.Method)
+		require.Len(allErr.Errors, 2)
+		for i, peerErr := range allErr.Errors {
+			require.Equal(peers[i], peerErr.PeerID)
+			require.Error(peerErr.Err)
+		}
+
 		require.Equal(0, s.listener.successes)
 		require.Equal(4, s.listener.failures)
 		require.Equal(0, s.listener.badPeers)
 	})
+
+	s.Run("No peers", func() {
+		require := require.New(s.T())
+
+		var rsp testResponse
+		_, err := s.client.CallOne(ctx, nil, testMethod, &testRequest{}, &rsp)
+		require.ErrorIs(err, ErrNoPeers)
+	})
+}
+
+func (s *RPCTestSuite) TestCallOnePeerSort() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require := require.New(s.T())
+
+	peers := make([]peer.ID, 0, len(s.serverHosts))
+	for _, h := range s.serverHosts {
+		peers = append(peers, h.ID())
+	}
+	unsorted := append([]peer.ID(nil), peers...)
+
+	// Sort the peers in reverse order of the servers, so that the last server responds.
+	index := make(map[peer.ID]int, len(peers))
+	for i, p := range peers {
+		index[p] = i
+	}
+	reverse := func(a, b core.PeerID) bool {
+		return index[a] > index[b]
+	}
+
+	var rsp testResponse
+	pf, err := s.client.CallOne(ctx, peers, testMethod, &testRequest{}, &rsp, WithPeerSort(reverse))
+	require.NoError(err, "CallOne failed")
+	require.Equal(len(peers)-1, rsp.ID)
+	require.Equal(peers[len(peers)-1], pf.PeerID())
+	require.Equal(unsorted, peers, "caller's peers should not be modified")
+}
+
+func (s *RPCTestSuite) TestCallOneAttemptRecorder() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	peers := make([]peer.ID, 0, len(s.serverHosts))
+	for _, h := range s.serverHosts {
+		peers = append(peers, h.ID())
+	}
+
+	type attempt struct {
+		peer core.PeerID
+		err  error
+	}
+	var attempts []attempt
+	recorder := func(peer core.PeerID, err error) {
+		attempts = append(attempts, attempt{peer, err})
+	}
+
+	s.Run("Happy path", func() {
+		require := require.New(s.T())
+
+		attempts = nil
+		var rsp testResponse
+		_, err := s.client.CallOne(ctx, peers, testMethod, &testRequest{}, &rsp,
+			WithAttemptRecorder(recorder),
+		)
+		require.NoError(err, "CallOne failed")
+
+		// The first two servers are corrupted.
+		require.Len(attempts, 3)
+		for i, a := range attempts {
+			require.Equal(peers[i], a.peer)
+			require.Equal(i < 2, a.err != nil)
+		}
+	})
+
+	s.Run("All peers fail", func() {
+		require := require.New(s.T())
+
+		attempts = nil
+		errInvalid := fmt.Errorf("invalid response")
+		var rsp testResponse
+		_, err := s.client.CallOne(ctx, peers, testMethod, &testRequest{}, &rsp,
+			WithAttemptRecorder(recorder),
+			WithValidationFn(func(PeerFeedback) error { return errInvalid }),
+		)
+		require.Error(err, "CallOne should fail")
+
+		require.Len(attempts, len(peers))
+		for i, a := range attempts {
+			require.Equal(peers[i], a.peer)
+			require.Error(a.err)
+			require.Equal(i >= 2, errors.Is(a.err, errInvalid))
+		}
+	})
+}
+
+func (s *RPCTestSuite) TestCallCompression() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	peer := s.serverHosts[2].ID()
+
+	// rawCall sends the given request directly and returns the response as received.
+	rawCall := func(request *Request) *Response {
+		require := require.New(s.T())
+
+		stream, err := s.clientHost.NewStream(ctx, peer, testProtocol)
+		require.NoError(err, "NewStream failed")
+		defer stream.Close()
+
+		codec := cbor.NewMessageCodec(stream, codecModuleName)
+		err = codec.Write(request)
+		require.NoError(err, "Write failed")
+
+		var rsp Response
+		err = codec.Read(&rsp)
+		require.NoError(err, "Read failed")
+		require.Nil(rsp.Error)
+		return &rsp
+	}
+
+	s.Run("Compressed response", func() {
+		require := require.New(s.T())
+
+		var rsp testLargeResponse
+		_, err := s.client.CallOne(ctx, []core.PeerID{peer}, testLargeMethod, &testRequest{}, &rsp,
+			WithCompression(CompressionSnappy),
+		)
+		require.NoError(err, "CallOne failed")
+		require.Equal(2, rsp.ID)
+		require.Len(rsp.Data, 64*1024)
+
+		raw := rawCall(&Request{
+			Method:      testLargeMethod,
+			Body:        cbor.Marshal(&testRequest{}),
+			Compression: CompressionSnappy,
+		})
+		require.Equal(CompressionSnappy, raw.Compression)
+		require.Less(len(raw.Ok), 64*1024, "response body should be compressed")
+	})
+
+	s.Run("Streamed request", func() {
+		require := require.New(s.T())
+
+		bodyWriter := func(w io.Writer) error {
+			return cbor.NewEncoder(w).Encode(&testRequest{})
+		}
+
+		var rsp testLargeResponse
+		_, err := s.client.CallStream(ctx, peer, testLargeMethod, bodyWriter, &rsp,
+			WithCompression(CompressionSnappy),
+		)
+		require.NoError(err, "CallStream failed")
+		require.Equal(2, rsp.ID)
+		require.Len(rsp.Data, 64*1024)
+	})
+
+	s.Run("Incompressible response", func() {
+		require := require.New(s.T())
+
+		raw := rawCall(&Request{
+			Method:      testMethod,
+			Body:        cbor.Marshal(&testRequest{}),
+			Compression: CompressionSnappy,
+		})
+		require.Equal(CompressionNone, raw.Compression)
+	})
+
+	s.Run("Unsupported codec", func() {
+		require := require.New(s.T())
+
+		raw := rawCall(&Request{
+			Method:      testLargeMethod,
+			Body:        cbor.Marshal(&testRequest{}),
+			Compression: CompressionCodec(0xff),
+		})
+		require.Equal(CompressionNone, raw.Compression)
+
+		var rsp testLargeResponse
+		err := cbor.Unmarshal(raw.Ok, &rsp)
+		require.NoError(err, "response should not be compressed")
+		require.Len(rsp.Data, 64*1024)
+	})
+}
+
+func (s *RPCTestSuite) TestCallStream() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	peer := s.serverHosts[2].ID()
+	service := s.services[2]
+
+	bodyWriter := func(w io.Writer) error {
+		return cbor.NewEncoder(w).Encode(&testRequest{})
+	}
+
+	s.Run("Happy path", func() {
+		require := require.New(s.T())
+
+		var rsp testResponse
+		pf, err := s.client.CallStream(ctx, peer, testMethod, bodyWriter, &rsp)
+		require.NoError(err, "CallStream failed")
+		require.Equal(2, rsp.ID)
+		require.Equal(peer, pf.PeerID())
+	})
+
+	s.Run("With nonce", func() {
+		require := require.New(s.T())
+
+		_ = service.takeNonces()
+		var rsp testResponse
+		_, err := s.client.CallStream(ctx, peer, testMethod, bodyWriter, &rsp, WithNonce())
+		require.NoError(err, "CallStream failed")
+		require.Equal(2, rsp.ID)
+
+		nonces := service.takeNonces()
+		require.Len(nonces, 1)
+		require.Len(nonces[0], RequestNonceSize)
+	})
+
+	s.Run("Unstable body writer", func() {
+		require := require.New(s.T())
+
+		var calls int
+		unstableWriter := func(w io.Writer) error {
+			calls++
+			return cbor.NewEncoder(w).Encode(&testLargeRequest{Data: make([]byte, calls)})
+		}
+
+		var rsp testResponse
+		_, err := s.client.CallStream(ctx, peer, testMethod, unstableWriter, &rsp)
+		require.ErrorContains(err, "request body size changed")
+	})
+}
+
+func (s *RPCTestSuite) TestCallMinResponseSpeed() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s.Run("Fast peer", func() {
+		require := require.New(s.T())
+
+		peer := s.serverHosts[2].ID()
+		var rsp testResponse
+		_, err := s.client.Call(ctx, peer, testMethod, &testRequest{}, &rsp,
+			WithMinResponseSpeed(1000),
+		)
+		require.NoError(err, "Call failed")
+	})
+
+	client := NewClient(s.clientHost, testTrickleProtocol)
+	peer := s.serverHosts[2].ID()
+
+	s.Run("Slow peer", func() {
+		require := require.New(s.T())
+
+		start := time.Now()
+		var rsp testResponse
+		_, err := client.Call(ctx, peer, testMethod, &testRequest{}, &rsp,
+			WithMaxPeerResponseTime(5*time.Second),
+			WithMinResponseSpeed(1000),
+		)
+		require.ErrorIs(err, ErrPeerTooSlow)
+		require.Less(time.Since(start), 2*minResponseSpeedWindow, "slow peer should be aborted early")
+	})
+
+	s.Run("Slow peer without minimum speed", func() {
+		require := require.New(s.T())
+
+		var rsp testResponse
+		_, err := client.Call(ctx, peer, testMethod, &testRequest{}, &rsp,
+			WithMaxPeerResponseTime(500*time.Millisecond),
+		)
+		require.Error(err, "Call should time out")
+		require.NotErrorIs(err, ErrPeerTooSlow)
+	})
+}
+
+func (s *RPCTestSuite) TestCallWriteDeadline() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(s.clientHost, testStallProtocol)
+	peer := s.serverHosts[2].ID()
+	req := &testLargeRequest{Data: make([]byte, 4*1024*1024)}
+	writeDeadline := 100 * time.Millisecond
+
+	s.Run("CallOne", func() {
+		require := require.New(s.T())
+
+		start := time.Now()
+		var rsp testResponse
+		_, err := client.Call(ctx, peer, testMethod, req, &rsp,
+			WithWriteDeadline(writeDeadline),
+		)
+		require.ErrorContains(err, "failed to send request")
+		require.Less(time.Since(start), testStallDuration, "write should hit the deadline")
+	})
+
+	s.Run("CallMulti", func() {
+		require := require.New(s.T())
+
+		start := time.Now()
+		var rsp testResponse
+		rsps, _, err := client.CallMulti(ctx, []core.PeerID{peer}, testMethod, req, &rsp,
+			WithWriteDeadlineMulti(writeDeadline),
+		)
+		require.NoError(err, "CallMulti")
+		require.Empty(rsps)
+		require.Less(time.Since(start), testStallDuration, "write should hit the deadline")
+	})
+}
+
+func (s *RPCTestSuite) TestCallContextCanceled() {
+	// The last server responds to slow requests after a long delay.
+	peer := s.serverHosts[3].ID()
+
+	s.Run("Canceled", func() {
+		require := require.New(s.T())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(testSlowMethodDelay/4, cancel)
+
+		start := time.Now()
+		var rsp testResponse
+		_, err := s.client.Call(ctx, peer, testSlowMethod, &testRequest{}, &rsp)
+		require.ErrorIs(err, context.Canceled)
+		require.Less(time.Since(start), testSlowMethodDelay, "call should be aborted on cancellation")
+
+		require.Equal(0, s.listener.failures, "canceled calls should not degrade the peer")
+	})
+
+	s.Run("Deadline exceeded", func() {
+		require := require.New(s.T())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testSlowMethodDelay/4)
+		defer cancel()
+
+		start := time.Now()
+		var rsp testResponse
+		_, err := s.client.Call(ctx, peer, testSlowMethod, &testRequest{}, &rsp)
+		require.ErrorIs(err, context.DeadlineExceeded)
+		require.Less(time.Since(start), testSlowMethodDelay, "call should be aborted on deadline")
+
+		require.Equal(1, s.listener.failures)
+	})
+}
+
+func (s *RPCTestSuite) TestCallExponentialBackoff() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require := require.New(s.T())
+
+	// Constant backoff is used by default.
+	_, ok := NewCallOptions().newBackOff().(*backoff.ConstantBackOff)
+	require.True(ok, "constant backoff should be used by default")
+
+	initial, maxInterval := 50*time.Millisecond, 200*time.Millisecond
+	co := NewCallOptions(WithExponentialBackoff(initial, maxInterval, 2))
+	eb, ok := co.newBackOff().(*backoff.ExponentialBackOff)
+	require.True(ok, "exponential backoff should be used")
+	require.Equal(initial, eb.InitialInterval)
+	require.Equal(maxInterval, eb.MaxInterval)
+	require.Equal(2.0, eb.Multiplier)
+	require.Positive(eb.RandomizationFactor, "backoff should use jitter")
+
+	// Retries should still be bounded by the maximum number of retries.
+	s.services[0].takeNonces()
+	start := time.Now()
+	peer := s.serverHosts[0].ID()
+	var rsp testResponse
+	_, err := s.client.Call(ctx, peer, testMethod, &testRequest{}, &rsp,
+		WithMaxRetries(3),
+		WithExponentialBackoff(initial, maxInterval, 2),
+	)
+	require.Error(err, "Call should fail")
+	require.Len(s.services[0].takeNonces(), 4, "call should be attempted once and retried 3 times")
+
+	// With 50% jitter, the intervals should be at least 25ms, 50ms and 100ms.
+	require.GreaterOrEqual(time.Since(start), 175*time.Millisecond)
+}
+
+func (s *RPCTestSuite) TestCallDefaultOptions() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewClient(s.clientHost, testProtocol, WithDefaultCallOptions(
+		WithMaxRetries(2),
+		WithRetryInterval(10*time.Millisecond),
+	))
+
+	// The first server always fails, so all retries will be attempted.
+	peer := s.serverHosts[0].ID()
+	service := s.services[0]
+
+	s.Run("Default options", func() {
+		require := require.New(s.T())
+
+		_ = service.takeNonces()
+		var rsp testResponse
+		_, err := client.Call(ctx, peer, testMethod, &testRequest{}, &rsp)
+		require.Error(err, "Call should fail")
+		require.Len(service.takeNonces(), 3, "call should be retried as configured by default")
+	})
+
+	s.Run("Overridden options", func() {
+		require := require.New(s.T())
+
+		_ = service.takeNonces()
+		var rsp testResponse
+		_, err := client.Call(ctx, peer, testMethod, &testRequest{}, &rsp, WithMaxRetries(0))
+		require.Error(err, "Call should fail")
+		require.Len(service.takeNonces(), 1, "per-call options should override the defaults")
+	})
+}
+
+func (s *RPCTestSuite) TestCallRetryableFn() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	peer := s.serverHosts[0].ID()
+
+	for _, tc := range []struct {
+		name      string
+		retryable bool
+		attempts  int
+	}{
+		{"Retryable", true, 4},
+		{"Permanent", false, 1},
+	} {
+		s.Run(tc.name, func() {
+			require := require.New(s.T())
+
+			s.services[0].takeNonces()
+			var rsp testResponse
+			_, err := s.client.Call(ctx, peer, testMethod, &testRequest{}, &rsp,
+				WithMaxRetries(3),
+				WithRetryInterval(10*time.Millisecond),
+				WithRetryableFn(func(err error) bool {
+					require.ErrorContains(err, "first two servers are corrupted")
+					return tc.retryable
+				}),
+			)
+			require.Error(err, "Call should fail")
+			require.Len(s.services[0].takeNonces(), tc.attempts)
+		})
+	}
+}
+
+func (s *RPCTestSuite) TestCallAny() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s.Run("Fastest peer wins", func() {
+		require := require.New(s.T())
+
+		slowPeer := s.serverHosts[3].ID()
+		fastPeer := s.serverHosts[2].ID()
+
+		start := time.Now()
+		var rsp testResponse
+		pf, err := s.client.CallAny(ctx, []peer.ID{slowPeer, fastPeer}, testSlowMethod, &testRequest{}, &rsp)
+		require.NoError(err, "CallAny failed")
+		require.Equal(2, rsp.ID)
+		require.Equal(fastPeer, pf.PeerID())
+		require.Less(time.Since(start), 2*testSlowMethodDelay, "CallAny should not wait for slow peers")
+	})
+
+	s.Run("Failing peers", func() {
+		require := require.New(s.T())
+
+		peers := []peer.ID{s.serverHosts[0].ID(), s.serverHosts[1].ID(), s.serverHosts[2].ID()}
+
+		var rsp testResponse
+		pf, err := s.client.CallAny(ctx, peers, testMethod, &testRequest{}, &rsp)
+		require.NoError(err, "CallAny failed")
+		require.Equal(2, rsp.ID)
+		require.Equal(peers[2], pf.PeerID())
+	})
+
+	s.Run("Validation", func() {
+		require := require.New(s.T())
+
+		peers := []peer.ID{s.serverHosts[2].ID(), s.serverHosts[3].ID()}
+
+		var rsp testResponse
+		pf, err := s.client.CallAny(ctx, peers, testMethod, &testRequest{}, &rsp,
+			WithValidationFn(func(pf PeerFeedback) error {
+				if rsp.ID != 3 {
+					return fmt.Errorf("invalid response")
+				}
+				return nil
+			}),
+		)
+		require.NoError(err, "CallAny failed")
+		require.Equal(3, rsp.ID)
+		require.Equal(peers[1], pf.PeerID())
+	})
+
+	s.Run("Retries", func() {
+		require := require.New(s.T())
+
+		peers := []peer.ID{s.serverHosts[0].ID(), s.serverHosts[1].ID()}
+		s.services[0].takeNonces()
+		s.services[1].takeNonces()
+
+		var rsp testResponse
+		_, err := s.client.CallAny(ctx, peers, testMethod, &testRequest{}, &rsp,
+			WithMaxRetries(2),
+			WithRetryInterval(10*time.Millisecond),
+		)
+		require.ErrorContains(err, "call failed on all peers")
+		require.Len(s.services[0].takeNonces(), 3)
+		require.Len(s.services[1].takeNonces(), 3)
+	})
+}
+
+func (s *RPCTestSuite) TestCallNonce() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The first server always fails, so all retries will be attempted.
+	peer := s.serverHosts[0].ID()
+	service := s.services[0]
+
+	call := func(opts ...CallOption) [][]byte {
+		opts = append(opts, WithMaxRetries(2), WithRetryInterval(10*time.Millisecond))
+
+		_ = service.takeNonces()
+		var rsp testResponse
+		_, err := s.client.Call(ctx, peer, testMethod, &testRequest{}, &rsp, opts...)
+		require.Error(s.T(), err, "Call did not fail")
+
+		nonces := service.takeNonces()
+		require.Len(s.T(), nonces, 3)
+		return nonces
+	}
+
+	s.Run("Without nonce", func() {
+		require := require.New(s.T())
+
+		for _, nonce := range call() {
+			require.Nil(nonce)
+		}
+	})
+
+	s.Run("Stable nonce", func() {
+		require := require.New(s.T())
+
+		nonces := call(WithNonce())
+		require.Len(nonces[0], RequestNonceSize)
+		for _, nonce := range nonces {
+			require.Equal(nonces[0], nonce, "nonce should be stable across retries")
+		}
+
+		// Nonces should differ across logical calls.
+		require.NotEqual(nonces[0], call(WithNonce())[0])
+	})
+
+	s.Run("Fresh nonce per attempt", func() {
+		require := require.New(s.T())
+
+		nonces := call(WithFreshNoncePerAttempt())
+		seen := make(map[string]struct{})
+		for _, nonce := range nonces {
+			require.Len(nonce, RequestNonceSize)
+			seen[string(nonce)] = struct{}{}
+		}
+		require.Len(seen, len(nonces), "nonce should be fresh for each attempt")
+	})
 }
 
 func (s *RPCTestSuite) TestCallMulti() {
@@ -276,6 +968,435 @@ func (s *RPCTestSuite) TestCallMulti() {
 	})
 }
 
+func (s *RPCTestSuite) TestCallMultiResponseFactory() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require := require.New(s.T())
+
+	peers := make([]peer.ID, 0, len(s.serverHosts))
+	for _, host := range s.serverHosts {
+		peers = append(peers, host.ID())
+	}
+
+	var created atomic.Int32
+	factory := func() interface{} {
+		created.Add(1)
+		return &testResponse{}
+	}
+
+	rsps, _, err := s.client.CallMulti(ctx, peers, testMethod, &testRequest{}, nil,
+		WithResponseFactory(factory),
+		WithOrderedResults(),
+	)
+	require.NoError(err, "CallMulti failed")
+	require.Len(rsps, len(peers))
+	require.EqualValues(len(peers), created.Load(), "factory should be invoked for each peer")
+
+	// The first two servers are corrupted.
+	require.Nil(rsps[0])
+	require.Nil(rsps[1])
+	for i := 2; i < len(peers); i++ {
+		rsp, ok := rsps[i].(*testResponse)
+		require.True(ok, "response should be of the type returned by the factory")
+		require.Equal(i, rsp.ID)
+	}
+}
+
+func (s *RPCTestSuite) TestCallMultiFailureCollector() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require := require.New(s.T())
+
+	peers := make([]peer.ID, 0, len(s.serverHosts))
+	for _, host := range s.serverHosts {
+		peers = append(peers, host.ID())
+	}
+
+	failures := make(map[core.PeerID]error)
+	var rsp testResponse
+	rsps, _, err := s.client.CallMulti(ctx, peers, testMethod, &testRequest{}, &rsp,
+		WithFailureCollector(func(peerID core.PeerID, err error) {
+			failures[peerID] = err
+		}),
+	)
+	require.NoError(err, "CallMulti failed")
+	require.Len(rsps, 2)
+
+	// The first two servers are corrupted.
+	require.Len(failures, 2)
+	for _, peer := range peers[:2] {
+		require.ErrorContains(failures[peer], "first two servers are corrupted")
+	}
+}
+
+func (s *RPCTestSuite) TestCallMultiPerPeerLimit() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The third server responds to slow requests after a delay.
+	slowPeer := s.serverHosts[2].ID()
+	peers := []peer.ID{slowPeer, slowPeer, slowPeer}
+
+	call := func(opts ...CallMultiOption) time.Duration {
+		start := time.Now()
+		var rsp testResponse
+		rsps, _, err := s.client.CallMulti(ctx, peers, testSlowMethod, &testRequest{}, &rsp, opts...)
+		require.NoError(s.T(), err, "CallMulti failed")
+		require.Len(s.T(), rsps, len(peers))
+		return time.Since(start)
+	}
+
+	s.Run("Without limit", func() {
+		require.Less(s.T(), call(), 2*testSlowMethodDelay, "requests should be made in parallel")
+	})
+
+	s.Run("With limit", func() {
+		require.GreaterOrEqual(s.T(), call(WithPerPeerLimit(1)), 3*testSlowMethodDelay, "requests should be made sequentially")
+	})
+}
+
+func (s *RPCTestSuite) TestCallMultiDeadline() {
+	// The third server responds to slow requests after a delay, the last one hangs for twice
+	// as long.
+	peers := []peer.ID{s.serverHosts[2].ID(), s.serverHosts[3].ID()}
+
+	// Use a separate client as aborted requests may still be reported to the listener after
+	// the call returns.
+	client := NewClient(s.clientHost, testProtocol)
+
+	call := func(timeout time.Duration, opts ...CallMultiOption) ([]interface{}, time.Duration, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		start := time.Now()
+		var rsp testResponse
+		rsps, _, err := client.CallMulti(ctx, peers, testSlowMethod, &testRequest{}, &rsp, opts...)
+		return rsps, time.Since(start), err
+	}
+
+	s.Run("Partial results", func() {
+		require := require.New(s.T())
+
+		rsps, took, err := call(3 * testSlowMethodDelay / 2)
+		require.NoError(err, "CallMulti should return partial results")
+		require.Len(rsps, 1)
+		require.Equal(2, (*rsps[0].(**testResponse)).ID)
+		require.Less(took, 2*testSlowMethodDelay, "hanging peer should not be waited for")
+	})
+
+	s.Run("Deadline margin", func() {
+		require := require.New(s.T())
+
+		rsps, took, err := call(5*time.Second, WithDeadlineMargin(5*time.Second-3*testSlowMethodDelay/2))
+		require.NoError(err, "CallMulti should return partial results")
+		require.Len(rsps, 1)
+		require.Equal(2, (*rsps[0].(**testResponse)).ID)
+		require.Less(took, 2*testSlowMethodDelay, "hanging peer should not be waited for")
+	})
+
+	s.Run("No results", func() {
+		require := require.New(s.T())
+
+		rsps, took, err := call(testSlowMethodDelay / 2)
+		require.ErrorIs(err, context.DeadlineExceeded)
+		require.Empty(rsps)
+		require.Less(took, testSlowMethodDelay, "call should be aborted on deadline")
+	})
+
+}
+
+func (s *RPCTestSuite) TestCallMultiOrderedResults() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require := require.New(s.T())
+
+	// Use the reverse order of the servers, so that the order differs from the server IDs.
+	peers := make([]peer.ID, 0, len(s.serverHosts))
+	for i := len(s.serverHosts) - 1; i >= 0; i-- {
+		peers = append(peers, s.serverHosts[i].ID())
+	}
+	var rsp testResponse
+	rsps, pfs, err := s.client.CallMulti(ctx, peers, testMethod, &testRequest{}, &rsp, WithOrderedResults())
+	require.NoError(err, "CallMulti failed")
+	require.Len(rsps, len(peers))
+	require.Len(pfs, len(peers))
+
+	for i := range peers {
+		id := len(peers) - 1 - i
+		if id < 2 {
+			// The first two servers are corrupted.
+			require.Nil(rsps[i])
+			require.Nil(pfs[i])
+			continue
+		}
+		require.Equal(id, (*rsps[i].(**testResponse)).ID)
+		require.Equal(peers[i], pfs[i].PeerID())
+	}
+}
+
+func (s *RPCTestSuite) TestCallMultiDetailed() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require := require.New(s.T())
+
+	peers := make([]peer.ID, 0, len(s.serverHosts))
+	for _, h := range s.serverHosts {
+		peers = append(peers, h.ID())
+	}
+	var rsp testResponse
+	results, err := s.client.CallMultiDetailed(ctx, peers, testMethod, &testRequest{}, &rsp)
+	require.NoError(err, "CallMultiDetailed failed")
+	require.Equal(2, len(results))
+
+	for _, result := range results {
+		decoded := *result.Response.(**testResponse)
+		require.Equal(peers[decoded.ID], result.PeerFeedback.PeerID())
+
+		// Raw response should match the decoded one.
+		var raw testResponse
+		err = cbor.Unmarshal(result.RawResponse, &raw)
+		require.NoError(err, "Unmarshal raw response")
+		require.Equal(*decoded, raw)
+		require.EqualValues(cbor.Marshal(decoded), result.RawResponse)
+	}
+
+	require.Equal(0, s.listener.successes)
+	require.Equal(2, s.listener.failures)
+	require.Equal(0, s.listener.badPeers)
+}
+
+func (s *RPCTestSuite) TestCallMultiPeerResponseTimes() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require := require.New(s.T())
+
+	fastPeer := s.serverHosts[2].ID()
+	slowPeer := s.serverHosts[3].ID()
+	peers := []peer.ID{fastPeer, slowPeer}
+
+	// Only the peer with a generous budget should be able to respond in time.
+	var rsp testResponse
+	rsps, pfs, err := s.client.CallMulti(ctx, peers, testSlowMethod, &testRequest{}, &rsp,
+		WithMaxPeerResponseTimeMulti(testSlowMethodDelay/2),
+		WithMaxPeerResponseTimesMulti(map[core.PeerID]time.Duration{
+			slowPeer: 4 * testSlowMethodDelay,
+		}),
+	)
+	require.NoError(err, "CallMulti failed")
+	require.Equal(1, len(rsps))
+	require.Equal(1, len(pfs))
+	require.Equal(slowPeer, pfs[0].PeerID())
+	require.Equal(3, (*rsps[0].(**testResponse)).ID)
+
+	require.Equal(0, s.listener.successes)
+	require.Equal(1, s.listener.failures)
+	require.Equal(0, s.listener.badPeers)
+}
+
+func (s *RPCTestSuite) TestCallMultiCanceled() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require := require.New(s.T())
+
+	fastPeer := s.serverHosts[2].ID()
+	slowPeer := s.serverHosts[3].ID()
+	peers := []peer.ID{fastPeer, slowPeer}
+
+	// Cancel the context as soon as the first response is received.
+	callCtx, callCancel := context.WithCancel(ctx)
+	defer callCancel()
+
+	start := time.Now()
+	var rsp testResponse
+	rsps, pfs, err := s.client.CallMulti(callCtx, peers, testSlowMethod, &testRequest{}, &rsp,
+		WithAggregateFn(func(interface{}, PeerFeedback) bool {
+			callCancel()
+			return true
+		}),
+	)
+	require.ErrorIs(err, context.Canceled)
+	require.Less(time.Since(start), 2*testSlowMethodDelay, "CallMulti should return promptly")
+	require.Equal(1, len(rsps))
+	require.Equal(1, len(pfs))
+	require.Equal(fastPeer, pfs[0].PeerID())
+}
+
+func (s *RPCTestSuite) TestCallTracer() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require := require.New(s.T())
+
+	path := filepath.Join(s.T().TempDir(), "trace.jsonl")
+	tracer, err := NewCallTracer(path)
+	require.NoError(err, "NewCallTracer")
+
+	client := NewClient(s.clientHost, testProtocol, WithCallTracer(tracer))
+
+	badPeer := s.serverHosts[0].ID()
+	goodPeer := s.serverHosts[2].ID()
+	peers := []peer.ID{badPeer, goodPeer}
+
+	var rsp testResponse
+	_, err = client.CallOne(ctx, peers, testMethod, &testRequest{}, &rsp)
+	require.NoError(err, "CallOne failed")
+
+	_, _, err = client.CallMulti(ctx, peers, testMethod, &testRequest{}, &rsp)
+	require.NoError(err, "CallMulti failed")
+
+	_, err = client.Call(ctx, badPeer, testMethod, &testRequest{}, &rsp)
+	require.Error(err, "Call should fail")
+
+	err = tracer.Close()
+	require.NoError(err, "Close")
+
+	data, err := os.ReadFile(path)
+	require.NoError(err, "ReadFile")
+
+	var traces []*CallTrace
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var trace CallTrace
+		err = dec.Decode(&trace)
+		require.NoError(err, "Decode")
+		traces = append(traces, &trace)
+	}
+	require.Len(traces, 3, "there should be one trace per call")
+
+	for _, trace := range traces {
+		require.Equal(string(testProtocol), trace.Protocol)
+		require.Equal(testMethod, trace.Method)
+		require.False(trace.Start.IsZero())
+		require.Positive(trace.Duration)
+		for _, attempt := range trace.Attempts {
+			require.Contains(peers, attempt.PeerID)
+			require.False(attempt.Start.IsZero())
+			require.Positive(attempt.Latency)
+			require.Equal(attempt.PeerID == badPeer, attempt.Error != "")
+		}
+	}
+
+	// CallOne tries peers in order until one succeeds.
+	require.Equal(CallTraceKindCallOne, traces[0].Kind)
+	require.Empty(traces[0].Error)
+	require.Len(traces[0].Attempts, 2)
+	require.Equal(badPeer, traces[0].Attempts[0].PeerID)
+	require.Equal(goodPeer, traces[0].Attempts[1].PeerID)
+
+	// CallMulti tries all peers.
+	require.Equal(CallTraceKindCallMulti, traces[1].Kind)
+	require.Empty(traces[1].Error)
+	require.Len(traces[1].Attempts, 2)
+
+	// Failed calls record the error.
+	require.Equal(CallTraceKindCallOne, traces[2].Kind)
+	require.NotEmpty(traces[2].Error)
+	require.Len(traces[2].Attempts, 1)
+}
+
+func (s *RPCTestSuite) TestMetrics() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require := require.New(s.T())
+
+	labels := prometheus.Labels{"protocol": string(testProtocol), "method": testMethod}
+	calls := func() float64 { return testutil.ToFloat64(clientCalls.With(labels)) }
+	failures := func() float64 { return testutil.ToFloat64(clientFailures.With(labels)) }
+	badPeers := func() float64 { return testutil.ToFloat64(clientBadPeers.With(labels)) }
+	callsBefore, failuresBefore, badPeersBefore := calls(), failures(), badPeers()
+
+	// Clients with metrics disabled should not record anything.
+	peers := []peer.ID{s.serverHosts[0].ID(), s.serverHosts[2].ID()}
+	var rsp testResponse
+	pf, err := s.client.CallOne(ctx, peers, testMethod, &testRequest{}, &rsp)
+	require.NoError(err, "CallOne failed")
+	pf.RecordBadPeer()
+	require.Equal(callsBefore, calls())
+	require.Equal(failuresBefore, failures())
+	require.Equal(badPeersBefore, badPeers())
+
+	client := NewClient(s.clientHost, testProtocol, WithMetrics(true))
+	pf, err = client.CallOne(ctx, peers, testMethod, &testRequest{}, &rsp)
+	require.NoError(err, "CallOne failed")
+	require.Equal(callsBefore+2, calls())
+	require.Equal(failuresBefore+1, failures())
+	require.Equal(badPeersBefore, badPeers())
+
+	pf.RecordFailure()
+	pf.RecordBadPeer()
+	require.Equal(failuresBefore+2, failures())
+	require.Equal(badPeersBefore+1, badPeers())
+}
+
+func (s *RPCTestSuite) TestRateLimiter() {
+	peer := s.serverHosts[2].ID()
+
+	s.Run("Limited", func() {
+		require := require.New(s.T())
+
+		// Allow one call every 100ms without bursts.
+		client := NewClient(s.clientHost, testProtocol, WithRateLimiter(NewRateLimiter(10, 1)))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			var rsp testResponse
+			_, err := client.Call(ctx, peer, testMethod, &testRequest{}, &rsp)
+			require.NoError(err, "Call failed")
+		}
+		require.GreaterOrEqual(time.Since(start), 200*time.Millisecond, "calls should be rate limited")
+	})
+
+	// Allow one call every 10s, so that only the first call is not limited.
+	limiter := NewRateLimiter(0.1, 1)
+	client := NewClient(s.clientHost, testProtocol, WithRateLimiter(limiter))
+	var listener testListener
+	client.RegisterListener(&listener)
+
+	s.Run("Context canceled while waiting", func() {
+		require := require.New(s.T())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var rsp testResponse
+		_, err := client.Call(ctx, peer, testMethod, &testRequest{}, &rsp)
+		require.NoError(err, "Call failed")
+
+		shortCtx, shortCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer shortCancel()
+
+		_, err = client.Call(shortCtx, peer, testMethod, &testRequest{}, &rsp)
+		require.ErrorIs(err, context.DeadlineExceeded)
+		require.Zero(listener.failures, "waiting should not degrade the peer")
+	})
+
+	s.Run("Limits changed at runtime", func() {
+		require := require.New(s.T())
+
+		limiter.SetLimit(0, 1)
+		rate, burst := limiter.Limit()
+		require.Zero(rate)
+		require.EqualValues(1, burst)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		var rsp testResponse
+		_, err := client.Call(ctx, peer, testMethod, &testRequest{}, &rsp)
+		require.NoError(err, "calls should no longer be rate limited")
+	})
+}
+
 func (s *RPCTestSuite) TestListener() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
@@ -348,4 +1469,27 @@ func (s *RPCTestSuite) TestListener() {
 		pf.RecordSuccess()
 		test(s.T(), &listener, 2, 0, 0)
 	})
+
+	s.Run("Listeners", func() {
+		require := require.New(s.T())
+
+		client := NewClient(s.clientHost, testProtocol)
+		require.Empty(client.Listeners())
+
+		firstListener := testListener{}
+		secondListener := testListener{}
+		client.RegisterListener(&firstListener)
+		client.RegisterListener(&secondListener)
+		client.RegisterListener(&secondListener)
+
+		listeners := client.Listeners()
+		require.ElementsMatch([]ClientListener{&firstListener, &secondListener}, listeners)
+
+		// Modifying the snapshot should not affect the registered listeners.
+		listeners[0] = nil
+		require.ElementsMatch([]ClientListener{&firstListener, &secondListener}, client.Listeners())
+
+		client.UnregisterListener(&firstListener)
+		require.Equal([]ClientListener{&secondListener}, client.Listeners())
+	})
 }
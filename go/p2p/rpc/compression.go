@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"fmt"
+
+	"github.com/golang/snappy"
+)
+
+// maxDecompressedSize is the maximum size of a decompressed response body.
+const maxDecompressedSize = 64 * 1024 * 1024 // 64 MiB
+
+// CompressionCodec is a codec used to compress response bodies.
+type CompressionCodec uint8
+
+const (
+	// CompressionNone means that response bodies are not compressed.
+	CompressionNone CompressionCodec = 0
+	// CompressionSnappy means that response bodies are compressed using Snappy.
+	CompressionSnappy CompressionCodec = 1
+)
+
+// String returns a string representation of the compression codec.
+func (c CompressionCodec) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionSnappy:
+		return "snappy"
+	default:
+		return fmt.Sprintf("[unknown compression codec: %d]", uint8(c))
+	}
+}
+
+// IsSupported returns true iff the compression codec is supported.
+func (c CompressionCodec) IsSupported() bool {
+	switch c {
+	case CompressionNone, CompressionSnappy:
+		return true
+	default:
+		return false
+	}
+}
+
+// compress compresses the given data using the compression codec.
+func (c CompressionCodec) compress(data []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, data), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression codec: %s", c)
+	}
+}
+
+// decompress decompresses the given data using the compression codec.
+func (c CompressionCodec) decompress(data []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionSnappy:
+		n, err := snappy.DecodedLen(data)
+		if err != nil {
+			return nil, fmt.Errorf("malformed compressed data: %w", err)
+		}
+		if n > maxDecompressedSize {
+			return nil, fmt.Errorf("decompressed data too large (%d bytes)", n)
+		}
+		return snappy.Decode(nil, data)
+	default:
+		return nil, fmt.Errorf("unsupported compression codec: %s", c)
+	}
+}
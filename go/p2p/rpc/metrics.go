@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	clientCalls = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_p2p_rpc_client_calls",
+			Help: "Number of P2P RPC client calls to peers.",
+		},
+		[]string{"protocol", "method"},
+	)
+	clientFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_p2p_rpc_client_failures",
+			Help: "Number of failed P2P RPC client calls to peers.",
+		},
+		[]string{"protocol", "method"},
+	)
+	clientBadPeers = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_p2p_rpc_client_bad_peers",
+			Help: "Number of P2P RPC client calls to peers that were detected as malicious.",
+		},
+		[]string{"protocol", "method"},
+	)
+	clientLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "oasis_p2p_rpc_client_latency",
+			Help: "P2P RPC client call latency (seconds).",
+		},
+		[]string{"protocol", "method"},
+	)
+
+	rpcCollectors = []prometheus.Collector{
+		clientCalls,
+		clientFailures,
+		clientBadPeers,
+		clientLatency,
+	}
+
+	metricsOnce sync.Once
+)
+
+func initMetrics() {
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(rpcCollectors...)
+	})
+}
+
+func (c *client) metricsLabels(method string) prometheus.Labels {
+	return prometheus.Labels{
+		"protocol": string(c.protocolID),
+		"method":   method,
+	}
+}
+
+func (c *client) observeCall(method string, latency time.Duration) {
+	if !c.metricsEnabled {
+		return
+	}
+
+	labels := c.metricsLabels(method)
+	clientCalls.With(labels).Inc()
+	clientLatency.With(labels).Observe(latency.Seconds())
+}
+
+func (c *client) observeFailure(method string) {
+	if !c.metricsEnabled {
+		return
+	}
+
+	clientFailures.With(c.metricsLabels(method)).Inc()
+}
+
+func (c *client) observeBadPeer(method string) {
+	if !c.metricsEnabled {
+		return
+	}
+
+	clientBadPeers.With(c.metricsLabels(method)).Inc()
+}
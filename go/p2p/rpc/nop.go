@@ -70,6 +70,30 @@ func (c *nopClient) CallOne(
 	return nil, errUnsupported
 }
 
+// Implements Client.
+func (c *nopClient) CallAny(
+	context.Context,
+	[]peer.ID,
+	string,
+	interface{},
+	interface{},
+	...CallOption,
+) (PeerFeedback, error) {
+	return nil, errUnsupported
+}
+
+// Implements Client.
+func (c *nopClient) CallStream(
+	context.Context,
+	peer.ID,
+	string,
+	BodyWriterFunc,
+	interface{},
+	...CallOption,
+) (PeerFeedback, error) {
+	return nil, errUnsupported
+}
+
 // Implements Client.
 func (c *nopClient) CallMulti(
 	context.Context,
@@ -82,6 +106,18 @@ func (c *nopClient) CallMulti(
 	return nil, nil, errUnsupported
 }
 
+// Implements Client.
+func (c *nopClient) CallMultiDetailed(
+	context.Context,
+	[]peer.ID,
+	string,
+	interface{},
+	interface{},
+	...CallMultiOption,
+) ([]*CallMultiResult, error) {
+	return nil, errUnsupported
+}
+
 // Implements Client.
 func (c *nopClient) Close(
 	peer.ID,
@@ -101,3 +137,8 @@ func (c *nopClient) RegisterListener(ClientListener) {}
 
 // Implements Client.
 func (c *nopClient) UnregisterListener(ClientListener) {}
+
+// Implements Client.
+func (c *nopClient) Listeners() []ClientListener {
+	return nil
+}
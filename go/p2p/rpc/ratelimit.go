@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket rate limiter for outbound calls.
+//
+// The limits can be changed at runtime using SetLimit. A nil rate limiter does not limit calls.
+type RateLimiter struct {
+	mu sync.Mutex
+
+	rate   float64
+	burst  uint
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a new rate limiter allowing the given number of calls per second with
+// the given maximum burst size. A non-positive rate disables rate limiting.
+func NewRateLimiter(callsPerSecond float64, burst uint) *RateLimiter {
+	if burst == 0 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   callsPerSecond,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// SetLimit changes the number of calls per second and the maximum burst size.
+func (l *RateLimiter) SetLimit(callsPerSecond float64, burst uint) {
+	if burst == 0 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refillLocked(time.Now())
+	l.rate = callsPerSecond
+	l.burst = burst
+	l.tokens = math.Min(l.tokens, float64(burst))
+}
+
+// Limit returns the number of calls per second and the maximum burst size.
+func (l *RateLimiter) Limit() (float64, uint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.rate, l.burst
+}
+
+// Wait blocks until a call is allowed or the context is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	for {
+		delay := l.reserve()
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve takes a token if one is available, otherwise it returns the time until one will be.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.refillLocked(time.Now())
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return max(time.Duration((1-l.tokens)/l.rate*float64(time.Second)), time.Nanosecond)
+}
+
+func (l *RateLimiter) refillLocked(now time.Time) {
+	if l.rate > 0 {
+		l.tokens = math.Min(l.tokens+now.Sub(l.last).Seconds()*l.rate, float64(l.burst))
+	}
+	l.last = now
+}
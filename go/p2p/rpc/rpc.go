@@ -44,3 +44,17 @@ func PeerAddrInfoFromContext(ctx context.Context) (peer.AddrInfo, bool) {
 	peerAddrInfo, ok := ctx.Value(contextKeyPeerAddrInfo{}).(peer.AddrInfo)
 	return peerAddrInfo, ok
 }
+
+// contextKeyRequestNonce is the context key used for storing the request nonce.
+type contextKeyRequestNonce struct{}
+
+// WithRequestNonce creates a new context with the request nonce value set.
+func WithRequestNonce(parent context.Context, nonce []byte) context.Context {
+	return context.WithValue(parent, contextKeyRequestNonce{}, nonce)
+}
+
+// RequestNonceFromContext looks up the request nonce value in the given context.
+func RequestNonceFromContext(ctx context.Context) ([]byte, bool) {
+	nonce, ok := ctx.Value(contextKeyRequestNonce{}).([]byte)
+	return nonce, ok
+}
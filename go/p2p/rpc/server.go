@@ -76,6 +76,9 @@ func (s *server) HandleStream(stream network.Stream) {
 	// Handle request.
 	ctx, cancel := context.WithTimeout(context.Background(), RequestHandleTimeout)
 	ctx = WithPeerAddrInfo(ctx, addr)
+	if len(request.Nonce) > 0 {
+		ctx = WithRequestNonce(ctx, request.Nonce)
+	}
 	rsp, err := s.HandleRequest(ctx, request.Method, request.Body)
 	cancel()
 
@@ -84,6 +87,7 @@ func (s *server) HandleStream(stream network.Stream) {
 	switch err {
 	case nil:
 		response.Ok = cbor.Marshal(rsp)
+		s.compressResponse(&response, request.Compression)
 	default:
 		logger.Debug("failed to process request",
 			"err", err,
@@ -109,6 +113,29 @@ func (s *server) HandleStream(stream network.Stream) {
 	_ = stream.SetWriteDeadline(time.Time{})
 }
 
+// compressResponse compresses the response body using the codec requested by the client, unless
+// the codec is not supported or compression doesn't reduce the size of the body.
+func (s *server) compressResponse(response *Response, codec CompressionCodec) {
+	if codec == CompressionNone || !codec.IsSupported() {
+		return
+	}
+
+	compressed, err := codec.compress(response.Ok)
+	if err != nil {
+		s.logger.Debug("failed to compress response",
+			"err", err,
+			"codec", codec,
+		)
+		return
+	}
+	if len(compressed) >= len(response.Ok) {
+		return
+	}
+
+	response.Ok = cbor.Marshal(compressed)
+	response.Compression = codec
+}
+
 // NewServer creates a new RPC server for the given protocol.
 func NewServer(protocolID protocol.ID, srv Service) Server {
 	return &server{
@@ -0,0 +1,97 @@
+package rpc
+
+import (
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// minResponseSpeedWindow is the window over which the minimum response speed is enforced.
+const minResponseSpeedWindow = 1 * time.Second
+
+// minSpeedStream is a stream wrapper which aborts reads once the peer starts sending data at
+// a rate below the configured minimum speed.
+//
+// The speed is only enforced after the first byte has been received, as the time the peer needs
+// to start responding is bounded by the overall read deadline.
+type minSpeedStream struct {
+	network.Stream
+
+	minSpeed uint64
+	// deadline is the overall read deadline.
+	deadline time.Time
+
+	started     bool
+	windowStart time.Time
+	windowBytes uint64
+
+	tooSlow bool
+}
+
+func (s *minSpeedStream) Read(p []byte) (int, error) {
+	if s.tooSlow {
+		return 0, ErrPeerTooSlow
+	}
+
+	// Until the first byte arrives, only the overall deadline applies. Afterwards, the peer must
+	// send some data within each window.
+	readDeadline := s.deadline
+	if s.started {
+		if windowEnd := time.Now().Add(minResponseSpeedWindow); windowEnd.Before(readDeadline) {
+			readDeadline = windowEnd
+		}
+	}
+	_ = s.Stream.SetReadDeadline(readDeadline)
+
+	n, err := s.Stream.Read(p)
+	now := time.Now()
+	if n > 0 && !s.started {
+		s.started = true
+		s.windowStart = now
+	}
+	s.windowBytes += uint64(n)
+
+	if err != nil {
+		// Hitting the window deadline before the overall deadline means the peer is too slow.
+		if s.started && isTimeout(err) && now.Before(s.deadline) {
+			return n, s.abort()
+		}
+		return n, err
+	}
+
+	if elapsed := now.Sub(s.windowStart); s.started && elapsed >= minResponseSpeedWindow {
+		if float64(s.windowBytes)/elapsed.Seconds() < float64(s.minSpeed) {
+			return n, s.abort()
+		}
+
+		// Peer is fast enough, start a new window.
+		s.windowStart = now
+		s.windowBytes = 0
+	}
+
+	return n, nil
+}
+
+func (s *minSpeedStream) abort() error {
+	s.tooSlow = true
+	_ = s.Stream.Reset()
+	return ErrPeerTooSlow
+}
+
+func newMinSpeedStream(stream network.Stream, minSpeed uint64) *minSpeedStream {
+	return &minSpeedStream{
+		Stream:   stream,
+		minSpeed: minSpeed,
+	}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
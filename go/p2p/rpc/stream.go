@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+)
+
+// BodyWriterFunc is a function which writes the CBOR-encoded request body to the given writer.
+//
+// The function is called twice for each request sent, first to determine the size of the body
+// and then to send it, so it must write the same data each time.
+type BodyWriterFunc func(w io.Writer) error
+
+// countingWriter is a writer which counts the number of bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += uint64(n)
+	return n, err
+}
+
+// writeStreamedRequest writes the given request to the given writer, framed the same way as
+// requests written by the message codec, with the request body streamed using the body writer.
+func writeStreamedRequest(w io.Writer, request *Request, bodyWriter BodyWriterFunc) error {
+	// Determine the size of the body.
+	sizer := countingWriter{w: io.Discard}
+	if err := bodyWriter(&sizer); err != nil {
+		return fmt.Errorf("failed to encode request body: %w", err)
+	}
+
+	// Encode the request without the body, which is encoded last.
+	numFields := byte(2)
+	header := cbor.Marshal("method")
+	header = append(header, cbor.Marshal(request.Method)...)
+	if len(request.Nonce) > 0 {
+		numFields++
+		header = append(header, cbor.Marshal("nonce")...)
+		header = append(header, cbor.Marshal(request.Nonce)...)
+	}
+	if request.Compression != CompressionNone {
+		numFields++
+		header = append(header, cbor.Marshal("compression")...)
+		header = append(header, cbor.Marshal(request.Compression)...)
+	}
+	header = append(header, cbor.Marshal("body")...)
+	header = append([]byte{0xa0 | numFields}, header...) // Map with numFields pairs.
+
+	length := uint64(len(header)) + sizer.n
+	if length > math.MaxUint32 {
+		return fmt.Errorf("request too large")
+	}
+
+	bw := bufio.NewWriter(w)
+	var rawLength [4]byte
+	binary.BigEndian.PutUint32(rawLength[:], uint32(length))
+	if _, err := bw.Write(rawLength[:]); err != nil {
+		return err
+	}
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+
+	body := countingWriter{w: bw}
+	if err := bodyWriter(&body); err != nil {
+		return fmt.Errorf("failed to encode request body: %w", err)
+	}
+	if body.n != sizer.n {
+		return fmt.Errorf("request body size changed while streaming (expected: %d got: %d)", sizer.n, body.n)
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core"
+
+	"github.com/oasisprotocol/oasis-core/go/common/logging"
+)
+
+const (
+	// CallTraceKindCallOne is the kind of traces of calls made via Call or CallOne.
+	CallTraceKindCallOne = "call_one"
+	// CallTraceKindCallAny is the kind of traces of calls made via CallAny.
+	CallTraceKindCallAny = "call_any"
+	// CallTraceKindCallMulti is the kind of traces of calls made via CallMulti or CallMultiDetailed.
+	CallTraceKindCallMulti = "call_multi"
+)
+
+// CallTrace is a trace of a single client call.
+type CallTrace struct {
+	// Kind is the kind of the call.
+	Kind string `json:"kind"`
+	// Protocol is the protocol the call was made for.
+	Protocol string `json:"protocol"`
+	// Method is the called method.
+	Method string `json:"method"`
+	// Start is the time when the call started.
+	Start time.Time `json:"start"`
+	// Duration is the duration of the call in nanoseconds.
+	Duration time.Duration `json:"duration"`
+	// Attempts are the peer attempts made during the call, in order of completion.
+	Attempts []*CallTraceAttempt `json:"attempts"`
+	// Error is the error the call failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// CallTraceAttempt is a trace of a single attempt to call a peer.
+type CallTraceAttempt struct {
+	// PeerID is the identifier of the called peer.
+	PeerID core.PeerID `json:"peer_id"`
+	// Start is the time when the attempt started.
+	Start time.Time `json:"start"`
+	// Latency is the latency of the attempt in nanoseconds.
+	Latency time.Duration `json:"latency"`
+	// Error is the error the attempt failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// CallTracer writes traces of client calls to a file, one JSON-encoded CallTrace per line.
+type CallTracer struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+
+	logger *logging.Logger
+}
+
+// Close closes the trace file.
+func (t *CallTracer) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.f.Close()
+}
+
+func (t *CallTracer) write(trace *CallTrace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.enc.Encode(trace); err != nil {
+		t.logger.Warn("failed to write call trace",
+			"err", err,
+			"method", trace.Method,
+		)
+	}
+}
+
+// NewCallTracer creates a new call tracer appending traces to the file at the given path.
+func NewCallTracer(path string) (*CallTracer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open call trace file: %w", err)
+	}
+
+	return &CallTracer{
+		f:      f,
+		enc:    json.NewEncoder(f),
+		logger: logging.GetLogger("p2p/rpc/tracer"),
+	}, nil
+}
+
+// callTrace collects the trace of an in-progress call.
+//
+// A nil callTrace is valid and ignores all records, so that tracing can be disabled.
+type callTrace struct {
+	mu       sync.Mutex
+	tracer   *CallTracer
+	trace    CallTrace
+	finished bool
+}
+
+func (ct *callTrace) recordAttempt(peerID core.PeerID, start time.Time, latency time.Duration, err error) {
+	if ct == nil {
+		return
+	}
+
+	attempt := CallTraceAttempt{
+		PeerID:  peerID,
+		Start:   start,
+		Latency: latency,
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	// Attempts completing after the call has finished are not part of the call.
+	if ct.finished {
+		return
+	}
+	ct.trace.Attempts = append(ct.trace.Attempts, &attempt)
+}
+
+func (ct *callTrace) finish(err error) {
+	if ct == nil {
+		return
+	}
+
+	ct.mu.Lock()
+	ct.finished = true
+	ct.trace.Duration = time.Since(ct.trace.Start)
+	if err != nil {
+		ct.trace.Error = err.Error()
+	}
+	ct.mu.Unlock()
+
+	ct.tracer.write(&ct.trace)
+}
+
+func (c *client) newCallTrace(kind, method string) *callTrace {
+	if c.tracer == nil {
+		return nil
+	}
+
+	return &callTrace{
+		tracer: c.tracer,
+		trace: CallTrace{
+			Kind:     kind,
+			Protocol: string(c.protocolID),
+			Method:   method,
+			Start:    time.Now(),
+			Attempts: []*CallTraceAttempt{},
+		},
+	}
+}
@@ -3,6 +3,8 @@ package rpc
 import (
 	"fmt"
 
+	"github.com/libp2p/go-libp2p/core"
+
 	"github.com/oasisprotocol/oasis-core/go/common/cbor"
 	"github.com/oasisprotocol/oasis-core/go/common/errors"
 )
@@ -16,14 +18,61 @@ var (
 
 	// ErrBadRequest is an error raised when a given request is malformed.
 	ErrBadRequest = errors.New(ModuleName, 2, "rpc: bad request")
+
+	// ErrPeerTooSlow is an error raised when a peer sends its response slower than required.
+	ErrPeerTooSlow = errors.New(ModuleName, 3, "rpc: peer response too slow")
+
+	// ErrNoPeers is an error raised when no peers are given to service a request.
+	ErrNoPeers = errors.New(ModuleName, 4, "rpc: no peers given to service the request")
+
+	// ErrNotConnected is an error raised when a called peer is not connected and cannot be dialed.
+	ErrNotConnected = errors.New(ModuleName, 5, "rpc: peer not connected")
 )
 
+// PeerError is an error returned by a single peer while servicing a request.
+type PeerError struct {
+	// PeerID is the identifier of the peer.
+	PeerID core.PeerID
+	// Err is the error returned by the peer or by the response validation.
+	Err error
+}
+
+// AllPeersFailedError is an error raised when a request failed on all of the given peers.
+type AllPeersFailedError struct {
+	// Method is the name of the called method.
+	Method string
+	// Errors are the per-peer errors in the order in which the peers were tried.
+	Errors []PeerError
+}
+
+// Error implements error.
+func (e *AllPeersFailedError) Error() string {
+	if len(e.Errors) == 0 {
+		return "call failed on all peers"
+	}
+	return fmt.Sprintf("call failed on all peers: %s", e.Errors[len(e.Errors)-1].Err)
+}
+
+// Unwrap returns the error of the last peer that was tried.
+func (e *AllPeersFailedError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors[len(e.Errors)-1].Err
+}
+
 // Request is a request sent by the client.
 type Request struct {
 	// Method is the name of the method.
 	Method string `json:"method"`
 	// Body is the method-specific body.
 	Body cbor.RawMessage `json:"body"`
+	// Nonce is an optional request nonce which enables the server to detect replayed requests.
+	Nonce []byte `json:"nonce,omitempty"`
+	// Compression is an optional codec which the server may use to compress the response body.
+	//
+	// Servers which don't support response compression ignore this field.
+	Compression CompressionCodec `json:"compression,omitempty"`
 }
 
 // Error is a message body representing an error.
@@ -41,7 +90,11 @@ func (e Error) String() string {
 // Response is a response to a previously sent request.
 type Response struct {
 	// Ok is the method-specific response in case of success.
+	//
+	// If the response is compressed, this is the CBOR-encoded compressed response body.
 	Ok cbor.RawMessage `json:"ok,omitempty"`
 	// Error is an error response in case of failure.
 	Error *Error `json:"error,omitempty"`
+	// Compression is the codec used to compress the response body, if any.
+	Compression CompressionCodec `json:"compression,omitempty"`
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/oasisprotocol/oasis-core/go/common"
 	tpConfig "github.com/oasisprotocol/oasis-core/go/runtime/txpool/config"
 )
 
@@ -80,6 +81,10 @@ type Config struct {
 	// Runtime ID -> local config.
 	RuntimeConfig map[string]interface{} `yaml:"config,omitempty"`
 
+	// Runtime ID -> fallback key manager runtime ID, used when the runtime's key manager is not
+	// reachable.
+	FallbackKeyManagers map[string]string `yaml:"fallback_key_managers,omitempty"`
+
 	// Address(es) of sentry node(s) to connect to of the form [PubKey@]ip:port
 	// (where the PubKey@ part represents base64 encoded node TLS public key).
 	SentryAddresses []string `yaml:"sentry_addresses,omitempty"`
@@ -130,6 +135,16 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("unknown runtime environment: %s", c.Environment)
 	}
 
+	for rtID, kmID := range c.FallbackKeyManagers {
+		var id common.Namespace
+		if err := id.UnmarshalHex(rtID); err != nil {
+			return fmt.Errorf("malformed runtime ID in fallback_key_managers: %s", rtID)
+		}
+		if err := id.UnmarshalHex(kmID); err != nil {
+			return fmt.Errorf("malformed fallback key manager ID for runtime %s: %s", rtID, kmID)
+		}
+	}
+
 	switch c.Prune.Strategy {
 	case "none":
 	case "keep_last":
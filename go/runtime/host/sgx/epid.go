@@ -91,7 +91,7 @@ func (ep *teeStateEPID) update(
 		return nil, fmt.Errorf("error while requesting SPID info: %w", err)
 	}
 
-	// Update the SigRL (Not cached, knowing if revoked is important).
+	// Update the SigRL (Not cached by default, knowing if revoked is important).
 	sigRL, err := iasClient.GetSigRL(ctx, ep.epidGID)
 	if err != nil {
 		return nil, fmt.Errorf("error while requesting SigRL: %w", err)
@@ -1,15 +1,24 @@
 package committee
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/libp2p/go-libp2p/core"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/oasisprotocol/oasis-core/go/common"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
 	"github.com/oasisprotocol/oasis-core/go/common/logging"
+	"github.com/oasisprotocol/oasis-core/go/common/pubsub"
 	cmSync "github.com/oasisprotocol/oasis-core/go/common/sync"
 	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
 	keymanager "github.com/oasisprotocol/oasis-core/go/keymanager/api"
@@ -20,6 +29,186 @@ import (
 	keymanagerP2P "github.com/oasisprotocol/oasis-core/go/worker/keymanager/p2p"
 )
 
+var (
+	keyManagerCallCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_worker_keymanager_client_enclave_rpc_count",
+			Help: "Number of EnclaveRPC calls to key manager nodes.",
+		},
+		[]string{"keymanager", "kind"},
+	)
+	keyManagerCallSuccesses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_worker_keymanager_client_enclave_rpc_successes",
+			Help: "Number of successful EnclaveRPC calls to key manager nodes.",
+		},
+		[]string{"keymanager", "kind"},
+	)
+	keyManagerCallFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_worker_keymanager_client_enclave_rpc_failures",
+			Help: "Number of failed EnclaveRPC calls to key manager nodes.",
+		},
+		[]string{"keymanager", "kind"},
+	)
+	keyManagerCallLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "oasis_worker_keymanager_client_enclave_rpc_latency",
+			Help: "Latency of EnclaveRPC calls served by key manager nodes (seconds).",
+		},
+		[]string{"keymanager", "kind"},
+	)
+	keyManagerBadPeerFeedback = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oasis_worker_keymanager_client_bad_peer_feedback",
+			Help: "Number of key manager nodes reported as bad by the runtime.",
+		},
+		[]string{"keymanager", "kind"},
+	)
+
+	keyManagerClientCollectors = []prometheus.Collector{
+		keyManagerCallCount,
+		keyManagerCallSuccesses,
+		keyManagerCallFailures,
+		keyManagerCallLatency,
+		keyManagerBadPeerFeedback,
+	}
+
+	keyManagerClientMetricsOnce sync.Once
+)
+
+var (
+	// ErrKeyManagerNotAvailable is the error returned when no key manager is available.
+	ErrKeyManagerNotAvailable = errors.New("key manager not available")
+
+	// ErrInsufficientKeyManagerCommittee is the error returned when fewer than the configured
+	// minimum number of key manager committee members are resolved to peer identities.
+	ErrInsufficientKeyManagerCommittee = errors.New("insufficient key manager committee")
+
+	errNoKeyManagerNodes = errors.New("no key manager nodes available")
+)
+
+// nodeLatencyInvAlpha is the inverse alpha (1/alpha) value for computing the exponential moving
+// average of latencies of calls to key manager nodes.
+const nodeLatencyInvAlpha = 10
+
+// defaultNodeRefreshInterval is the default interval at which peer identities of key manager
+// nodes are resolved again.
+const defaultNodeRefreshInterval = 5 * time.Minute
+
+// sharedCallTimeout is the maximum duration of a call to the key manager committee shared between
+// concurrent callers, unless a shorter maximum call duration is configured.
+const sharedCallTimeout = time.Minute
+
+// KeyManagerRoutingMode is the mode in which EnclaveRPC calls are routed to key manager nodes.
+type KeyManagerRoutingMode uint8
+
+const (
+	// KeyManagerRoutingOneOf routes the call to the key manager nodes in turn until one of them
+	// succeeds.
+	KeyManagerRoutingOneOf KeyManagerRoutingMode = iota
+	// KeyManagerRoutingSingle routes the call to a single key manager node only, preferring
+	// the node which served the previous call.
+	KeyManagerRoutingSingle
+	// KeyManagerRoutingAll routes the call to all key manager nodes and succeeds only if all
+	// of them succeed and return the same response.
+	KeyManagerRoutingAll
+	// KeyManagerRoutingAny routes the call to the key manager nodes in parallel and succeeds
+	// with the first valid response. It should only be used for read-only calls.
+	KeyManagerRoutingAny
+)
+
+// KeyManagerRoutingPolicy is the policy for routing EnclaveRPC calls to key manager nodes.
+type KeyManagerRoutingPolicy struct {
+	// Mode is the routing mode.
+	Mode KeyManagerRoutingMode
+	// MaxRetries is the maximum number of times a failed call is retried.
+	MaxRetries uint64
+	// RetryInterval is the interval between call retries.
+	RetryInterval time.Duration
+}
+
+// DefaultKeyManagerRoutingPolicy is the routing policy used for EnclaveRPC calls of kinds which
+// have no routing policy configured and are not known to be read-only.
+var DefaultKeyManagerRoutingPolicy = KeyManagerRoutingPolicy{
+	Mode:          KeyManagerRoutingOneOf,
+	MaxRetries:    keymanagerP2P.MaxCallEnclaveRetries,
+	RetryInterval: rpc.DefaultCallRetryInterval,
+}
+
+// DefaultKeyManagerReadOnlyRoutingPolicy is the routing policy used for read-only EnclaveRPC
+// calls of kinds which have no routing policy configured.
+var DefaultKeyManagerReadOnlyRoutingPolicy = KeyManagerRoutingPolicy{
+	Mode:          KeyManagerRoutingAny,
+	MaxRetries:    keymanagerP2P.MaxCallEnclaveRetries,
+	RetryInterval: rpc.DefaultCallRetryInterval,
+}
+
+// peerTagger returns the peer tagger of the given P2P service, or nil if the service doesn't
+// support tagging important peers.
+func peerTagger(service p2p.Service) p2p.PeerTagger {
+	if service == nil {
+		return nil
+	}
+	pm := service.PeerManager()
+	if pm == nil {
+		return nil
+	}
+	return pm.PeerTagger()
+}
+
+// isReadOnlyKind returns true iff EnclaveRPC calls of the given kind are known to be read-only.
+func isReadOnlyKind(kind enclaverpc.Kind) bool {
+	return kind == enclaverpc.KindInsecureQuery
+}
+
+// KeyManagerClientOption is a key manager client wrapper option setter.
+type KeyManagerClientOption func(km *KeyManagerClientWrapper)
+
+// WithKeyManagerRoutingPolicy configures the routing policy for EnclaveRPC calls of the given kind.
+func WithKeyManagerRoutingPolicy(kind enclaverpc.Kind, policy KeyManagerRoutingPolicy) KeyManagerClientOption {
+	return func(km *KeyManagerClientWrapper) {
+		km.routingPolicies[kind] = policy
+	}
+}
+
+// WithKeyManagerCommitteeRetryRounds configures the number of additional rounds in which failed
+// EnclaveRPC calls are retried after refetching the key manager committee, in case committee
+// membership has changed. By default, failed calls are not retried with a refetched committee.
+func WithKeyManagerCommitteeRetryRounds(rounds uint64) KeyManagerClientOption {
+	return func(km *KeyManagerClientWrapper) {
+		km.committeeRetryRounds = rounds
+	}
+}
+
+// WithKeyManagerMaxCallDuration configures the maximum duration of EnclaveRPC calls, including
+// all retries. Calls with an earlier deadline are not affected. By default, the duration of calls
+// is only limited by the caller's context.
+func WithKeyManagerMaxCallDuration(d time.Duration) KeyManagerClientOption {
+	return func(km *KeyManagerClientWrapper) {
+		km.maxCallDuration = d
+	}
+}
+
+// WithKeyManagerNodeRefreshInterval configures the interval at which peer identities of key
+// manager nodes are resolved again, in case they changed without a key manager status update.
+// Zero disables periodic resolution.
+func WithKeyManagerNodeRefreshInterval(d time.Duration) KeyManagerClientOption {
+	return func(km *KeyManagerClientWrapper) {
+		km.nodeRefreshInterval = d
+	}
+}
+
+// WithKeyManagerMinNodes configures the minimum number of key manager committee members which
+// must be resolved to peer identities before the client is initialized and EnclaveRPC calls are
+// routed to the committee. Calls made while fewer members are resolved fail with
+// ErrInsufficientKeyManagerCommittee. By default, no minimum is enforced.
+func WithKeyManagerMinNodes(n int) KeyManagerClientOption {
+	return func(km *KeyManagerClientWrapper) {
+		km.minNodes = n
+	}
+}
+
 // KeyManagerClientWrapper is a wrapper for the key manager P2P client that handles deferred
 // initialization after the key manager runtime ID is known.
 //
@@ -35,7 +224,26 @@ type KeyManagerClientWrapper struct {
 	nt           *nodeTracker
 	logger       *logging.Logger
 
+	committeeNotifier *pubsub.Broker
+
+	fallbackID  *common.Namespace
+	fallbackCli keymanagerP2P.Client
+	fallbackNt  *nodeTracker
+
+	routingPolicies      map[enclaverpc.Kind]KeyManagerRoutingPolicy
+	committeeRetryRounds uint64
+	maxCallDuration      time.Duration
+	nodeRefreshInterval  time.Duration
+	minNodes             int
+
 	lastPeerFeedback rpc.PeerFeedback
+
+	// inflight deduplicates concurrent identical read-only EnclaveRPC calls.
+	inflight singleflight.Group
+
+	// badPeers are the peers reported as bad by the runtime. They are kept across key manager
+	// changes and are excluded from routing until cleared.
+	badPeers map[core.PeerID]struct{}
 }
 
 // Initialized returns a channel that gets closed when the client is initialized.
@@ -53,6 +261,95 @@ func (km *KeyManagerClientWrapper) Initialized() <-chan struct{} {
 	return km.nt.Initialized()
 }
 
+// WaitInitialized waits for the client to be initialized.
+//
+// In case there is no active key manager client, ErrKeyManagerNotAvailable is returned.
+func (km *KeyManagerClientWrapper) WaitInitialized(ctx context.Context) error {
+	km.l.Lock()
+	cli, nt := km.cli, km.nt
+	km.l.Unlock()
+
+	if cli == nil || nt == nil {
+		return ErrKeyManagerNotAvailable
+	}
+
+	select {
+	case <-nt.Initialized():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CommitteePeers returns the peer identities of the currently tracked key manager committee
+// members. If the client is not initialized, an empty list is returned.
+func (km *KeyManagerClientWrapper) CommitteePeers() []core.PeerID {
+	km.l.Lock()
+	nt := km.nt
+	km.l.Unlock()
+
+	if nt == nil {
+		return []core.PeerID{}
+	}
+
+	kmNodes := nt.Nodes(nil)
+	peers := make([]core.PeerID, 0, len(kmNodes))
+	for p := range kmNodes {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+// WatchCommittee returns a channel that produces the peer identities of the key manager
+// committee members each time the membership of the committee changes.
+//
+// The latest committee is sent immediately upon subscription, if known.
+func (km *KeyManagerClientWrapper) WatchCommittee() (<-chan []core.PeerID, *pubsub.Subscription) {
+	sub := km.committeeNotifier.Subscribe()
+	ch := make(chan []core.PeerID)
+	sub.Unwrap(ch)
+
+	return ch, sub
+}
+
+// PeerImportanceTaggingActive returns true iff key manager nodes are tagged as important peers,
+// making it less likely for connections to them to be pruned.
+func (km *KeyManagerClientWrapper) PeerImportanceTaggingActive() bool {
+	return peerTagger(km.p2p) != nil
+}
+
+// ClearBadPeers clears the set of peers reported as bad, so that calls are routed to them again.
+func (km *KeyManagerClientWrapper) ClearBadPeers() {
+	km.l.Lock()
+	defer km.l.Unlock()
+
+	km.logger.Info("clearing bad key manager peers",
+		"num_peers", len(km.badPeers),
+	)
+	km.badPeers = make(map[core.PeerID]struct{})
+}
+
+func (km *KeyManagerClientWrapper) markBadPeer(peer core.PeerID) {
+	km.l.Lock()
+	defer km.l.Unlock()
+
+	km.logger.Warn("excluding bad key manager peer from routing",
+		"peer_id", peer,
+	)
+	km.badPeers[peer] = struct{}{}
+}
+
+// withoutBadPeers removes peers reported as bad from the given key manager nodes.
+func (km *KeyManagerClientWrapper) withoutBadPeers(kmNodes map[core.PeerID]signature.PublicKey) map[core.PeerID]signature.PublicKey {
+	km.l.Lock()
+	defer km.l.Unlock()
+
+	for p := range km.badPeers {
+		delete(kmNodes, p)
+	}
+	return kmNodes
+}
+
 // SetKeyManagerID configures the key manager runtime ID to use.
 func (km *KeyManagerClientWrapper) SetKeyManagerID(id *common.Namespace) {
 	km.l.Lock()
@@ -78,14 +375,60 @@ func (km *KeyManagerClientWrapper) SetKeyManagerID(id *common.Namespace) {
 		km.nt = nil
 	default:
 		km.cli = keymanagerP2P.NewClient(km.p2p, km.chainContext, *id)
-		km.nt = newKeyManagerNodeTracker(km.p2p, km.consensus, *id)
+		km.nt = newKeyManagerNodeTracker(km.p2p, km.consensus, *id, km.nodeRefreshInterval, km.minNodes, km.committeeNotifier)
 		km.nt.Start()
+
+		// Connections to key manager nodes are only at risk of being pruned when P2P is enabled.
+		if km.p2p != nil && km.p2p.PeerManager() != nil && !km.PeerImportanceTaggingActive() {
+			km.logger.Warn("peer importance tagging not available, connections to key manager nodes may be pruned which reduces key manager connectivity reliability",
+				"keymanager_id", id,
+			)
+		}
+	}
+
+	km.lastPeerFeedback = nil
+}
+
+// SetFallbackKeyManagerID configures the fallback key manager runtime ID to use.
+//
+// EnclaveRPC calls are routed to the fallback key manager only in case no nodes of the primary
+// key manager are reachable. Calls to the fallback key manager may be served by any of its nodes.
+func (km *KeyManagerClientWrapper) SetFallbackKeyManagerID(id *common.Namespace) {
+	km.l.Lock()
+	defer km.l.Unlock()
+
+	// Only reinitialize in case the key manager ID changes.
+	if km.fallbackID == id || (km.fallbackID != nil && km.fallbackID.Equal(id)) {
+		return
+	}
+
+	km.logger.Debug("fallback key manager updated",
+		"keymanager_id", id,
+	)
+	km.fallbackID = id
+
+	if km.fallbackNt != nil {
+		km.fallbackNt.Stop()
+	}
+
+	switch id {
+	case nil:
+		km.fallbackCli = nil
+		km.fallbackNt = nil
+	default:
+		km.fallbackCli = keymanagerP2P.NewClient(km.p2p, km.chainContext, *id)
+		km.fallbackNt = newKeyManagerNodeTracker(km.p2p, km.consensus, *id, km.nodeRefreshInterval, km.minNodes, nil)
+		km.fallbackNt.Start()
 	}
 
 	km.lastPeerFeedback = nil
 }
 
 // CallEnclave implements runtimeKeymanager.Client.
+//
+// Concurrent identical read-only calls share a single call to the key manager committee, while
+// the peer feedback of each caller is recorded separately. The shared call is detached from the
+// callers' contexts, so a caller giving up does not affect the others.
 func (km *KeyManagerClientWrapper) CallEnclave(
 	ctx context.Context,
 	data []byte,
@@ -93,45 +436,113 @@ func (km *KeyManagerClientWrapper) CallEnclave(
 	kind enclaverpc.Kind,
 	pf *enclaverpc.PeerFeedback,
 ) ([]byte, signature.PublicKey, error) {
-	var node signature.PublicKey
+	// Feedback is recorded for each caller, even if its call is shared with other callers.
+	km.recordPeerFeedback(kind, pf)
 
-	km.l.Lock()
-	cli := km.cli
-	lastPf := km.lastPeerFeedback
-	km.l.Unlock()
+	if !isReadOnlyKind(kind) {
+		return km.callEnclave(ctx, data, nodes, kind)
+	}
 
-	if cli == nil {
-		return nil, node, fmt.Errorf("key manager not available")
+	type result struct {
+		data []byte
+		node signature.PublicKey
 	}
 
-	// Propagate peer feedback on the last EnclaveRPC call to guide routing decision.
-	if lastPf != nil {
-		// If no feedback has been provided by the runtime, treat previous call as success.
-		if pf == nil {
-			pfv := enclaverpc.PeerFeedbackSuccess
-			pf = &pfv
+	key := callEnclaveKey(data, nodes, kind)
+	ch := km.inflight.DoChan(key, func() (interface{}, error) {
+		timeout := sharedCallTimeout
+		if km.maxCallDuration > 0 && km.maxCallDuration < timeout {
+			timeout = km.maxCallDuration
 		}
+		sharedCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
 
-		km.logger.Debug("received peer feedback from runtime",
-			"peer_feedback", *pf,
-		)
+		data, node, err := km.callEnclave(sharedCtx, data, nodes, kind)
+		return &result{data, node}, err
+	})
 
-		switch *pf {
-		case enclaverpc.PeerFeedbackSuccess:
-			lastPf.RecordSuccess()
-		case enclaverpc.PeerFeedbackFailure:
-			lastPf.RecordFailure()
-		case enclaverpc.PeerFeedbackBadPeer:
-			lastPf.RecordBadPeer()
-		default:
+	select {
+	case res := <-ch:
+		r := res.Val.(*result)
+		if res.Err != nil {
+			return nil, r.node, res.Err
 		}
+		// Each caller gets its own copy of the response as it is shared between callers.
+		return bytes.Clone(r.data), r.node, nil
+	case <-ctx.Done():
+		return nil, signature.PublicKey{}, ctx.Err()
 	}
+}
 
-	// Call only members of the key manager committee. If no nodes are given, use all members.
-	kmNodes := km.nt.Nodes(nodes)
-	peers := make([]core.PeerID, 0, len(kmNodes))
-	for p := range kmNodes {
-		peers = append(peers, p)
+// callEnclaveKey derives the key used to deduplicate identical EnclaveRPC calls.
+func callEnclaveKey(data []byte, nodes []signature.PublicKey, kind enclaverpc.Kind) string {
+	parts := make([][]byte, 0, len(nodes)+2)
+	parts = append(parts, []byte{byte(kind)}, data)
+	for _, n := range nodes {
+		parts = append(parts, n[:])
+	}
+	h := hash.NewFromBytes(parts...)
+	return string(h[:])
+}
+
+// recordPeerFeedback propagates peer feedback on the last EnclaveRPC call to guide routing
+// decisions.
+func (km *KeyManagerClientWrapper) recordPeerFeedback(kind enclaverpc.Kind, pf *enclaverpc.PeerFeedback) {
+	km.l.Lock()
+	id, lastPf := km.id, km.lastPeerFeedback
+	km.l.Unlock()
+
+	if lastPf == nil {
+		return
+	}
+
+	// If no feedback has been provided by the runtime, treat previous call as success.
+	if pf == nil {
+		pfv := enclaverpc.PeerFeedbackSuccess
+		pf = &pfv
+	}
+
+	km.logger.Debug("received peer feedback from runtime",
+		"peer_feedback", *pf,
+	)
+
+	switch *pf {
+	case enclaverpc.PeerFeedbackSuccess:
+		lastPf.RecordSuccess()
+	case enclaverpc.PeerFeedbackFailure:
+		lastPf.RecordFailure()
+	case enclaverpc.PeerFeedbackBadPeer:
+		lastPf.RecordBadPeer()
+		km.markBadPeer(lastPf.PeerID())
+		keyManagerBadPeerFeedback.With(km.metricLabels(id, kind)).Inc()
+	default:
+	}
+}
+
+func (km *KeyManagerClientWrapper) callEnclave(
+	ctx context.Context,
+	data []byte,
+	nodes []signature.PublicKey,
+	kind enclaverpc.Kind,
+) ([]byte, signature.PublicKey, error) {
+	var node signature.PublicKey
+
+	km.l.Lock()
+	id, cli, nt := km.id, km.cli, km.nt
+	fallbackID, fallbackCli, fallbackNt := km.fallbackID, km.fallbackCli, km.fallbackNt
+	lastPf := km.lastPeerFeedback
+	km.l.Unlock()
+
+	if cli == nil && fallbackCli == nil {
+		return nil, node, ErrKeyManagerNotAvailable
+	}
+
+	// Bound the duration of the call, unless the caller's deadline is earlier.
+	callCtx := ctx
+	if km.maxCallDuration > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, km.maxCallDuration)
+		defer cancel()
 	}
 
 	req := &keymanagerP2P.CallEnclaveRequest{
@@ -139,19 +550,48 @@ func (km *KeyManagerClientWrapper) CallEnclave(
 		Kind: kind,
 	}
 
-	rsp, nextPf, err := cli.CallEnclave(ctx, req, peers)
-	if err != nil {
-		return nil, node, err
+	// Route the call as configured for its kind.
+	policy := km.routingPolicy(kind)
+
+	var (
+		rsp    *keymanagerP2P.CallEnclaveResponse
+		nextPf rpc.PeerFeedback
+		err    error
+	)
+	if cli != nil {
+		keyManagerCallCount.With(km.metricLabels(id, kind)).Inc()
+		rsp, nextPf, node, err = km.callCommittee(callCtx, cli, nt, policy, req, nodes, lastPf)
+		km.observeCall(id, kind, nextPf, err)
 	}
 
-	node, ok := kmNodes[nextPf.PeerID()]
-	if !ok {
-		return nil, node, fmt.Errorf("unknown peer id")
+	// Fail over to the fallback key manager in case the primary one is not reachable. The nodes
+	// are members of the primary key manager committee, so they don't restrict the fallback.
+	if (cli == nil || isUnreachableError(err)) && fallbackCli != nil {
+		km.logger.Warn("primary key manager not reachable, using fallback key manager",
+			"err", err,
+		)
+
+		cli = fallbackCli
+		keyManagerCallCount.With(km.metricLabels(fallbackID, kind)).Inc()
+		rsp, nextPf, node, err = km.callCommittee(callCtx, cli, fallbackNt, policy, req, nil, lastPf)
+		km.observeCall(fallbackID, kind, nextPf, err)
+	}
+	if err != nil {
+		// If the call was cut off by the maximum call duration, the runtime will not provide
+		// feedback on it, so make sure feedback on the last call is not propagated again.
+		if callCtx.Err() != nil && ctx.Err() == nil {
+			km.l.Lock()
+			if km.lastPeerFeedback == lastPf {
+				km.lastPeerFeedback = nil
+			}
+			km.l.Unlock()
+		}
+		return nil, node, err
 	}
 
 	// Store peer feedback instance that we can use.
 	km.l.Lock()
-	if km.cli == cli { // Key manager could get updated while we are doing the call.
+	if km.cli == cli || km.fallbackCli == cli { // Key manager could get updated while we are doing the call.
 		km.lastPeerFeedback = nextPf
 	}
 	km.l.Unlock()
@@ -159,14 +599,268 @@ func (km *KeyManagerClientWrapper) CallEnclave(
 	return rsp.Data, node, nil
 }
 
+func (km *KeyManagerClientWrapper) metricLabels(id *common.Namespace, kind enclaverpc.Kind) prometheus.Labels {
+	var keymanagerID string
+	if id != nil {
+		keymanagerID = id.String()
+	}
+
+	return prometheus.Labels{
+		"keymanager": keymanagerID,
+		"kind":       kind.String(),
+	}
+}
+
+// isUnreachableError returns true if the given error indicates that none of the key manager nodes
+// could be reached, as opposed to a node failing to serve the call.
+func isUnreachableError(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, rpc.ErrNoPeers),
+		errors.Is(err, errNoKeyManagerNodes),
+		errors.Is(err, ErrInsufficientKeyManagerCommittee):
+		return true
+	}
+
+	var allErr *rpc.AllPeersFailedError
+	if !errors.As(err, &allErr) || len(allErr.Errors) == 0 {
+		return false
+	}
+	for _, pe := range allErr.Errors {
+		if !errors.Is(pe.Err, rpc.ErrNotConnected) {
+			return false
+		}
+	}
+	return true
+}
+
+// observeCall records the outcome of a call to the key manager with the given ID.
+func (km *KeyManagerClientWrapper) observeCall(id *common.Namespace, kind enclaverpc.Kind, pf rpc.PeerFeedback, err error) {
+	labels := km.metricLabels(id, kind)
+	if err != nil {
+		keyManagerCallFailures.With(labels).Inc()
+		return
+	}
+	keyManagerCallSuccesses.With(labels).Inc()
+	keyManagerCallLatency.With(labels).Observe(pf.Latency().Seconds())
+}
+
+// callCommittee routes the call to the members of the key manager committee tracked by the given
+// node tracker, retrying failed calls as configured.
+func (km *KeyManagerClientWrapper) callCommittee(
+	ctx context.Context,
+	cli keymanagerP2P.Client,
+	nt *nodeTracker,
+	policy KeyManagerRoutingPolicy,
+	req *keymanagerP2P.CallEnclaveRequest,
+	nodes []signature.PublicKey,
+	lastPf rpc.PeerFeedback,
+) (*keymanagerP2P.CallEnclaveResponse, rpc.PeerFeedback, signature.PublicKey, error) {
+	var (
+		node    signature.PublicKey
+		kmNodes map[core.PeerID]signature.PublicKey
+		rsp     *keymanagerP2P.CallEnclaveResponse
+		pf      rpc.PeerFeedback
+		err     error
+	)
+	// Don't route calls to too few committee members.
+	if err = nt.checkMinNodes(); err != nil {
+		return nil, nil, node, err
+	}
+
+	for round := uint64(0); ; round++ {
+		// Call only members of the key manager committee. If no nodes are given, use all members.
+		// The committee is refetched in each round as its membership could have changed.
+		kmNodes = km.withoutBadPeers(nt.Nodes(nodes))
+		peers := nt.PeersByLatency(kmNodes)
+
+		rsp, pf, err = km.callRound(ctx, cli, policy, req, peers, lastPf)
+		if err == nil || round >= km.committeeRetryRounds || ctx.Err() != nil {
+			break
+		}
+
+		km.logger.Debug("failed to call key manager committee, retrying",
+			"err", err,
+			"round", round,
+		)
+	}
+	if err != nil {
+		return nil, nil, node, err
+	}
+
+	node, ok := kmNodes[pf.PeerID()]
+	if !ok {
+		return nil, nil, node, fmt.Errorf("unknown peer id")
+	}
+	nt.RecordLatency(pf.PeerID(), pf.Latency())
+
+	return rsp, pf, node, nil
+}
+
+func (km *KeyManagerClientWrapper) routingPolicy(kind enclaverpc.Kind) KeyManagerRoutingPolicy {
+	if policy, ok := km.routingPolicies[kind]; ok {
+		return policy
+	}
+	if isReadOnlyKind(kind) {
+		return DefaultKeyManagerReadOnlyRoutingPolicy
+	}
+	return DefaultKeyManagerRoutingPolicy
+}
+
+// callRound routes the call to the given peers, retrying failed calls as configured by the given
+// routing policy.
+func (km *KeyManagerClientWrapper) callRound(
+	ctx context.Context,
+	cli keymanagerP2P.Client,
+	policy KeyManagerRoutingPolicy,
+	req *keymanagerP2P.CallEnclaveRequest,
+	peers []core.PeerID,
+	lastPf rpc.PeerFeedback,
+) (*keymanagerP2P.CallEnclaveResponse, rpc.PeerFeedback, error) {
+	var (
+		rsp *keymanagerP2P.CallEnclaveResponse
+		pf  rpc.PeerFeedback
+	)
+	callFn := func() error {
+		var err error
+		rsp, pf, err = km.routeCall(ctx, cli, policy.Mode, req, peers, lastPf)
+		return err
+	}
+
+	retry := backoff.WithMaxRetries(backoff.NewConstantBackOff(policy.RetryInterval), policy.MaxRetries)
+	if err := backoff.Retry(callFn, backoff.WithContext(retry, ctx)); err != nil {
+		return nil, nil, err
+	}
+	return rsp, pf, nil
+}
+
+// routeCall makes a single attempt to route the call to the given peers in the given mode.
+func (km *KeyManagerClientWrapper) routeCall(
+	ctx context.Context,
+	cli keymanagerP2P.Client,
+	mode KeyManagerRoutingMode,
+	req *keymanagerP2P.CallEnclaveRequest,
+	peers []core.PeerID,
+	lastPf rpc.PeerFeedback,
+) (*keymanagerP2P.CallEnclaveResponse, rpc.PeerFeedback, error) {
+	// Retries are handled by the caller.
+	noRetries := rpc.WithMaxRetries(0)
+
+	// Peers are given in order of preference, so the peers selected by the client's peer manager
+	// must be tried in that order.
+	switch mode {
+	case KeyManagerRoutingOneOf:
+		return cli.CallEnclave(ctx, req, peers, noRetries, inOrder(peers))
+	case KeyManagerRoutingSingle:
+		if len(peers) == 0 {
+			return nil, nil, errNoKeyManagerNodes
+		}
+
+		// Prefer the peer that served the previous call, if it is still available.
+		peer := peers[0]
+		if lastPf != nil {
+			for _, p := range peers {
+				if p == lastPf.PeerID() {
+					peer = p
+					break
+				}
+			}
+		}
+
+		return cli.CallEnclave(ctx, req, []core.PeerID{peer}, noRetries)
+	case KeyManagerRoutingAll:
+		if len(peers) == 0 {
+			return nil, nil, errNoKeyManagerNodes
+		}
+
+		rsps := make([]*keymanagerP2P.CallEnclaveResponse, 0, len(peers))
+		pfs := make([]rpc.PeerFeedback, 0, len(peers))
+		for _, peer := range peers {
+			rsp, pf, err := cli.CallEnclave(ctx, req, []core.PeerID{peer}, noRetries)
+			if err != nil {
+				return nil, nil, fmt.Errorf("call to key manager node %s failed: %w", peer, err)
+			}
+			rsps = append(rsps, rsp)
+			pfs = append(pfs, pf)
+		}
+
+		// Nodes disagreeing with the majority are considered faulty. The call fails as there
+		// is no telling which response is correct.
+		majority := majorityResponse(rsps)
+		var conflicts int
+		for i, rsp := range rsps {
+			if !bytes.Equal(rsp.Data, majority.Data) {
+				pfs[i].RecordFailure()
+				conflicts++
+			}
+		}
+		if conflicts > 0 {
+			return nil, nil, fmt.Errorf("%d of %d key manager nodes returned a conflicting response", conflicts, len(rsps))
+		}
+
+		// Only the response of the first node is returned to the runtime, so feedback can
+		// only be provided for that node.
+		for _, pf := range pfs[1:] {
+			pf.RecordSuccess()
+		}
+
+		return rsps[0], pfs[0], nil
+	case KeyManagerRoutingAny:
+		return cli.CallEnclaveAny(ctx, req, peers, noRetries)
+	default:
+		return nil, nil, backoff.Permanent(fmt.Errorf("unsupported routing mode: %d", mode))
+	}
+}
+
+// majorityResponse returns the response returned by most nodes, preferring earlier responses
+// in case of a tie.
+func majorityResponse(rsps []*keymanagerP2P.CallEnclaveResponse) *keymanagerP2P.CallEnclaveResponse {
+	counts := make(map[string]int, len(rsps))
+	var (
+		majority *keymanagerP2P.CallEnclaveResponse
+		maxCount int
+	)
+	for _, rsp := range rsps {
+		counts[string(rsp.Data)]++
+		if count := counts[string(rsp.Data)]; count > maxCount {
+			majority, maxCount = rsp, count
+		}
+	}
+	return majority
+}
+
+// inOrder returns a call option which makes the client try peers in the order of the given peers.
+func inOrder(peers []core.PeerID) rpc.CallOption {
+	order := make(map[core.PeerID]int, len(peers))
+	for i, peer := range peers {
+		order[peer] = i
+	}
+	return rpc.WithPeerSort(func(a, b core.PeerID) bool {
+		return order[a] < order[b]
+	})
+}
+
 // NewKeyManagerClientWrapper creates a new key manager client wrapper.
-func NewKeyManagerClientWrapper(p2p p2p.Service, consensus consensus.Backend, chainContext string, logger *logging.Logger) *KeyManagerClientWrapper {
-	return &KeyManagerClientWrapper{
-		p2p:          p2p,
-		consensus:    consensus,
-		chainContext: chainContext,
-		logger:       logger,
+func NewKeyManagerClientWrapper(p2p p2p.Service, consensus consensus.Backend, chainContext string, logger *logging.Logger, opts ...KeyManagerClientOption) *KeyManagerClientWrapper {
+	keyManagerClientMetricsOnce.Do(func() {
+		prometheus.MustRegister(keyManagerClientCollectors...)
+	})
+
+	km := &KeyManagerClientWrapper{
+		p2p:                 p2p,
+		consensus:           consensus,
+		chainContext:        chainContext,
+		logger:              logger,
+		routingPolicies:     make(map[enclaverpc.Kind]KeyManagerRoutingPolicy),
+		committeeNotifier:   pubsub.NewBroker(true),
+		badPeers:            make(map[core.PeerID]struct{}),
+		nodeRefreshInterval: defaultNodeRefreshInterval,
+	}
+	for _, opt := range opts {
+		opt(km)
 	}
+	return km
 }
 
 type nodeTracker struct {
@@ -178,6 +872,20 @@ type nodeTracker struct {
 
 	nodes map[signature.PublicKey]core.PeerID
 
+	// committeeNotifier is notified of the peer identities of committee members when the
+	// membership of the committee changes. It may be nil.
+	committeeNotifier *pubsub.Broker
+
+	// refreshInterval is the interval at which peer identities of nodes are resolved again.
+	refreshInterval time.Duration
+
+	// minNodes is the minimum number of nodes which must be resolved to peer identities before
+	// the tracker is initialized.
+	minNodes int
+
+	// latencies are the exponential moving averages of latencies of calls to nodes.
+	latencies map[core.PeerID]time.Duration
+
 	initCh   chan struct{}
 	startOne cmSync.One
 
@@ -195,7 +903,7 @@ func (nt *nodeTracker) Start() {
 }
 
 // Initialized returns a channel that closes when the tracker fetches nodes from the key manager
-// status for the first time.
+// status for the first time and at least the minimum number of them is resolved.
 func (nt *nodeTracker) Initialized() <-chan struct{} {
 	return nt.initCh
 }
@@ -224,12 +932,131 @@ func (nt *nodeTracker) Nodes(nodes []signature.PublicKey) map[core.PeerID]signat
 	return peers
 }
 
+// checkMinNodes returns an error if fewer than the minimum number of nodes are tracked.
+func (nt *nodeTracker) checkMinNodes() error {
+	nt.Lock()
+	defer nt.Unlock()
+
+	if len(nt.nodes) < nt.minNodes {
+		return fmt.Errorf("%w: %d of %d required nodes resolved", ErrInsufficientKeyManagerCommittee, len(nt.nodes), nt.minNodes)
+	}
+	return nil
+}
+
+// RecordLatency records the latency of a completed call to the given peer.
+func (nt *nodeTracker) RecordLatency(peer core.PeerID, latency time.Duration) {
+	nt.Lock()
+	defer nt.Unlock()
+
+	if nt.latencies == nil {
+		nt.latencies = make(map[core.PeerID]time.Duration)
+	}
+
+	avg, ok := nt.latencies[peer]
+	if !ok {
+		nt.latencies[peer] = latency
+		return
+	}
+
+	// Compute exponential moving average.
+	avg += (latency - avg) / nodeLatencyInvAlpha
+	nt.latencies[peer] = avg
+}
+
+// PeersByLatency returns the given peers sorted in ascending order of the average latency of calls
+// to them. Peers without latency measurements are interleaved with the measured ones, so that they
+// still get probed.
+func (nt *nodeTracker) PeersByLatency(peers map[core.PeerID]signature.PublicKey) []core.PeerID {
+	nt.Lock()
+	defer nt.Unlock()
+
+	var measured, unmeasured []core.PeerID
+	for p := range peers {
+		if _, ok := nt.latencies[p]; ok {
+			measured = append(measured, p)
+			continue
+		}
+		unmeasured = append(unmeasured, p)
+	}
+	sort.SliceStable(measured, func(i, j int) bool {
+		return nt.latencies[measured[i]] < nt.latencies[measured[j]]
+	})
+
+	sorted := make([]core.PeerID, 0, len(peers))
+	for i := 0; i < len(measured) || i < len(unmeasured); i++ {
+		if i < len(measured) {
+			sorted = append(sorted, measured[i])
+		}
+		if i < len(unmeasured) {
+			sorted = append(sorted, unmeasured[i])
+		}
+	}
+	return sorted
+}
+
+// resolvePeerID resolves the peer identity of the given node from its node descriptor.
+func (nt *nodeTracker) resolvePeerID(ctx context.Context, nodeID signature.PublicKey) (core.PeerID, error) {
+	node, err := nt.consensus.Registry().GetNode(ctx, &registry.IDQuery{
+		ID:     nodeID,
+		Height: consensus.HeightLatest,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch node descriptor: %w", err)
+	}
+
+	peerID, err := p2p.PublicKeyToPeerID(node.P2P.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive peer ID: %w", err)
+	}
+	return peerID, nil
+}
+
+// peerID returns the currently tracked peer identity of the given node, if any.
+func (nt *nodeTracker) peerID(nodeID signature.PublicKey) (core.PeerID, bool) {
+	nt.Lock()
+	defer nt.Unlock()
+
+	peerID, ok := nt.nodes[nodeID]
+	return peerID, ok
+}
+
+// setNodes updates the tracked nodes, notifying subscribers if the committee membership changed.
+func (nt *nodeTracker) setNodes(nodes map[signature.PublicKey]core.PeerID) {
+	nt.Lock()
+	changed := len(nodes) != len(nt.nodes)
+	for n, p := range nodes {
+		if op, ok := nt.nodes[n]; !ok || op != p {
+			changed = true
+			break
+		}
+	}
+	nt.nodes = nodes
+	nt.Unlock()
+
+	if !changed || nt.committeeNotifier == nil {
+		return
+	}
+
+	peers := make([]core.PeerID, 0, len(nodes))
+	for _, p := range nodes {
+		peers = append(peers, p)
+	}
+	nt.committeeNotifier.Broadcast(peers)
+}
+
 func (nt *nodeTracker) trackKeymanagerNodes(ctx context.Context) {
 	stCh, stSub := nt.consensus.KeyManager().WatchStatuses()
 	defer stSub.Close()
 
+	var refreshCh <-chan time.Time
+	if nt.refreshInterval > 0 {
+		ticker := time.NewTicker(nt.refreshInterval)
+		defer ticker.Stop()
+		refreshCh = ticker.C
+	}
+
+	var status *keymanager.Status
 	for {
-		var status *keymanager.Status
 		select {
 		case <-ctx.Done():
 			return
@@ -241,6 +1068,12 @@ func (nt *nodeTracker) trackKeymanagerNodes(ctx context.Context) {
 			}
 
 			status = st
+		case <-refreshCh:
+			// Periodically resolve the nodes of the last status again, as their peer identities
+			// could have changed without a status update.
+			if status == nil {
+				continue
+			}
 		}
 
 		// It's not possible to service requests for this key manager.
@@ -256,40 +1089,48 @@ func (nt *nodeTracker) trackKeymanagerNodes(ctx context.Context) {
 		nodes := make(map[signature.PublicKey]core.PeerID, len(status.Nodes))
 		peers := make([]core.PeerID, 0, len(status.Nodes))
 		for _, nodeID := range status.Nodes {
-			node, err := nt.consensus.Registry().GetNode(ctx, &registry.IDQuery{
-				ID:     nodeID,
-				Height: consensus.HeightLatest,
-			})
+			peerID, err := nt.resolvePeerID(ctx, nodeID)
 			if err != nil {
-				nt.logger.Warn("failed to fetch node descriptor",
-					"err", err,
-					"node_id", nodeID,
-				)
-				continue
-			}
+				// Nodes are only dropped once they are no longer members of the committee,
+				// so retain the previously known peer identity, if any.
+				prevPeerID, ok := nt.peerID(nodeID)
+				if !ok {
+					nt.logger.Warn("failed to resolve peer ID of key manager node",
+						"err", err,
+						"node_id", nodeID,
+					)
+					continue
+				}
 
-			peerID, err := p2p.PublicKeyToPeerID(node.P2P.ID)
-			if err != nil {
-				nt.logger.Warn("failed to derive peer ID",
+				nt.logger.Warn("failed to resolve peer ID of key manager node, retaining previous peer ID",
 					"err", err,
 					"node_id", nodeID,
+					"peer_id", prevPeerID,
 				)
-				continue
+				peerID = prevPeerID
 			}
 
-			nodes[node.ID] = peerID
+			nodes[nodeID] = peerID
 			peers = append(peers, peerID)
 		}
 
 		// Mark them as important.
-		if pm := nt.p2p.PeerManager(); pm != nil {
-			pm.PeerTagger().SetPeerImportance(p2p.ImportantNodeKeyManager, nt.keymanagerID, peers)
+		if pt := peerTagger(nt.p2p); pt != nil {
+			pt.SetPeerImportance(p2p.ImportantNodeKeyManager, nt.keymanagerID, peers)
 		}
 
 		// Update nodes.
-		nt.Lock()
-		nt.nodes = nodes
-		nt.Unlock()
+		nt.setNodes(nodes)
+
+		// A single reachable node may be insufficient, wait for the minimum to be resolved.
+		if len(nodes) < nt.minNodes {
+			nt.logger.Warn("insufficient key manager nodes resolved",
+				"id", status.ID,
+				"num_nodes", len(nodes),
+				"min_nodes", nt.minNodes,
+			)
+			continue
+		}
 
 		// Signal initialization completed.
 		select {
@@ -306,13 +1147,28 @@ func (nt *nodeTracker) trackKeymanagerNodes(ctx context.Context) {
 
 // newKeyManagerNodeTracker creates a new tracker that is responsible for keeping the list
 // of key manager nodes and their peer identities up-to-date.
-func newKeyManagerNodeTracker(p2p p2p.Service, consensus consensus.Backend, keymanagerID common.Namespace) *nodeTracker {
+//
+// Besides on each key manager status update, peer identities of the nodes are resolved again at
+// the given refresh interval, unless it is zero. The tracker is initialized once at least minNodes
+// nodes are resolved. If a committee notifier is given, it is notified each time the committee
+// membership changes.
+func newKeyManagerNodeTracker(
+	p2p p2p.Service,
+	consensus consensus.Backend,
+	keymanagerID common.Namespace,
+	refreshInterval time.Duration,
+	minNodes int,
+	committeeNotifier *pubsub.Broker,
+) *nodeTracker {
 	return &nodeTracker{
-		p2p:          p2p,
-		consensus:    consensus,
-		keymanagerID: keymanagerID,
-		initCh:       make(chan struct{}),
-		startOne:     cmSync.NewOne(),
-		logger:       logging.GetLogger("worker/common/committee/keymanager/nodetracker"),
+		p2p:               p2p,
+		consensus:         consensus,
+		keymanagerID:      keymanagerID,
+		refreshInterval:   refreshInterval,
+		minNodes:          minNodes,
+		committeeNotifier: committeeNotifier,
+		initCh:            make(chan struct{}),
+		startOne:          cmSync.NewOne(),
+		logger:            logging.GetLogger("worker/common/committee/keymanager/nodetracker"),
 	}
 }
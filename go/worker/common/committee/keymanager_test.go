@@ -0,0 +1,961 @@
+package committee
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oasisprotocol/oasis-core/go/common"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	memorySigner "github.com/oasisprotocol/oasis-core/go/common/crypto/signature/signers/memory"
+	"github.com/oasisprotocol/oasis-core/go/common/logging"
+	p2p "github.com/oasisprotocol/oasis-core/go/p2p/api"
+	"github.com/oasisprotocol/oasis-core/go/p2p/rpc"
+	enclaverpc "github.com/oasisprotocol/oasis-core/go/runtime/enclaverpc/api"
+	keymanagerP2P "github.com/oasisprotocol/oasis-core/go/worker/keymanager/p2p"
+)
+
+type testP2P struct {
+	p2p.Service
+
+	pm   p2p.PeerManager
+	host host.Host
+}
+
+func (p *testP2P) PeerManager() p2p.PeerManager {
+	return p.pm
+}
+
+func (p *testP2P) Host() core.Host {
+	return p.host
+}
+
+func (p *testP2P) RegisterProtocol(core.ProtocolID, int, int) {}
+
+func (p *testP2P) BlockPeer(core.PeerID) {}
+
+type testPeerManager struct {
+	p2p.PeerManager
+
+	pt p2p.PeerTagger
+}
+
+func (m *testPeerManager) PeerTagger() p2p.PeerTagger {
+	return m.pt
+}
+
+type testPeerTagger struct{}
+
+func (t *testPeerTagger) SetPeerImportance(p2p.ImportanceKind, common.Namespace, []core.PeerID) {}
+
+type testPeerFeedback struct {
+	peerID  core.PeerID
+	latency time.Duration
+
+	successes atomic.Int32
+	failures  atomic.Int32
+	badPeers  atomic.Int32
+}
+
+func (pf *testPeerFeedback) RecordSuccess() {
+	pf.successes.Add(1)
+}
+
+func (pf *testPeerFeedback) RecordFailure() {
+	pf.failures.Add(1)
+}
+
+func (pf *testPeerFeedback) RecordBadPeer() {
+	pf.badPeers.Add(1)
+}
+
+func (pf *testPeerFeedback) PeerID() core.PeerID {
+	return pf.peerID
+}
+
+func (pf *testPeerFeedback) Latency() time.Duration {
+	return pf.latency
+}
+
+// testKeyManagerClient is a key manager protocol client which serves calls from the first
+// of the given peers, failing calls to peers marked as failing or unreachable. Peers marked as
+// conflicting serve calls with a response differing from the request.
+type testKeyManagerClient struct {
+	mu sync.Mutex
+
+	failing     map[core.PeerID]bool
+	unreachable map[core.PeerID]bool
+	conflicting map[core.PeerID]bool
+	latencies   map[core.PeerID]time.Duration
+	calls       [][]core.PeerID
+	anyCalls    int
+	feedback    []*testPeerFeedback
+
+	// onCall is called on each call, before the call is served.
+	onCall func()
+	// blocking causes calls to block until the context is done.
+	blocking bool
+}
+
+func (c *testKeyManagerClient) CallEnclave(ctx context.Context, request *keymanagerP2P.CallEnclaveRequest, peers []core.PeerID, _ ...rpc.CallOption) (*keymanagerP2P.CallEnclaveResponse, rpc.PeerFeedback, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, peers)
+	if c.onCall != nil {
+		c.onCall()
+	}
+	if c.blocking {
+		c.mu.Unlock()
+		<-ctx.Done()
+		c.mu.Lock()
+		return nil, nil, ctx.Err()
+	}
+
+	if len(peers) == 0 {
+		return nil, nil, rpc.ErrNoPeers
+	}
+
+	allErr := &rpc.AllPeersFailedError{}
+	for _, peer := range peers {
+		switch {
+		case c.unreachable[peer]:
+			allErr.Errors = append(allErr.Errors, rpc.PeerError{PeerID: peer, Err: rpc.ErrNotConnected})
+		case c.failing[peer]:
+			allErr.Errors = append(allErr.Errors, rpc.PeerError{PeerID: peer, Err: fmt.Errorf("call failed")})
+		default:
+			data := request.Data
+			if c.conflicting[peer] {
+				data = append([]byte("conflicting "), data...)
+			}
+			pf := &testPeerFeedback{peerID: peer, latency: c.latencies[peer]}
+			c.feedback = append(c.feedback, pf)
+			return &keymanagerP2P.CallEnclaveResponse{Data: data}, pf, nil
+		}
+	}
+	return nil, nil, allErr
+}
+
+func (c *testKeyManagerClient) CallEnclaveAny(ctx context.Context, request *keymanagerP2P.CallEnclaveRequest, peers []core.PeerID, opts ...rpc.CallOption) (*keymanagerP2P.CallEnclaveResponse, rpc.PeerFeedback, error) {
+	c.mu.Lock()
+	c.anyCalls++
+	c.mu.Unlock()
+
+	return c.CallEnclave(ctx, request, peers, opts...)
+}
+
+func (c *testKeyManagerClient) takeCalls() [][]core.PeerID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	calls := c.calls
+	c.calls = nil
+	return calls
+}
+
+func newTestKeyManagerClientWrapper(numNodes int, opts ...KeyManagerClientOption) (*KeyManagerClientWrapper, *testKeyManagerClient, []core.PeerID) {
+	nodes := make(map[signature.PublicKey]core.PeerID, numNodes)
+	peers := make([]core.PeerID, 0, numNodes)
+	for i := 0; i < numNodes; i++ {
+		var node signature.PublicKey
+		node[0] = byte(i)
+		peer := core.PeerID(fmt.Sprintf("peer-%d", i))
+
+		nodes[node] = peer
+		peers = append(peers, peer)
+	}
+
+	cli := &testKeyManagerClient{
+		failing:     make(map[core.PeerID]bool),
+		unreachable: make(map[core.PeerID]bool),
+		conflicting: make(map[core.PeerID]bool),
+		latencies:   make(map[core.PeerID]time.Duration),
+	}
+
+	km := NewKeyManagerClientWrapper(nil, nil, "", logging.GetLogger("test"), opts...)
+	km.cli = cli
+	km.nt = &nodeTracker{
+		nodes:             nodes,
+		committeeNotifier: km.committeeNotifier,
+		logger:            logging.GetLogger("test"),
+	}
+
+	return km, cli, peers
+}
+
+func TestKeyManagerRoutingPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	noRetries := KeyManagerRoutingPolicy{
+		Mode:          KeyManagerRoutingOneOf,
+		RetryInterval: time.Millisecond,
+	}
+	single := KeyManagerRoutingPolicy{
+		Mode:          KeyManagerRoutingSingle,
+		RetryInterval: time.Millisecond,
+	}
+	all := KeyManagerRoutingPolicy{
+		Mode:          KeyManagerRoutingAll,
+		MaxRetries:    2,
+		RetryInterval: time.Millisecond,
+	}
+
+	km, cli, peers := newTestKeyManagerClientWrapper(3,
+		WithKeyManagerRoutingPolicy(enclaverpc.KindNoiseSession, noRetries),
+		WithKeyManagerRoutingPolicy(enclaverpc.KindInsecureQuery, single),
+		WithKeyManagerRoutingPolicy(enclaverpc.KindLocalQuery, all),
+	)
+
+	t.Run("One of", func(t *testing.T) {
+		require := require.New(t)
+
+		rsp, _, err := km.CallEnclave(ctx, []byte("one of"), nil, enclaverpc.KindNoiseSession, nil)
+		require.NoError(err, "CallEnclave")
+		require.Equal([]byte("one of"), rsp)
+
+		calls := cli.takeCalls()
+		require.Len(calls, 1, "call should be routed once")
+		require.ElementsMatch(peers, calls[0], "call should be routed to all nodes")
+	})
+
+	t.Run("Single", func(t *testing.T) {
+		require := require.New(t)
+
+		_, node, err := km.CallEnclave(ctx, []byte("single"), nil, enclaverpc.KindInsecureQuery, nil)
+		require.NoError(err, "CallEnclave")
+
+		calls := cli.takeCalls()
+		require.Len(calls, 1, "call should be routed once")
+		require.Len(calls[0], 1, "call should be routed to a single node")
+		require.Equal(km.nt.nodes[node], calls[0][0])
+
+		// Subsequent calls should stick to the same node.
+		_, _, err = km.CallEnclave(ctx, []byte("single"), nil, enclaverpc.KindInsecureQuery, nil)
+		require.NoError(err, "CallEnclave")
+
+		calls2 := cli.takeCalls()
+		require.Len(calls2, 1, "call should be routed once")
+		require.Equal(calls[0], calls2[0], "call should be routed to the same node")
+	})
+
+	t.Run("All", func(t *testing.T) {
+		require := require.New(t)
+
+		_, _, err := km.CallEnclave(ctx, []byte("all"), nil, enclaverpc.KindLocalQuery, nil)
+		require.NoError(err, "CallEnclave")
+
+		calls := cli.takeCalls()
+		require.Len(calls, len(peers), "call should be routed to each node")
+		var called []core.PeerID
+		for _, call := range calls {
+			require.Len(call, 1, "call should be routed to each node separately")
+			called = append(called, call[0])
+		}
+		require.ElementsMatch(peers, called)
+	})
+
+	t.Run("All conflicting", func(t *testing.T) {
+		require := require.New(t)
+
+		cli.conflicting[peers[0]] = true
+		defer delete(cli.conflicting, peers[0])
+		cli.feedback = nil
+
+		// Calls routed to all nodes fail if any node returns a conflicting response.
+		_, _, err := km.CallEnclave(ctx, []byte("all"), nil, enclaverpc.KindLocalQuery, nil)
+		require.ErrorContains(err, "conflicting response")
+		require.Len(cli.takeCalls(), len(peers)*int(all.MaxRetries+1))
+
+		// Only the nodes disagreeing with the majority should be penalized.
+		for _, pf := range cli.feedback {
+			require.Zero(pf.successes.Load(), "success should not be recorded for conflicting responses")
+			if pf.peerID == peers[0] {
+				require.EqualValues(1, pf.failures.Load(), "failure should be recorded for the conflicting node")
+			} else {
+				require.Zero(pf.failures.Load(), "failure should not be recorded for agreeing nodes")
+			}
+		}
+
+		// Success should be recorded once all nodes agree.
+		delete(cli.conflicting, peers[0])
+		cli.feedback = nil
+		_, _, err = km.CallEnclave(ctx, []byte("all"), nil, enclaverpc.KindLocalQuery, nil)
+		require.NoError(err, "CallEnclave")
+		cli.takeCalls()
+		require.Len(cli.feedback, len(peers))
+		for _, pf := range cli.feedback[1:] {
+			require.EqualValues(1, pf.successes.Load(), "success should be recorded for agreeing nodes")
+		}
+	})
+
+	t.Run("Retries", func(t *testing.T) {
+		require := require.New(t)
+
+		cli.failing[peers[0]] = true
+		defer delete(cli.failing, peers[0])
+
+		// Calls routed to all nodes fail if any node fails, and are retried as configured.
+		_, _, err := km.CallEnclave(ctx, []byte("all"), nil, enclaverpc.KindLocalQuery, nil)
+		require.Error(err, "CallEnclave should fail")
+
+		var attempts int
+		for _, call := range cli.takeCalls() {
+			if call[0] == peers[0] {
+				attempts++
+			}
+		}
+		require.EqualValues(all.MaxRetries+1, attempts)
+
+		// Calls with no retries configured are attempted once.
+		for _, peer := range peers {
+			cli.failing[peer] = true
+		}
+		_, _, err = km.CallEnclave(ctx, []byte("one of"), nil, enclaverpc.KindNoiseSession, nil)
+		require.Error(err, "CallEnclave should fail")
+		require.Len(cli.takeCalls(), 1)
+	})
+}
+
+func TestKeyManagerCommitteeRetryRounds(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	policy := KeyManagerRoutingPolicy{
+		Mode:          KeyManagerRoutingOneOf,
+		RetryInterval: time.Millisecond,
+	}
+
+	for _, tc := range []struct {
+		name    string
+		rounds  uint64
+		success bool
+	}{
+		{"Without retry rounds", 0, false},
+		{"With retry rounds", 1, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			km, cli, peers := newTestKeyManagerClientWrapper(2,
+				WithKeyManagerRoutingPolicy(enclaverpc.KindNoiseSession, policy),
+				WithKeyManagerCommitteeRetryRounds(tc.rounds),
+			)
+
+			// All current committee members fail, but a new working member joins the committee
+			// once the first call is made.
+			for _, peer := range peers {
+				cli.failing[peer] = true
+			}
+			var newNode signature.PublicKey
+			newNode[0] = 0xff
+			newPeer := core.PeerID("peer-new")
+			cli.onCall = func() {
+				km.nt.Lock()
+				defer km.nt.Unlock()
+				km.nt.nodes[newNode] = newPeer
+			}
+
+			_, node, err := km.CallEnclave(ctx, []byte("retry"), nil, enclaverpc.KindNoiseSession, nil)
+			if !tc.success {
+				require.Error(err, "CallEnclave should fail")
+				require.Len(cli.takeCalls(), 1, "call should not be retried")
+				return
+			}
+			require.NoError(err, "CallEnclave")
+			require.Equal(newNode, node)
+			require.Len(cli.takeCalls(), 2, "call should be retried with the refetched committee")
+		})
+	}
+}
+
+func TestKeyManagerCommitteePeers(t *testing.T) {
+	require := require.New(t)
+
+	km := NewKeyManagerClientWrapper(nil, nil, "", logging.GetLogger("test"))
+	require.Empty(km.CommitteePeers(), "uninitialized client should have no committee peers")
+
+	km, _, peers := newTestKeyManagerClientWrapper(3)
+	require.ElementsMatch(peers, km.CommitteePeers())
+}
+
+func TestKeyManagerPeerImportanceTagging(t *testing.T) {
+	require := require.New(t)
+
+	km := NewKeyManagerClientWrapper(nil, nil, "", logging.GetLogger("test"))
+	require.False(km.PeerImportanceTaggingActive(), "tagging should not be active without P2P")
+
+	km = NewKeyManagerClientWrapper(&testP2P{}, nil, "", logging.GetLogger("test"))
+	require.False(km.PeerImportanceTaggingActive(), "tagging should not be active without a peer manager")
+
+	km = NewKeyManagerClientWrapper(&testP2P{pm: &testPeerManager{}}, nil, "", logging.GetLogger("test"))
+	require.False(km.PeerImportanceTaggingActive(), "tagging should not be active without a peer tagger")
+
+	km = NewKeyManagerClientWrapper(&testP2P{pm: &testPeerManager{pt: &testPeerTagger{}}}, nil, "", logging.GetLogger("test"))
+	require.True(km.PeerImportanceTaggingActive(), "tagging should be active")
+}
+
+func TestKeyManagerPeersByLatency(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require := require.New(t)
+
+	km, cli, peers := newTestKeyManagerClientWrapper(4,
+		WithKeyManagerRoutingPolicy(enclaverpc.KindInsecureQuery, KeyManagerRoutingPolicy{
+			Mode: KeyManagerRoutingSingle,
+		}),
+	)
+
+	// Without measurements, all peers should be returned.
+	require.ElementsMatch(peers, km.nt.PeersByLatency(km.nt.Nodes(nil)))
+
+	km.nt.RecordLatency(peers[0], 30*time.Millisecond)
+	km.nt.RecordLatency(peers[1], 10*time.Millisecond)
+
+	// Measured peers should be sorted by latency and interleaved with unmeasured ones.
+	sorted := km.nt.PeersByLatency(km.nt.Nodes(nil))
+	require.Len(sorted, len(peers))
+	require.Equal(peers[1], sorted[0])
+	require.Contains(peers[2:], sorted[1])
+	require.Equal(peers[0], sorted[2])
+	require.Contains(peers[2:], sorted[3])
+
+	// Latencies should be averaged.
+	km.nt.RecordLatency(peers[1], 410*time.Millisecond)
+	require.Equal(50*time.Millisecond, km.nt.latencies[peers[1]])
+	require.Equal(peers[0], km.nt.PeersByLatency(km.nt.Nodes(nil))[0])
+
+	// Calls should prefer the fastest peer and record the latency of completed calls.
+	cli.latencies[peers[0]] = 130 * time.Millisecond
+	_, _, err := km.CallEnclave(ctx, []byte("fastest"), nil, enclaverpc.KindInsecureQuery, nil)
+	require.NoError(err, "CallEnclave")
+	calls := cli.takeCalls()
+	require.Len(calls, 1)
+	require.Equal([]core.PeerID{peers[0]}, calls[0])
+	require.Equal(40*time.Millisecond, km.nt.latencies[peers[0]])
+}
+
+// testEnclave is a key manager enclave which responds with its own ID.
+type testEnclave struct {
+	id byte
+}
+
+func (e *testEnclave) CallEnclave(context.Context, []byte, enclaverpc.Kind) ([]byte, error) {
+	return []byte{e.id}, nil
+}
+
+func TestKeyManagerPeersByLatencyP2P(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require := require.New(t)
+
+	newHost := func() host.Host {
+		listenAddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
+		require.NoError(err, "NewMultiaddr")
+		signer, err := memorySigner.NewFactory().Generate(signature.SignerP2P, rand.Reader)
+		require.NoError(err, "Generate")
+		h, err := libp2p.New(
+			libp2p.ListenAddrs(listenAddr),
+			libp2p.Identity(p2p.SignerToPrivKey(signer)),
+		)
+		require.NoError(err, "libp2p.New")
+		t.Cleanup(func() { h.Close() })
+		return h
+	}
+
+	// Serve the key manager protocol from a few nodes.
+	chainContext := "test"
+	keymanagerID := common.NewTestNamespaceFromSeed([]byte("keymanager"), common.NamespaceKeyManager)
+	clientHost := newHost()
+	nodes := make(map[signature.PublicKey]core.PeerID)
+	var peers []core.PeerID
+	for i := 0; i < 4; i++ {
+		server := keymanagerP2P.NewServer(chainContext, keymanagerID, &testEnclave{id: byte(i)})
+		serverHost := newHost()
+		serverHost.SetStreamHandler(server.Protocol(), server.HandleStream)
+
+		err := clientHost.Connect(ctx, peer.AddrInfo{
+			ID:    serverHost.ID(),
+			Addrs: serverHost.Addrs(),
+		})
+		require.NoError(err, "Connect")
+
+		var node signature.PublicKey
+		node[0] = byte(i)
+		nodes[node] = serverHost.ID()
+		peers = append(peers, serverHost.ID())
+	}
+
+	km := NewKeyManagerClientWrapper(nil, nil, "", logging.GetLogger("test"),
+		WithKeyManagerRoutingPolicy(enclaverpc.KindInsecureQuery, KeyManagerRoutingPolicy{
+			Mode: KeyManagerRoutingOneOf,
+		}),
+	)
+	km.cli = keymanagerP2P.NewClient(&testP2P{host: clientHost}, chainContext, keymanagerID)
+	km.nt = &nodeTracker{
+		nodes:             nodes,
+		committeeNotifier: km.committeeNotifier,
+		logger:            logging.GetLogger("test"),
+	}
+
+	// Wait for the peer manager of the client to learn about all the peers.
+	require.Eventually(func() bool {
+		for _, peer := range peers {
+			req := &keymanagerP2P.CallEnclaveRequest{Kind: enclaverpc.KindInsecureQuery}
+			if _, _, err := km.cli.CallEnclave(ctx, req, []core.PeerID{peer}); err != nil {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Make one of the peers by far the fastest one.
+	for i, peer := range peers {
+		latency := time.Duration(i+1) * time.Second
+		if i == 2 {
+			latency = time.Millisecond
+		}
+		km.nt.RecordLatency(peer, latency)
+	}
+
+	// Calls should always be served by the fastest peer.
+	for i := 0; i < 20; i++ {
+		rsp, node, err := km.CallEnclave(ctx, []byte("fastest"), nil, enclaverpc.KindInsecureQuery, nil)
+		require.NoError(err, "CallEnclave")
+		require.Equal([]byte{2}, rsp)
+		require.Equal(peers[2], nodes[node])
+	}
+
+	// Peers blocked by the peer manager should be skipped, regardless of the order.
+	req := &keymanagerP2P.CallEnclaveRequest{Kind: enclaverpc.KindInsecureQuery}
+	order := []core.PeerID{peers[2], peers[0], peers[1], peers[3]}
+	rsp, pf, err := km.cli.CallEnclave(ctx, req, order, inOrder(order))
+	require.NoError(err, "CallEnclave")
+	require.Equal([]byte{2}, rsp.Data)
+	pf.RecordBadPeer()
+
+	rsp, _, err = km.cli.CallEnclave(ctx, req, order, inOrder(order))
+	require.NoError(err, "CallEnclave")
+	require.Equal([]byte{0}, rsp.Data, "bad peer should be skipped")
+}
+
+func TestKeyManagerFallback(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require := require.New(t)
+
+	policy := KeyManagerRoutingPolicy{
+		Mode:          KeyManagerRoutingOneOf,
+		RetryInterval: time.Millisecond,
+	}
+	km, cli, peers := newTestKeyManagerClientWrapper(2,
+		WithKeyManagerRoutingPolicy(enclaverpc.KindNoiseSession, policy),
+	)
+
+	// Configure a fallback key manager with a committee of its own.
+	var fallbackNode signature.PublicKey
+	fallbackNode[0] = 0xff
+	fallbackPeer := core.PeerID("peer-fallback")
+	fallbackCli := &testKeyManagerClient{
+		failing:     make(map[core.PeerID]bool),
+		unreachable: make(map[core.PeerID]bool),
+	}
+	km.fallbackCli = fallbackCli
+	km.fallbackNt = &nodeTracker{
+		nodes:  map[signature.PublicKey]core.PeerID{fallbackNode: fallbackPeer},
+		logger: logging.GetLogger("test"),
+	}
+
+	// Calls should be routed to the primary key manager while it is reachable.
+	_, node, err := km.CallEnclave(ctx, []byte("primary"), nil, enclaverpc.KindNoiseSession, nil)
+	require.NoError(err, "CallEnclave")
+	require.Contains(peers, km.nt.nodes[node])
+	require.Len(cli.takeCalls(), 1)
+	require.Empty(fallbackCli.takeCalls(), "fallback key manager should not be called")
+
+	// Calls should not fail over when the primary key manager nodes fail to serve them.
+	for _, peer := range peers {
+		cli.failing[peer] = true
+	}
+	_, _, err = km.CallEnclave(ctx, []byte("failing"), nil, enclaverpc.KindNoiseSession, nil)
+	require.Error(err, "CallEnclave should fail")
+	require.Len(cli.takeCalls(), 1)
+	require.Empty(fallbackCli.takeCalls(), "fallback key manager should not be called")
+
+	// Calls should fail over to the fallback key manager once the primary is not reachable. The
+	// nodes of the primary key manager should not restrict calls to the fallback key manager.
+	for _, peer := range peers {
+		delete(cli.failing, peer)
+		cli.unreachable[peer] = true
+	}
+	var primaryNode signature.PublicKey
+	_, node, err = km.CallEnclave(ctx, []byte("fallback"), []signature.PublicKey{primaryNode}, enclaverpc.KindNoiseSession, nil)
+	require.NoError(err, "CallEnclave")
+	require.Equal(fallbackNode, node)
+	require.Len(cli.takeCalls(), 1)
+	require.Equal([][]core.PeerID{{fallbackPeer}}, fallbackCli.takeCalls())
+
+	// Peer feedback should be recorded against the fallback key manager peer.
+	require.Equal(fallbackPeer, km.lastPeerFeedback.PeerID())
+}
+
+func TestKeyManagerMaxCallDuration(t *testing.T) {
+	require := require.New(t)
+
+	maxCallDuration := 50 * time.Millisecond
+	km, cli, _ := newTestKeyManagerClientWrapper(2,
+		WithKeyManagerRoutingPolicy(enclaverpc.KindNoiseSession, KeyManagerRoutingPolicy{
+			Mode:          KeyManagerRoutingOneOf,
+			MaxRetries:    100,
+			RetryInterval: time.Millisecond,
+		}),
+		WithKeyManagerMaxCallDuration(maxCallDuration),
+	)
+
+	// Make a successful call, so that there is feedback to propagate.
+	_, _, err := km.CallEnclave(context.Background(), []byte("ok"), nil, enclaverpc.KindNoiseSession, nil)
+	require.NoError(err, "CallEnclave")
+	require.NotNil(km.lastPeerFeedback)
+
+	// Calls without a deadline should be cut off after the maximum call duration.
+	cli.blocking = true
+	start := time.Now()
+	_, _, err = km.CallEnclave(context.Background(), []byte("blocked"), nil, enclaverpc.KindNoiseSession, nil)
+	require.ErrorIs(err, context.DeadlineExceeded)
+	require.Less(time.Since(start), 10*maxCallDuration)
+	require.Nil(km.lastPeerFeedback, "feedback on the last call should not be propagated again")
+
+	// Calls with an earlier deadline should not be affected.
+	ctx, cancel := context.WithTimeout(context.Background(), maxCallDuration/5)
+	defer cancel()
+	start = time.Now()
+	_, _, err = km.CallEnclave(ctx, []byte("blocked"), nil, enclaverpc.KindNoiseSession, nil)
+	require.ErrorIs(err, context.DeadlineExceeded)
+	require.Less(time.Since(start), maxCallDuration)
+}
+
+func TestKeyManagerWatchCommittee(t *testing.T) {
+	require := require.New(t)
+
+	km, _, peers := newTestKeyManagerClientWrapper(2)
+
+	ch, sub := km.WatchCommittee()
+	defer sub.Close()
+
+	recvCommittee := func() []core.PeerID {
+		select {
+		case committee := <-ch:
+			return committee
+		case <-time.After(time.Second):
+			require.FailNow("failed to receive committee")
+			return nil
+		}
+	}
+
+	// Membership changes should be notified.
+	var node signature.PublicKey
+	node[0] = 0xff
+	newPeer := core.PeerID("peer-new")
+	nodes := map[signature.PublicKey]core.PeerID{node: newPeer}
+	for n, p := range km.nt.nodes {
+		nodes[n] = p
+	}
+	km.nt.setNodes(nodes)
+	require.ElementsMatch(append([]core.PeerID{newPeer}, peers...), recvCommittee())
+
+	// Updates without membership changes should not be notified.
+	unchanged := make(map[signature.PublicKey]core.PeerID)
+	for n, p := range nodes {
+		unchanged[n] = p
+	}
+	km.nt.setNodes(unchanged)
+
+	delete(nodes, node)
+	km.nt.setNodes(nodes)
+	require.ElementsMatch(peers, recvCommittee())
+
+	// New subscribers should receive the latest committee.
+	ch2, sub2 := km.WatchCommittee()
+	defer sub2.Close()
+	select {
+	case committee := <-ch2:
+		require.ElementsMatch(peers, committee)
+	case <-time.After(time.Second):
+		require.FailNow("failed to receive committee")
+	}
+}
+
+func TestKeyManagerBadPeers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require := require.New(t)
+
+	km, cli, peers := newTestKeyManagerClientWrapper(2,
+		WithKeyManagerRoutingPolicy(enclaverpc.KindInsecureQuery, KeyManagerRoutingPolicy{
+			Mode: KeyManagerRoutingSingle,
+		}),
+	)
+
+	_, node, err := km.CallEnclave(ctx, []byte("first"), nil, enclaverpc.KindInsecureQuery, nil)
+	require.NoError(err, "CallEnclave")
+	badPeer := km.nt.nodes[node]
+	cli.takeCalls()
+
+	// Peers reported as bad by the runtime should be excluded from routing.
+	bad := enclaverpc.PeerFeedbackBadPeer
+	_, node, err = km.CallEnclave(ctx, []byte("second"), nil, enclaverpc.KindInsecureQuery, &bad)
+	require.NoError(err, "CallEnclave")
+	require.NotEqual(badPeer, km.nt.nodes[node])
+	for _, call := range cli.takeCalls() {
+		require.NotContains(call, badPeer)
+	}
+
+	// Clearing bad peers should make them available again.
+	km.ClearBadPeers()
+	_, _, err = km.CallEnclave(ctx, []byte("third"), nil, enclaverpc.KindNoiseSession, nil)
+	require.NoError(err, "CallEnclave")
+	calls := cli.takeCalls()
+	require.Len(calls, 1)
+	require.ElementsMatch(peers, calls[0])
+}
+
+func TestKeyManagerReadOnlyRouting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require := require.New(t)
+
+	km, cli, peers := newTestKeyManagerClientWrapper(3)
+	cli.failing[peers[0]] = true
+
+	// Read-only calls should be routed to all nodes in parallel.
+	rsp, node, err := km.CallEnclave(ctx, []byte("read-only"), nil, enclaverpc.KindInsecureQuery, nil)
+	require.NoError(err, "CallEnclave")
+	require.Equal([]byte("read-only"), rsp)
+	require.Equal(1, cli.anyCalls)
+	calls := cli.takeCalls()
+	require.Len(calls, 1)
+	require.ElementsMatch(peers, calls[0])
+
+	// The serving node should be resolved from the serving peer.
+	require.Equal(km.lastPeerFeedback.PeerID(), km.nt.nodes[node])
+	require.NotEqual(peers[0], km.nt.nodes[node])
+
+	// Other calls should not be routed in parallel.
+	_, _, err = km.CallEnclave(ctx, []byte("stateful"), nil, enclaverpc.KindNoiseSession, nil)
+	require.NoError(err, "CallEnclave")
+	require.Equal(1, cli.anyCalls)
+}
+
+func TestKeyManagerMinNodes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require := require.New(t)
+
+	km, cli, peers := newTestKeyManagerClientWrapper(2)
+	km.nt.minNodes = 3
+
+	// Calls should not be routed to too few committee members.
+	_, _, err := km.CallEnclave(ctx, []byte("hello"), nil, enclaverpc.KindNoiseSession, nil)
+	require.ErrorIs(err, ErrInsufficientKeyManagerCommittee)
+	require.Empty(cli.takeCalls())
+
+	// Once the minimum is met, calls should be routed again.
+	var node signature.PublicKey
+	node[0] = 0xff
+	km.nt.nodes[node] = core.PeerID("peer-new")
+
+	_, _, err = km.CallEnclave(ctx, []byte("hello"), nil, enclaverpc.KindNoiseSession, nil)
+	require.NoError(err, "CallEnclave")
+	calls := cli.takeCalls()
+	require.Len(calls, 1)
+	require.Subset(calls[0], peers)
+}
+
+func TestKeyManagerCallDeduplication(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require := require.New(t)
+
+	km, cli, _ := newTestKeyManagerClientWrapper(3)
+
+	// Block the first call until the other calls are made.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	cli.onCall = func() {
+		once.Do(func() { close(started) })
+		<-release
+	}
+
+	const numCalls = 5
+	data := []byte("read-only")
+	rsps := make([][]byte, numCalls)
+	errs := make([]error, numCalls)
+
+	var wg sync.WaitGroup
+	call := func(i int) {
+		defer wg.Done()
+		rsps[i], _, errs[i] = km.CallEnclave(ctx, data, nil, enclaverpc.KindInsecureQuery, nil)
+	}
+
+	wg.Add(1)
+	go call(0)
+	<-started
+	for i := 1; i < numCalls; i++ {
+		wg.Add(1)
+		go call(i)
+	}
+
+	// Give the other calls time to join the in-flight call.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Len(cli.takeCalls(), 1, "identical read-only calls should share a single call")
+	for i := 0; i < numCalls; i++ {
+		require.NoError(errs[i], "CallEnclave")
+		require.Equal(data, rsps[i])
+	}
+
+	// Each caller should get its own copy of the response.
+	rsps[0][0] = 'x'
+	for i := 1; i < numCalls; i++ {
+		require.Equal(data, rsps[i])
+	}
+	require.Equal([]byte("read-only"), data)
+
+	// The shared call should not be affected by the first caller giving up.
+	started = make(chan struct{})
+	release = make(chan struct{})
+	once = sync.Once{}
+
+	firstCtx, firstCancel := context.WithCancel(ctx)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, errs[0] = km.CallEnclave(firstCtx, data, nil, enclaverpc.KindInsecureQuery, nil)
+	}()
+	<-started
+	wg.Add(1)
+	go call(1)
+
+	time.Sleep(100 * time.Millisecond)
+	firstCancel()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Len(cli.takeCalls(), 1, "identical read-only calls should share a single call")
+	require.ErrorIs(errs[0], context.Canceled)
+	require.NoError(errs[1], "CallEnclave should not fail when another caller gives up")
+	require.Equal(data, rsps[1])
+
+	// Calls carrying peer feedback should be shared, with the feedback of each caller recorded.
+	lastPf := km.lastPeerFeedback.(*testPeerFeedback)
+	successes, failures := lastPf.successes.Load(), lastPf.failures.Load()
+
+	started = make(chan struct{})
+	release = make(chan struct{})
+	once = sync.Once{}
+
+	success, failure := enclaverpc.PeerFeedbackSuccess, enclaverpc.PeerFeedbackFailure
+	pfs := []*enclaverpc.PeerFeedback{&failure, &success, &failure, nil, nil}
+	callWithFeedback := func(i int) {
+		defer wg.Done()
+		rsps[i], _, errs[i] = km.CallEnclave(ctx, data, nil, enclaverpc.KindInsecureQuery, pfs[i])
+	}
+
+	wg.Add(1)
+	go callWithFeedback(0)
+	<-started
+	for i := 1; i < numCalls; i++ {
+		wg.Add(1)
+		go callWithFeedback(i)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Len(cli.takeCalls(), 1, "identical read-only calls with feedback should share a single call")
+	for i := 0; i < numCalls; i++ {
+		require.NoError(errs[i], "CallEnclave")
+		require.Equal(data, rsps[i])
+	}
+	require.EqualValues(successes+3, lastPf.successes.Load(), "feedback of each caller should be recorded")
+	require.EqualValues(failures+2, lastPf.failures.Load(), "feedback of each caller should be recorded")
+}
+
+func TestKeyManagerWaitInitialized(t *testing.T) {
+	require := require.New(t)
+
+	km := NewKeyManagerClientWrapper(nil, nil, "", logging.GetLogger("test"))
+	err := km.WaitInitialized(context.Background())
+	require.ErrorIs(err, ErrKeyManagerNotAvailable)
+
+	km, _, _ = newTestKeyManagerClientWrapper(1)
+	km.nt.initCh = make(chan struct{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = km.WaitInitialized(ctx)
+	require.ErrorIs(err, context.DeadlineExceeded)
+
+	close(km.nt.initCh)
+	err = km.WaitInitialized(context.Background())
+	require.NoError(err, "WaitInitialized")
+}
+
+func TestKeyManagerMetrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require := require.New(t)
+
+	km, cli, peers := newTestKeyManagerClientWrapper(1)
+	kind := enclaverpc.KindNoiseSession
+	km.routingPolicies[kind] = KeyManagerRoutingPolicy{
+		Mode:          KeyManagerRoutingOneOf,
+		RetryInterval: time.Millisecond,
+	}
+	cli.latencies[peers[0]] = 100 * time.Millisecond
+
+	labels := km.metricLabels(nil, kind)
+	count := func(c *prometheus.CounterVec) float64 {
+		return testutil.ToFloat64(c.With(labels))
+	}
+	calls, successes, failures, badPeers := count(keyManagerCallCount), count(keyManagerCallSuccesses), count(keyManagerCallFailures), count(keyManagerBadPeerFeedback)
+
+	_, _, err := km.CallEnclave(ctx, []byte("success"), nil, kind, nil)
+	require.NoError(err, "CallEnclave")
+
+	cli.failing[peers[0]] = true
+	bad := enclaverpc.PeerFeedbackBadPeer
+	_, _, err = km.CallEnclave(ctx, []byte("failure"), nil, kind, &bad)
+	require.Error(err, "CallEnclave should fail")
+
+	require.Equal(calls+2, count(keyManagerCallCount))
+	require.Equal(successes+1, count(keyManagerCallSuccesses))
+	require.Equal(failures+1, count(keyManagerCallFailures))
+	require.Equal(badPeers+1, count(keyManagerBadPeerFeedback))
+}
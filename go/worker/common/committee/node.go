@@ -11,6 +11,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	beacon "github.com/oasisprotocol/oasis-core/go/beacon/api"
+	"github.com/oasisprotocol/oasis-core/go/common"
 	"github.com/oasisprotocol/oasis-core/go/common/identity"
 	"github.com/oasisprotocol/oasis-core/go/common/logging"
 	"github.com/oasisprotocol/oasis-core/go/common/version"
@@ -160,6 +161,8 @@ type Node struct {
 
 	txTopic string
 
+	fallbackKeyManagerID *common.Namespace
+
 	ctx       context.Context
 	cancelCtx context.CancelFunc
 	stopCh    chan struct{}
@@ -247,6 +250,7 @@ func (n *Node) Stop() {
 		close(n.stopCh)
 		n.TxPool.Stop()
 		n.KeyManagerClient.SetKeyManagerID(nil)
+		n.KeyManagerClient.SetFallbackKeyManagerID(nil)
 	})
 }
 
@@ -647,6 +651,7 @@ func (n *Node) worker() {
 		)
 
 		n.KeyManagerClient.SetKeyManagerID(rt.KeyManager)
+		n.KeyManagerClient.SetFallbackKeyManagerID(n.fallbackKeyManagerID)
 		select {
 		case <-n.ctx.Done():
 			n.logger.Error("failed to wait for key manager",
@@ -876,7 +881,17 @@ func NewNode(
 	}
 
 	// Prepare the key manager client wrapper.
+	if kmID, ok := config.GlobalConfig.Runtime.FallbackKeyManagers[runtime.ID().String()]; ok {
+		var id common.Namespace
+		if err := id.UnmarshalHex(kmID); err != nil {
+			return nil, fmt.Errorf("malformed fallback key manager ID: %w", err)
+		}
+		n.fallbackKeyManagerID = &id
+	}
 	n.KeyManagerClient = NewKeyManagerClientWrapper(p2pHost, consensus, chainContext, n.logger)
+	if !n.KeyManagerClient.PeerImportanceTaggingActive() {
+		n.logger.Debug("peer importance tagging not available")
+	}
 
 	// Prepare the runtime host node helpers.
 	rhn, err := runtimeRegistry.NewRuntimeHostNode(n)
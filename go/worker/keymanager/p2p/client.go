@@ -24,8 +24,17 @@ const (
 type Client interface {
 	// CallEnclave calls a key manager enclave with the provided data.
 	//
-	// The peer to which the call will be routed is chosen at random from the given list.
-	CallEnclave(ctx context.Context, request *CallEnclaveRequest, peers []core.PeerID) (*CallEnclaveResponse, rpc.PeerFeedback, error)
+	// The peer to which the call will be routed is chosen from the given list by the peer manager,
+	// unless the peer order is configured using rpc.WithPeerSort. The given call options override
+	// the default ones.
+	CallEnclave(ctx context.Context, request *CallEnclaveRequest, peers []core.PeerID, opts ...rpc.CallOption) (*CallEnclaveResponse, rpc.PeerFeedback, error)
+
+	// CallEnclaveAny calls a key manager enclave with the provided data.
+	//
+	// The call is routed to all of the given peers in parallel and the first valid response
+	// is returned, so it should only be used for read-only requests. The given call options
+	// override the default ones.
+	CallEnclaveAny(ctx context.Context, request *CallEnclaveRequest, peers []core.PeerID, opts ...rpc.CallOption) (*CallEnclaveResponse, rpc.PeerFeedback, error)
 }
 
 type client struct {
@@ -33,11 +42,18 @@ type client struct {
 	mgr rpc.PeerManager
 }
 
-func (c *client) CallEnclave(ctx context.Context, request *CallEnclaveRequest, peers []core.PeerID) (*CallEnclaveResponse, rpc.PeerFeedback, error) {
+func (c *client) CallEnclave(ctx context.Context, request *CallEnclaveRequest, peers []core.PeerID, opts ...rpc.CallOption) (*CallEnclaveResponse, rpc.PeerFeedback, error) {
+	var rsp CallEnclaveResponse
+	pf, err := c.rc.CallOne(ctx, c.mgr.GetBestPeers(rpc.WithLimitPeers(peers)), MethodCallEnclave, request, &rsp, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &rsp, pf, nil
+}
+
+func (c *client) CallEnclaveAny(ctx context.Context, request *CallEnclaveRequest, peers []core.PeerID, opts ...rpc.CallOption) (*CallEnclaveResponse, rpc.PeerFeedback, error) {
 	var rsp CallEnclaveResponse
-	pf, err := c.rc.CallOne(ctx, c.mgr.GetBestPeers(rpc.WithLimitPeers(peers)), MethodCallEnclave, request, &rsp,
-		rpc.WithMaxRetries(MaxCallEnclaveRetries),
-	)
+	pf, err := c.rc.CallAny(ctx, c.mgr.GetBestPeers(rpc.WithLimitPeers(peers)), MethodCallEnclave, request, &rsp, opts...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -48,7 +64,7 @@ func (c *client) CallEnclave(ctx context.Context, request *CallEnclaveRequest, p
 func NewClient(p2p p2p.Service, chainContext string, keymanagerID common.Namespace) Client {
 	pid := protocol.NewRuntimeProtocolID(chainContext, keymanagerID, KeyManagerProtocolID, KeyManagerProtocolVersion)
 	mgr := rpc.NewPeerManager(p2p, pid, rpc.WithStickyPeers(true))
-	rc := rpc.NewClient(p2p.Host(), pid)
+	rc := rpc.NewClient(p2p.Host(), pid, rpc.WithDefaultCallOptions(rpc.WithMaxRetries(MaxCallEnclaveRetries)))
 	rc.RegisterListener(mgr)
 
 	p2p.RegisterProtocol(pid, minProtocolPeers, totalProtocolPeers)
@@ -57,6 +57,7 @@ func (c *client) GetDiff(ctx context.Context, request *GetDiffRequest) (*GetDiff
 	var rsp GetDiffResponse
 	pf, err := c.rcD.CallOne(ctx, c.mgrD.GetBestPeers(), MethodGetDiff, request, &rsp,
 		rpc.WithMaxPeerResponseTime(MaxGetDiffResponseTime),
+		rpc.WithCompression(rpc.CompressionSnappy),
 	)
 	if err != nil {
 		return nil, nil, err